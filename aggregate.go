@@ -0,0 +1,163 @@
+package dba
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+)
+
+type AggregateFunc string
+
+const (
+	AggregateCount         AggregateFunc = "COUNT"
+	AggregateCountDistinct AggregateFunc = "COUNT_DISTINCT"
+	AggregateSum           AggregateFunc = "SUM"
+	AggregateAvg           AggregateFunc = "AVG"
+	AggregateMin           AggregateFunc = "MIN"
+	AggregateMax           AggregateFunc = "MAX"
+)
+
+// Aggregation 聚合查询的投影
+type Aggregation struct {
+	Func  AggregateFunc `json:"func"`
+	Field string        `json:"field,omitempty"` // 字段名称，COUNT为空时统计行数
+	Alias string        `json:"alias,omitempty"` // 结果列名，默认为函数名+字段名，如sum_height
+}
+
+func Count() *Aggregation {
+	return &Aggregation{Func: AggregateCount}
+}
+
+func CountDistinct(field string) *Aggregation {
+	return &Aggregation{Func: AggregateCountDistinct, Field: field}
+}
+
+func Sum(field string) *Aggregation {
+	return &Aggregation{Func: AggregateSum, Field: field}
+}
+
+func Avg(field string) *Aggregation {
+	return &Aggregation{Func: AggregateAvg, Field: field}
+}
+
+func Min(field string) *Aggregation {
+	return &Aggregation{Func: AggregateMin, Field: field}
+}
+
+func Max(field string) *Aggregation {
+	return &Aggregation{Func: AggregateMax, Field: field}
+}
+
+// As 设置结果列名
+func (a *Aggregation) As(alias string) *Aggregation {
+	a.Alias = alias
+	return a
+}
+
+func (a *Aggregation) alias() string {
+	if a.Alias != "" {
+		return a.Alias
+	}
+	name := strings.ToLower(string(a.Func))
+	if a.Field != "" && a.Field != "*" {
+		name += "_" + strcase.ToSnake(a.Field)
+	}
+	return name
+}
+
+// expression 生成聚合表达式，字段名称转换为原生列名
+func (a *Aggregation) expression(driver Driver, sch *Schema, strict bool) (string, error) {
+	fn := AggregateFunc(strings.ToUpper(strings.TrimSpace(string(a.Func))))
+	if fn == AggregateCount && (a.Field == "" || a.Field == "*") {
+		return "COUNT(*)", nil
+	}
+	if a.Field == "" {
+		return "", fmt.Errorf("dba: missing aggregate field: %s", a.Func)
+	}
+	column, field, err := resolveColumn(driver, sch, a.Field, strict)
+	if err != nil {
+		return "", err
+	}
+	if field != nil && (field.IsVirtual || !field.IsScalarType()) {
+		return "", fmt.Errorf("dba: invalid aggregate field: %s.%s", sch.Name, a.Field)
+	}
+	switch fn {
+	case AggregateCount, AggregateSum, AggregateAvg, AggregateMin, AggregateMax:
+		return fmt.Sprintf("%s(%s)", fn, column), nil
+	case AggregateCountDistinct:
+		return fmt.Sprintf("COUNT(DISTINCT %s)", column), nil
+	default:
+		return "", fmt.Errorf("dba: invalid aggregate function: %s", a.Func)
+	}
+}
+
+// GroupBy 按字段分组
+func (r *Result) GroupBy(names ...string) *Result {
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			r.groupBys = append(r.groupBys, name)
+		}
+	}
+	return r
+}
+
+// Having 分组过滤，条件中可使用聚合结果列名
+func (r *Result) Having(conditions ...any) *Result {
+	if len(conditions) == 0 {
+		return r
+	}
+	if f := And(conditions...); f != nil {
+		r.havings = append(r.havings, f)
+	}
+	return r
+}
+
+// aggregation 按结果列名查找聚合投影
+func (r *Result) aggregation(alias string) *Aggregation {
+	for _, agg := range r.aggregates {
+		if agg.alias() == alias {
+			return agg
+		}
+	}
+	return nil
+}
+
+func (r *Result) Aggregate(dst any, aggregations ...*Aggregation) error {
+	return r.AggregateContext(context.Background(), dst, aggregations...)
+}
+
+// AggregateContext 执行聚合查询，结果包含分组列（原生列名）及聚合结果列，可扫描到结构体、map或其数组
+func (r *Result) AggregateContext(ctx context.Context, dst any, aggregations ...*Aggregation) error {
+	// FINAL
+	defer r.reset()
+	ctx = r.readContext(ctx)
+
+	for _, agg := range aggregations {
+		if agg != nil {
+			r.aggregates = append(r.aggregates, agg)
+		}
+	}
+	if len(r.aggregates) == 0 {
+		return fmt.Errorf("dba: missing aggregations")
+	}
+	data, attrs, err := r.beforeQuery()
+	if err != nil {
+		return err
+	}
+	var buff bytes.Buffer
+	if err := r.dm.queryTemplate.Execute(&buff, data); err != nil {
+		return err
+	}
+	sql := buff.String()
+	sql = r.dm.conn.Rebind(formatSQL(sql))
+
+	if err := autoScan(ctx, dst, r.queryer(ctx), sql, attrs); err != nil {
+		r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).Errorf("Aggregate failed: %v", err)
+		return err
+	}
+	r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).Infof("Aggregate successful")
+	return nil
+}
@@ -0,0 +1,73 @@
+package dba
+
+import (
+	"testing"
+)
+
+type Sale struct {
+	ID     uint `dba:"pk;incr"`
+	Region string
+	Amount int
+}
+
+type SaleSummary struct {
+	Region string  `db:"region"`
+	Orders int     `db:"count"`
+	Total  int     `db:"total"`
+	Avg    float64 `db:"avg_amount"`
+	Min    int     `db:"min_amount"`
+	Max    int     `db:"max_amount"`
+}
+
+func TestAggregate(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &Sale{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	model := func() *DataModel { return conn.ns.Model("Sale") }
+	if err := model().Create([]*Sale{
+		{Region: "east", Amount: 10}, {Region: "east", Amount: 30},
+		{Region: "west", Amount: 5},
+		{Region: "north", Amount: 50}, {Region: "north", Amount: 70}, {Region: "north", Amount: 60},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var list []*SaleSummary
+	err := model().Find("Amount >", 0).
+		GroupBy("Region").
+		Having("count >=", 2).
+		OrderBy("-total").
+		Aggregate(&list, Count(), Sum("Amount").As("total"), Avg("Amount"), Min("Amount"), Max("Amount"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 groups, got %+v", list)
+	}
+	if s := list[0]; s.Region != "north" || s.Orders != 3 || s.Total != 180 || s.Avg != 60 || s.Min != 50 || s.Max != 70 {
+		t.Fatalf("unexpected north summary: %+v", s)
+	}
+	if s := list[1]; s.Region != "east" || s.Orders != 2 || s.Total != 40 || s.Avg != 20 {
+		t.Fatalf("unexpected east summary: %+v", s)
+	}
+
+	// 无分组时返回单行
+	row := make(map[string]any)
+	if err := model().Find().Aggregate(row, CountDistinct("Region"), Sum("Amount")); err != nil {
+		t.Fatal(err)
+	}
+	if row["count_distinct_region"] != int64(3) || row["sum_amount"] != int64(225) {
+		t.Fatalf("unexpected totals: %v", row)
+	}
+
+	for name, fn := range map[string]func() error{
+		"missing aggregations": func() error { return model().Find().Aggregate(row) },
+		"missing field":        func() error { return model().Find().Aggregate(row, Sum("")) },
+		"invalid function":     func() error { return model().Find().Aggregate(row, &Aggregation{Func: "MEDIAN", Field: "Amount"}) },
+	} {
+		if err := fn(); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
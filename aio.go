@@ -1,16 +1,16 @@
 package dba
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
-	"github.com/jmoiron/sqlx"
-	"sync"
+	"time"
 )
 
-var txStore sync.Map
-
 type AioArgs struct {
 	Action string         `msgpack:"action"`
 	Data   map[string]any `msgpack:"data"`
+	Client string         `msgpack:"client"` // 调用方标识，事务会话只能由开启它的调用方使用，由服务端按连接设置
 }
 
 type AioReply struct {
@@ -51,6 +51,31 @@ func HandleAio(args *AioArgs) *AioReply {
 	case "connection_names":
 		names := ConnectionNames()
 		reply.Data = map[string]any{"names": names}
+	case "connection_stats":
+		var input struct {
+			Names []string `json:"names"` // 未指定时返回全部连接
+		}
+		if err = ConvertData(args.Data, &input); err != nil {
+			return reply
+		}
+		names := input.Names
+		if len(names) == 0 {
+			names = ConnectionNames()
+		}
+		stats := make(map[string]sql.DBStats)
+		replicaStats := make(map[string][]sql.DBStats)
+		for _, name := range names {
+			conn := Session(name)
+			if conn == nil {
+				err = fmt.Errorf("dba: connection not exists: %s", name)
+				return reply
+			}
+			stats[name] = conn.Stats()
+			for _, r := range conn.Replicas() {
+				replicaStats[name] = append(replicaStats[name], r.Stats())
+			}
+		}
+		reply.Data = map[string]any{"stats": stats, "replica_stats": replicaStats}
 	//数据源管理
 	case "register_schema":
 		var values []any
@@ -81,6 +106,7 @@ func HandleAio(args *AioArgs) *AioReply {
 	// 脚本操作
 	case "exec":
 		var input struct {
+			TxID           string `json:"tx_id"`
 			ConnectionName string `json:"connection_name"`
 			Query          string `json:"query"`
 			IsBatch        bool   `json:"is_batch"`
@@ -89,21 +115,38 @@ func HandleAio(args *AioArgs) *AioReply {
 		if err = ConvertData(args.Data, &input); err != nil {
 			return reply
 		}
-		if input.IsBatch {
-			if ns, e := ExecByBatch(input.ConnectionName, input.Query, input.Args...); e != nil {
-				err = e
-			} else {
+		err = withAioTx(args.Client, input.TxID, func(tx *Tx) error {
+			ctx := context.Background()
+			if input.IsBatch {
+				var ns []int
+				var e error
+				if tx != nil {
+					ns, e = tx.conn.batchExecTx(ctx, tx.tx, input.Query, input.Args...)
+				} else {
+					ns, e = ExecByBatch(input.ConnectionName, input.Query, input.Args...)
+				}
+				if e != nil {
+					return e
+				}
 				reply.Data = map[string]any{"ns": ns}
+				return nil
 			}
-		} else {
-			if n, e := ExecBy(input.ConnectionName, input.Query, input.Args...); e != nil {
-				err = e
+			var n int
+			var e error
+			if tx != nil {
+				n, e = tx.ExecContext(ctx, input.Query, input.Args...)
 			} else {
-				reply.Data = map[string]any{"n": n}
+				n, e = ExecBy(input.ConnectionName, input.Query, input.Args...)
 			}
-		}
+			if e != nil {
+				return e
+			}
+			reply.Data = map[string]any{"n": n}
+			return nil
+		})
 	case "query":
 		var input struct {
+			TxID           string `json:"tx_id"`
 			ConnectionName string `json:"connection_name"`
 			Query          string `json:"query"`
 			Args           []any  `json:"args"`
@@ -112,16 +155,29 @@ func HandleAio(args *AioArgs) *AioReply {
 		if err = ConvertData(args.Data, &input); err != nil {
 			return reply
 		}
-		var dst any
+		var (
+			list = make([]map[string]any, 0)
+			row  = make(map[string]any)
+			dst  any
+		)
 		if input.IsList {
-			dst = make([]map[string]any, 0)
+			dst = &list
 		} else {
-			dst = make(map[string]any)
+			dst = row
 		}
-		if err = QueryBy(input.ConnectionName, &dst, input.Query, input.Args...); err != nil {
+		if err = withAioTx(args.Client, input.TxID, func(tx *Tx) error {
+			if tx != nil {
+				return tx.Query(dst, input.Query, input.Args...)
+			}
+			return QueryBy(input.ConnectionName, dst, input.Query, input.Args...)
+		}); err != nil {
 			return reply
 		}
-		reply.Data = map[string]any{"data": dst}
+		if input.IsList {
+			reply.Data = map[string]any{"data": list}
+		} else {
+			reply.Data = map[string]any{"data": row}
+		}
 	// 模型操作
 	case "model_create":
 		var input struct {
@@ -130,24 +186,14 @@ func HandleAio(args *AioArgs) *AioReply {
 			ModelOptions *ModelOptions  `json:"model_options"`
 			Data         any            `json:"data"`
 			Options      *CreateOptions `json:"options"`
+			Actor        *Actor         `json:"actor"`
 		}
 		if err = ConvertData(args.Data, &input); err != nil {
 			return reply
 		}
-		var tx *sqlx.Tx
-		if input.TxID != "" {
-			if t, e := loadTxWithStoreID(input.TxID); err != nil {
-				err = e
-				return reply
-			} else {
-				tx = t
-				if input.ModelOptions == nil {
-					input.ModelOptions = new(ModelOptions)
-				}
-				input.ModelOptions.Tx = tx
-			}
-		}
-		if err = Model(input.ModelName, input.ModelOptions).Create(input.Data, input.Options); err != nil {
+		if err = withAioTx(args.Client, input.TxID, func(tx *Tx) error {
+			return aioModel(tx, input.ModelName, input.ModelOptions).CreateContext(WithActor(context.Background(), input.Actor), input.Data, input.Options)
+		}); err != nil {
 			return reply
 		}
 		reply.Data = map[string]any{"data": input.Data}
@@ -159,28 +205,19 @@ func HandleAio(args *AioArgs) *AioReply {
 			Filters      []any          `json:"filters"`
 			Data         any            `json:"data"`
 			Options      *UpdateOptions `json:"options"`
+			Actor        *Actor         `json:"actor"`
 		}
 		if err = ConvertData(args.Data, &input); err != nil {
 			return reply
 		}
-		var tx *sqlx.Tx
-		if input.TxID != "" {
-			if t, e := loadTxWithStoreID(input.TxID); err != nil {
-				err = e
-				return reply
-			} else {
-				tx = t
-				if input.ModelOptions == nil {
-					input.ModelOptions = new(ModelOptions)
-				}
-				input.ModelOptions.Tx = tx
+		err = withAioTx(args.Client, input.TxID, func(tx *Tx) error {
+			n, e := aioModel(tx, input.ModelName, input.ModelOptions).Find(input.Filters...).UpdateContext(WithActor(context.Background(), input.Actor), input.Data, input.Options)
+			if e != nil {
+				return e
 			}
-		}
-		if n, e := Model(input.ModelName, input.ModelOptions).Find(input.Filters...).Update(input.Data, input.Options); e != nil {
-			err = e
-		} else {
 			reply.Data = map[string]any{"n": n}
-		}
+			return nil
+		})
 	case "model_delete":
 		var input struct {
 			TxID         string         `json:"tx_id"`
@@ -188,28 +225,19 @@ func HandleAio(args *AioArgs) *AioReply {
 			ModelOptions *ModelOptions  `json:"model_options"`
 			Filters      []any          `json:"filters"`
 			Options      *DeleteOptions `json:"options"`
+			Actor        *Actor         `json:"actor"`
 		}
 		if err = ConvertData(args.Data, &input); err != nil {
 			return reply
 		}
-		var tx *sqlx.Tx
-		if input.TxID != "" {
-			if t, e := loadTxWithStoreID(input.TxID); err != nil {
-				err = e
-				return reply
-			} else {
-				tx = t
-				if input.ModelOptions == nil {
-					input.ModelOptions = new(ModelOptions)
-				}
-				input.ModelOptions.Tx = tx
+		err = withAioTx(args.Client, input.TxID, func(tx *Tx) error {
+			n, e := aioModel(tx, input.ModelName, input.ModelOptions).Find(input.Filters...).DeleteContext(WithActor(context.Background(), input.Actor), input.Options)
+			if e != nil {
+				return e
 			}
-		}
-		if n, e := Model(input.ModelName, input.ModelOptions).Find(input.Filters...).Delete(input.Options); e != nil {
-			err = e
-		} else {
 			reply.Data = map[string]any{"n": n}
-		}
+			return nil
+		})
 	case "model_query":
 		var input struct {
 			ConnectionName string             `json:"connection_name"`
@@ -222,13 +250,35 @@ func HandleAio(args *AioArgs) *AioReply {
 			Populates      []*PopulateOptions `json:"populates"`
 			PageNum        int                `json:"page_num"`
 			PageSize       int                `json:"page_size"`
+			Cursor         string             `json:"cursor"`      // 游标分页：上一次返回的next_cursor或prev_cursor
+			CursorSize     int                `json:"cursor_size"` // 游标分页：每页条数，大于0时启用
+			WithDeleted    bool               `json:"with_deleted"`
+			OnlyDeleted    bool               `json:"only_deleted"`
 		}
 		if err = ConvertData(args.Data, &input); err != nil {
 			return reply
 		}
 		var results []map[string]any
 		res := Model(input.ModelName, input.ModelOptions).Find(input.Filters...).OrderBy(input.OrderBys...).Fields(input.Fields, input.IsOmit).PopulateBy(input.Populates...)
-		if input.PageSize > 0 {
+		if input.OnlyDeleted {
+			res.OnlyDeleted()
+		} else if input.WithDeleted {
+			res.WithDeleted()
+		}
+		if input.CursorSize > 0 {
+			page, e := res.Cursor(input.Cursor, input.CursorSize, &results)
+			if e != nil {
+				err = e
+				return reply
+			}
+			reply.Data = map[string]any{
+				"results":     results,
+				"next_cursor": page.Next,
+				"prev_cursor": page.Prev,
+				"has_next":    page.HasNext,
+				"has_prev":    page.HasPrev,
+			}
+		} else if input.PageSize > 0 {
 			if input.PageNum <= 0 {
 				input.PageNum = 1
 			}
@@ -258,11 +308,19 @@ func HandleAio(args *AioArgs) *AioReply {
 			ModelOptions   *ModelOptions `json:"model_options"`
 			Filters        []any         `json:"filters"`
 			OrderBys       []string      `json:"order_bys"`
+			WithDeleted    bool          `json:"with_deleted"`
+			OnlyDeleted    bool          `json:"only_deleted"`
 		}
 		if err = ConvertData(args.Data, &input); err != nil {
 			return reply
 		}
-		if n, e := Model(input.ModelName, input.ModelOptions).Find(input.Filters...).OrderBy(input.OrderBys...).Count(); e != nil {
+		res := Model(input.ModelName, input.ModelOptions).Find(input.Filters...).OrderBy(input.OrderBys...)
+		if input.OnlyDeleted {
+			res.OnlyDeleted()
+		} else if input.WithDeleted {
+			res.WithDeleted()
+		}
+		if n, e := res.Count(); e != nil {
 			err = e
 			return reply
 		} else {
@@ -270,66 +328,69 @@ func HandleAio(args *AioArgs) *AioReply {
 				"n": n,
 			}
 		}
-	case "tx_begin":
+	case "model_aggregate":
 		var input struct {
-			ConnectionName string `json:"connection_name"`
+			ConnectionName string         `json:"connection_name"`
+			ModelName      string         `json:"model_name"`
+			ModelOptions   *ModelOptions  `json:"model_options"`
+			Filters        []any          `json:"filters"`
+			GroupBys       []string       `json:"group_bys"`
+			Having         []any          `json:"having"`
+			Aggregations   []*Aggregation `json:"aggregations"`
+			OrderBys       []string       `json:"order_bys"`
+			Limit          int            `json:"limit"`
+			Offset         int            `json:"offset"`
+			WithDeleted    bool           `json:"with_deleted"`
+			OnlyDeleted    bool           `json:"only_deleted"`
 		}
 		if err = ConvertData(args.Data, &input); err != nil {
 			return reply
 		}
-		if t, e := Session(input.ConnectionName).Begin(); e != nil {
-			err = e
-			return reply
-		} else {
-			addr := fmt.Sprintf("%p", t)
-			txStore.Store(addr, t)
-			reply.Data = map[string]any{
-				"tx_id": addr,
-			}
+		var results []map[string]any
+		res := Model(input.ModelName, input.ModelOptions).Find(input.Filters...).GroupBy(input.GroupBys...).Having(input.Having...).OrderBy(input.OrderBys...).Limit(input.Limit).Offset(input.Offset)
+		if input.OnlyDeleted {
+			res.OnlyDeleted()
+		} else if input.WithDeleted {
+			res.WithDeleted()
+		}
+		if err = res.Aggregate(&results, input.Aggregations...); err != nil {
 			return reply
 		}
-	case "tx_commit":
+		reply.Data = map[string]any{
+			"results": results,
+		}
+	case "tx_begin":
 		var input struct {
-			TxID string `json:"tx_id"`
+			ConnectionName string             `json:"connection_name"`
+			Timeout        int                `json:"timeout"` // 空闲超时（秒），超时未使用自动回滚，默认DefaultAioTxTimeout
+			Isolation      sql.IsolationLevel `json:"isolation"`
+			ReadOnly       bool               `json:"read_only"`
 		}
 		if err = ConvertData(args.Data, &input); err != nil {
 			return reply
 		}
-		var tx *sqlx.Tx
-		if t, e := loadTxWithStoreID(input.TxID); err != nil {
+		s, e := beginAioTx(args.Client, input.ConnectionName, time.Duration(input.Timeout)*time.Second,
+			&sql.TxOptions{Isolation: input.Isolation, ReadOnly: input.ReadOnly})
+		if e != nil {
 			err = e
 			return reply
-		} else {
-			tx = t
 		}
-		err = tx.Commit()
-	case "tx_rollback":
+		reply.Data = map[string]any{"tx_id": s.id}
+	case "tx_commit", "tx_rollback":
 		var input struct {
 			TxID string `json:"tx_id"`
 		}
 		if err = ConvertData(args.Data, &input); err != nil {
 			return reply
 		}
-		var tx *sqlx.Tx
-		if t, e := loadTxWithStoreID(input.TxID); err != nil {
-			err = e
-			return reply
+		if args.Action == "tx_commit" {
+			err = commitAioTx(args.Client, input.TxID)
 		} else {
-			tx = t
+			err = rollbackAioTx(args.Client, input.TxID)
 		}
-		err = tx.Rollback()
+	case "tx_list":
+		reply.Data = map[string]any{"list": AioTxList()}
 	default:
 	}
 	return reply
 }
-
-func loadTxWithStoreID(tid string) (*sqlx.Tx, error) {
-	var tx *sqlx.Tx
-	if t, ok := txStore.Load(tid); ok {
-		tx = t.(*sqlx.Tx)
-	}
-	if tx == nil {
-		return nil, fmt.Errorf("tx not exist or expired: %s", tid)
-	}
-	return tx, fmt.Errorf("tx not exist or expired: %s", tid)
-}
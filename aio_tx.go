@@ -0,0 +1,194 @@
+package dba
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultAioTxTimeout Aio事务会话的默认空闲超时，超过该时间未使用的事务自动回滚
+var DefaultAioTxTimeout = time.Minute
+
+var aioTxSessions sync.Map
+
+// aioTxSession 通过Aio协议开启的事务会话，只能由开启它的调用方使用
+type aioTxSession struct {
+	mu        sync.Mutex
+	id        string
+	client    string
+	tx        *Tx
+	timeout   time.Duration
+	timer     *time.Timer
+	createdAt time.Time
+	usedAt    atomic.Int64 // 最后使用时间（UnixNano）
+	closed    bool
+}
+
+type AioTxInfo struct {
+	TxID           string    `json:"tx_id"`
+	Client         string    `json:"client"`
+	ConnectionName string    `json:"connection_name"`
+	CreatedAt      time.Time `json:"created_at"`
+	UsedAt         time.Time `json:"used_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+func newAioTxID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// beginAioTx 在指定连接上开启事务会话，timeout<=0时使用DefaultAioTxTimeout
+func beginAioTx(client, connectionName string, timeout time.Duration, opts *sql.TxOptions) (*aioTxSession, error) {
+	conn := Session(connectionName)
+	if conn == nil {
+		return nil, fmt.Errorf("dba: connection not exists: %s", connectionName)
+	}
+	id, err := newAioTxID()
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = DefaultAioTxTimeout
+	}
+	// 事务跨越多次请求，不能绑定单次请求的上下文
+	xtx, err := conn.xdb.BeginTxx(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &aioTxSession{
+		id:        id,
+		client:    client,
+		tx:        &Tx{conn: conn, tx: xtx},
+		timeout:   timeout,
+		createdAt: time.Now(),
+	}
+	s.usedAt.Store(s.createdAt.UnixNano())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	aioTxSessions.Store(id, s)
+	s.timer = time.AfterFunc(timeout, s.expire)
+	return s, nil
+}
+
+// expire 空闲超时后回滚事务，期间被使用过则顺延
+func (s *aioTxSession) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if idle := time.Since(time.Unix(0, s.usedAt.Load())); idle < s.timeout {
+		s.timer.Reset(s.timeout - idle)
+		return
+	}
+	s.close()
+	if err := s.tx.tx.Rollback(); err != nil {
+		s.tx.conn.logger.Errorf("Aio transaction %s rollback failed: %v", s.id, err)
+		return
+	}
+	s.tx.conn.logger.Warnf("Aio transaction %s rolled back after %s idle", s.id, s.timeout)
+}
+
+// close 移除会话，调用方需持有锁
+func (s *aioTxSession) close() {
+	s.closed = true
+	s.timer.Stop()
+	aioTxSessions.Delete(s.id)
+}
+
+func (s *aioTxSession) info() *AioTxInfo {
+	usedAt := time.Unix(0, s.usedAt.Load())
+	return &AioTxInfo{
+		TxID:           s.id,
+		Client:         s.client,
+		ConnectionName: s.tx.conn.name,
+		CreatedAt:      s.createdAt,
+		UsedAt:         usedAt,
+		ExpiresAt:      usedAt.Add(s.timeout),
+	}
+}
+
+// useAioTx 校验调用方后在事务会话中执行fn，同一会话的请求串行执行
+func useAioTx(client, id string, fn func(s *aioTxSession) error) error {
+	v, ok := aioTxSessions.Load(id)
+	if !ok {
+		return fmt.Errorf("dba: tx not exist or expired: %s", id)
+	}
+	s := v.(*aioTxSession)
+	if s.client != client {
+		return fmt.Errorf("dba: tx belongs to another client: %s", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("dba: tx not exist or expired: %s", id)
+	}
+	defer s.usedAt.Store(time.Now().UnixNano())
+	return fn(s)
+}
+
+// withAioTx id为空时直接执行fn（tx为nil），否则在对应的事务会话中执行
+func withAioTx(client, id string, fn func(tx *Tx) error) error {
+	if id == "" {
+		return fn(nil)
+	}
+	return useAioTx(client, id, func(s *aioTxSession) error {
+		return fn(s.tx)
+	})
+}
+
+// aioModel 返回绑定事务的模型，tx为nil时使用默认命名空间
+func aioModel(tx *Tx, name string, options *ModelOptions) *DataModel {
+	if tx != nil {
+		return tx.Model(name, options)
+	}
+	return Model(name, options)
+}
+
+func commitAioTx(client, id string) error {
+	return useAioTx(client, id, func(s *aioTxSession) error {
+		s.close()
+		return s.tx.tx.Commit()
+	})
+}
+
+func rollbackAioTx(client, id string) error {
+	return useAioTx(client, id, func(s *aioTxSession) error {
+		s.close()
+		return s.tx.tx.Rollback()
+	})
+}
+
+// AioTxList 返回当前所有的Aio事务会话，按创建时间排序
+func AioTxList() []*AioTxInfo {
+	var list []*AioTxInfo
+	aioTxSessions.Range(func(key, value any) bool {
+		list = append(list, value.(*aioTxSession).info())
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// ReleaseAioClient 回滚调用方未结束的全部事务会话，在调用方断开连接时使用
+func ReleaseAioClient(client string) {
+	aioTxSessions.Range(func(key, value any) bool {
+		s := value.(*aioTxSession)
+		if s.client == client {
+			if err := rollbackAioTx(client, s.id); err != nil {
+				s.tx.conn.logger.Errorf("Aio transaction %s rollback failed: %v", s.id, err)
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,150 @@
+package dba
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type AioTxItem struct {
+	ID   uint `dba:"pk;incr"`
+	Name string
+}
+
+// newAioTestConnection 在默认命名空间中连接SQLite数据库，供HandleAio使用
+func newAioTestConnection(t *testing.T) *Connection {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	conn, err := Connect(&ConnectConfig{Name: t.Name(), Driver: SQLite, Dsn: filepath.Join(t.TempDir(), "test.db"), Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		Disconnect(t.Name())
+		_ = UnregisterSchema("AioTxItem")
+	})
+	if err := RegisterSchema(&AioTxItem{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Init(SchemaBys("AioTxItem")); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func aio(t *testing.T, client, action string, data map[string]any) *AioReply {
+	t.Helper()
+	return HandleAio(&AioArgs{Action: action, Data: data, Client: client})
+}
+
+func TestAioTransaction(t *testing.T) {
+	conn := newAioTestConnection(t)
+	count := func() int {
+		t.Helper()
+		n, err := conn.ns.Model("AioTxItem", &ModelOptions{ConnectionName: conn.name}).Find().Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	reply := aio(t, "a", "tx_begin", map[string]any{"connection_name": conn.name})
+	if reply.Code != 0 {
+		t.Fatal(reply.Msg)
+	}
+	txID := reply.Data["tx_id"].(string)
+	if len(txID) != 32 {
+		t.Fatalf("unexpected tx id: %s", txID)
+	}
+
+	if reply := aio(t, "a", "exec", map[string]any{"tx_id": txID, "query": "INSERT INTO aio_tx_item (name) VALUES (?)", "args": []any{"x"}}); reply.Code != 0 {
+		t.Fatal(reply.Msg)
+	}
+	if reply := aio(t, "a", "model_create", map[string]any{"tx_id": txID, "model_name": "AioTxItem", "data": map[string]any{"Name": "y"}}); reply.Code != 0 {
+		t.Fatal(reply.Msg)
+	}
+	reply = aio(t, "a", "query", map[string]any{"tx_id": txID, "query": "SELECT COUNT(*) AS n FROM aio_tx_item"})
+	if reply.Code != 0 {
+		t.Fatal(reply.Msg)
+	}
+	if n := reply.Data["data"].(map[string]any)["n"]; n != int64(2) {
+		t.Fatalf("expected 2 rows inside transaction, got %v", n)
+	}
+
+	// 其他调用方不能使用该事务
+	if reply := aio(t, "b", "tx_commit", map[string]any{"tx_id": txID}); reply.Code == 0 {
+		t.Fatal("expected ownership error")
+	}
+
+	reply = aio(t, "a", "tx_list", nil)
+	if list := reply.Data["list"].([]*AioTxInfo); len(list) != 1 || list[0].TxID != txID || list[0].Client != "a" || list[0].ConnectionName != conn.name {
+		t.Fatalf("unexpected tx list: %+v", list)
+	}
+
+	if reply := aio(t, "a", "tx_commit", map[string]any{"tx_id": txID}); reply.Code != 0 {
+		t.Fatal(reply.Msg)
+	}
+	reply = aio(t, "b", "query", map[string]any{"connection_name": conn.name, "query": "SELECT name FROM aio_tx_item ORDER BY id", "is_list": true})
+	if rows := reply.Data["data"].([]map[string]any); len(rows) != 2 || rows[1]["name"] != "y" {
+		t.Fatalf("expected 2 committed rows, got %v", rows)
+	}
+	// 提交后会话被移除
+	if reply := aio(t, "a", "tx_rollback", map[string]any{"tx_id": txID}); reply.Code == 0 {
+		t.Fatal("expected error for finished transaction")
+	}
+	if list := AioTxList(); len(list) != 0 {
+		t.Fatalf("expected no sessions, got %+v", list)
+	}
+
+	reply = aio(t, "a", "tx_begin", map[string]any{"connection_name": conn.name})
+	txID = reply.Data["tx_id"].(string)
+	if reply := aio(t, "a", "model_delete", map[string]any{"tx_id": txID, "model_name": "AioTxItem", "filters": []any{"Name", "x"}}); reply.Code != 0 {
+		t.Fatal(reply.Msg)
+	}
+	if reply := aio(t, "a", "tx_rollback", map[string]any{"tx_id": txID}); reply.Code != 0 {
+		t.Fatal(reply.Msg)
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("expected rollback, got %d rows", n)
+	}
+
+	// 调用方断开时回滚其事务
+	reply = aio(t, "a", "tx_begin", map[string]any{"connection_name": conn.name})
+	txID = reply.Data["tx_id"].(string)
+	aio(t, "a", "exec", map[string]any{"tx_id": txID, "query": "DELETE FROM aio_tx_item"})
+	ReleaseAioClient("a")
+	if list := AioTxList(); len(list) != 0 {
+		t.Fatalf("expected released sessions, got %+v", list)
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("expected rollback on release, got %d rows", n)
+	}
+}
+
+func TestAioTransactionTimeout(t *testing.T) {
+	conn := newAioTestConnection(t)
+	s, err := beginAioTx("a", conn.name, 50*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply := aio(t, "a", "exec", map[string]any{"tx_id": s.id, "query": "INSERT INTO aio_tx_item (name) VALUES ('x')"}); reply.Code != 0 {
+		t.Fatal(reply.Msg)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(AioTxList()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("transaction did not expire")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if reply := aio(t, "a", "tx_commit", map[string]any{"tx_id": s.id}); reply.Code == 0 {
+		t.Fatal("expected error for expired transaction")
+	}
+	if n, err := conn.ns.Model("AioTxItem", &ModelOptions{ConnectionName: conn.name}).Find().Count(); err != nil || n != 0 {
+		t.Fatalf("expected expired transaction to be rolled back, got %d, %v", n, err)
+	}
+}
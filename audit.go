@@ -0,0 +1,115 @@
+package dba
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// AuditKind 审计字段类型
+type AuditKind string
+
+const (
+	AuditCreatedAt AuditKind = "created_at"
+	AuditUpdatedAt AuditKind = "updated_at"
+	AuditCreatedBy AuditKind = "created_by"
+	AuditUpdatedBy AuditKind = "updated_by"
+	AuditDeletedBy AuditKind = "deleted_by"
+)
+
+// 操作人属性，用于created_by=account等标签值
+const (
+	ActorAttrID      = "id"
+	ActorAttrAccount = "account"
+	ActorAttrName    = "name"
+	ActorAttrOrg     = "org"
+)
+
+// Actor 当前操作人
+type Actor struct {
+	ID      any    `json:"id,omitempty"`
+	Account string `json:"account,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Org     any    `json:"org,omitempty"`
+}
+
+// Attr 按属性名获取操作人信息，未知属性返回nil
+func (a *Actor) Attr(name string) any {
+	if a == nil {
+		return nil
+	}
+	switch name {
+	case ActorAttrAccount:
+		return a.Account
+	case ActorAttrName:
+		return a.Name
+	case ActorAttrOrg:
+		return a.Org
+	case ActorAttrID:
+		return a.ID
+	default:
+		return nil
+	}
+}
+
+// isActorAttr 判断是否为支持的操作人属性
+func isActorAttr(name string) bool {
+	switch name {
+	case ActorAttrID, ActorAttrAccount, ActorAttrName, ActorAttrOrg:
+		return true
+	}
+	return false
+}
+
+// ActorProvider 从上下文中获取当前操作人
+type ActorProvider func(ctx context.Context) *Actor
+
+type actorContextKey struct{}
+
+// WithActor 将操作人写入上下文
+func WithActor(ctx context.Context, actor *Actor) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext 读取WithActor写入的操作人，不存在时返回nil
+func ActorFromContext(ctx context.Context) *Actor {
+	if ctx == nil {
+		return nil
+	}
+	actor, _ := ctx.Value(actorContextKey{}).(*Actor)
+	return actor
+}
+
+// auditValues 计算指定类型审计字段的写入值，无操作人时跳过操作人字段
+func (dm *DataModel) auditValues(ctx context.Context, kinds ...AuditKind) map[string]any {
+	var (
+		values = make(map[string]any)
+		now    = time.Now()
+		actor  *Actor
+	)
+	for _, f := range dm.schema.Fields {
+		if f.Audit == "" || !lo.Contains(kinds, f.Audit) {
+			continue
+		}
+		switch f.Audit {
+		case AuditCreatedAt, AuditUpdatedAt:
+			if f.Type == Integer {
+				values[f.Name] = now.Unix()
+			} else {
+				values[f.Name] = now
+			}
+		default:
+			if actor == nil {
+				actor = dm.conn.ns.actor(ctx)
+			}
+			if v := actor.Attr(f.AuditActorAttr); !IsNilOrZero(v) {
+				values[f.Name] = v
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,94 @@
+package dba
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type AuditItem struct {
+	ID        uint `dba:"pk;incr"`
+	Name      string
+	CreatedAt time.Time  `dba:"created_at"`
+	UpdatedAt int64      `dba:"updated_at"`
+	CreatedBy string     `dba:"created_by=account"`
+	UpdatedBy *int       `dba:"updated_by"`
+	DeletedBy *string    `dba:"deleted_by=name"`
+	DeletedAt *time.Time `dba:"soft_delete"`
+}
+
+func TestAuditFields(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &AuditItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	model := func() *DataModel { return conn.ns.Model("AuditItem") }
+	load := func(name string) *AuditItem {
+		t.Helper()
+		var item AuditItem
+		if err := model().Find("Name", name).WithDeleted().One(&item); err != nil {
+			t.Fatal(err)
+		}
+		return &item
+	}
+	alice := WithActor(context.Background(), &Actor{ID: 1, Account: "alice", Name: "Alice"})
+	bob := WithActor(context.Background(), &Actor{ID: 2, Account: "bob", Name: "Bob"})
+
+	start := time.Now().Add(-time.Second)
+	item := &AuditItem{Name: "a"}
+	if err := model().CreateContext(alice, item); err != nil {
+		t.Fatal(err)
+	}
+	// 填充值回写到传入数据
+	if item.CreatedAt.Before(start) || item.UpdatedAt < start.Unix() || item.CreatedBy != "alice" || item.UpdatedBy == nil || *item.UpdatedBy != 1 {
+		t.Fatalf("unexpected created item: %+v", item)
+	}
+	if stored := load("a"); !stored.CreatedAt.Equal(item.CreatedAt) || stored.CreatedBy != "alice" || *stored.UpdatedBy != 1 || stored.DeletedBy != nil {
+		t.Fatalf("unexpected stored item: %+v", stored)
+	}
+	// 显式赋值的审计字段不被覆盖
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := model().CreateContext(alice, &AuditItem{Name: "b", CreatedAt: created, CreatedBy: "carol"}); err != nil {
+		t.Fatal(err)
+	}
+	if stored := load("b"); !stored.CreatedAt.Equal(created) || stored.CreatedBy != "carol" {
+		t.Fatalf("expected explicit values kept: %+v", stored)
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ns.Model("AuditItem", &ModelOptions{Tx: tx}).Find("Name", "a").UpdateContext(bob, map[string]any{"Name": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if stored := load("a"); stored.CreatedBy != "alice" || *stored.UpdatedBy != 2 {
+		t.Fatalf("unexpected updated item: %+v", stored)
+	}
+	if _, err := model().Find("Name", "a").DeleteContext(bob); err != nil {
+		t.Fatal(err)
+	}
+	if stored := load("a"); stored.DeletedBy == nil || *stored.DeletedBy != "Bob" || stored.DeletedAt == nil {
+		t.Fatalf("unexpected deleted item: %+v", stored)
+	}
+	if _, err := model().Find("Name", "a").Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if stored := load("a"); stored.DeletedBy != nil || stored.DeletedAt != nil {
+		t.Fatalf("unexpected restored item: %+v", stored)
+	}
+
+	// 自定义操作人来源
+	conn.ns.SetActorProvider(func(ctx context.Context) *Actor {
+		return &Actor{ID: 3, Account: "system"}
+	})
+	if err := model().Create(&AuditItem{Name: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if stored := load("c"); stored.CreatedBy != "system" || *stored.UpdatedBy != 3 {
+		t.Fatalf("unexpected provider actor: %+v", stored)
+	}
+}
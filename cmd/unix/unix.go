@@ -96,6 +96,10 @@ func (s *Server) handleConnection(conn net.Conn) {
 
 	log.Println("建立新连接")
 
+	// 每个连接作为独立的调用方，断开时回滚其未结束的事务
+	client := dba.NewUUIDToken()
+	defer dba.ReleaseAioClient(client)
+
 	reader := bufio.NewReader(conn)
 	writer := bufio.NewWriter(conn)
 
@@ -140,6 +144,7 @@ func (s *Server) handleConnection(conn net.Conn) {
 		}
 
 		log.Printf("收到消息 %+v\n", msg)
+		msg.Client = client
 
 		response := dba.HandleAio(&msg)
 
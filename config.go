@@ -0,0 +1,340 @@
+package dba
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigWatchInterval 配置文件热加载的默认检查间隔
+var DefaultConfigWatchInterval = 5 * time.Second
+
+type LoadConfigOptions struct {
+	EnvFiles      []string       // 加载配置前读取的.env文件，不覆盖已有的环境变量
+	Watch         bool           // 配置变化时重新加载连接（热加载），直到上下文结束
+	WatchInterval time.Duration  // 热加载的检查间隔，默认DefaultConfigWatchInterval
+	Logger        *logrus.Logger // 连接及热加载使用的日志，默认使用全局日志
+}
+
+// configFile 配置文件结构：defaults为各连接的默认配置（如连接池、默认子句），连接中的同名配置优先
+type configFile struct {
+	Defaults    map[string]any   `json:"defaults"`
+	Connections []map[string]any `json:"connections"`
+	Schemas     []map[string]any `json:"schemas"`
+}
+
+// loadedConfig 已加载的配置指纹，热加载时只处理变化的部分
+type loadedConfig struct {
+	connections map[string]string // 连接名称 -> 连接配置指纹
+	schemas     string
+}
+
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?}`)
+
+// LoadConfig 从YAML、JSON或TOML文件加载命名连接及模型，详见LoadConfigContext
+func (ns *Namespace) LoadConfig(path string, options ...*LoadConfigOptions) error {
+	return ns.LoadConfigContext(context.Background(), path, options...)
+}
+
+// LoadConfigContext 从YAML、JSON或TOML文件（按扩展名识别）加载命名连接及模型。
+// 字符串值中的${ENV}、${ENV:-默认值}替换为环境变量，变量未设置时读取ENV_FILE指向的文件；
+// 以_file结尾的配置（如dsn_file）读取文件内容作为对应配置（dsn）的值。
+// 开启Watch时定期检查配置，新增或变化的连接重新连接，从配置中移除的连接断开，直到ctx结束
+func (ns *Namespace) LoadConfigContext(ctx context.Context, path string, options ...*LoadConfigOptions) error {
+	var opts LoadConfigOptions
+	if len(options) > 0 && options[0] != nil {
+		opts = *options[0]
+	}
+	if opts.Logger == nil {
+		opts.Logger = globalLogger
+	}
+	if len(opts.EnvFiles) > 0 {
+		if err := godotenv.Load(opts.EnvFiles...); err != nil {
+			return fmt.Errorf("dba: load env files failed: %w", err)
+		}
+	}
+	loaded, err := ns.applyConfig(path, nil, &opts)
+	if err != nil {
+		return err
+	}
+	if opts.Watch {
+		interval := opts.WatchInterval
+		if interval <= 0 {
+			interval = DefaultConfigWatchInterval
+		}
+		go ns.watchConfig(ctx, path, loaded, interval, &opts)
+	}
+	return nil
+}
+
+func (ns *Namespace) watchConfig(ctx context.Context, path string, loaded *loadedConfig, interval time.Duration, opts *LoadConfigOptions) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			next, err := ns.applyConfig(path, loaded, opts)
+			if err != nil {
+				opts.Logger.Errorf("Reload config %s failed: %v", path, err)
+			}
+			loaded = next
+		}
+	}
+}
+
+// applyConfig 读取配置并连接新增或变化的连接，断开已从配置中移除的连接。
+// loaded为上次加载的结果（首次加载时为nil），返回本次加载的结果；连接失败时保留原连接并返回错误
+func (ns *Namespace) applyConfig(path string, loaded *loadedConfig, opts *LoadConfigOptions) (*loadedConfig, error) {
+	if loaded == nil {
+		loaded = &loadedConfig{}
+	}
+	cfg, err := readConfigFile(path)
+	if err != nil {
+		return loaded, err
+	}
+	configs := make(map[string]*ConnectConfig)
+	fingerprints := make(map[string]string)
+	var names []string
+	for i, item := range cfg.Connections {
+		merged := make(map[string]any)
+		for k, v := range cfg.Defaults {
+			merged[k] = v
+		}
+		for k, v := range item {
+			merged[k] = v
+		}
+		if err := resolveSecretFiles(merged); err != nil {
+			return loaded, err
+		}
+		if err := normalizeValues(merged); err != nil {
+			return loaded, err
+		}
+		var config ConnectConfig
+		if err := ConvertData(merged, &config); err != nil {
+			return loaded, err
+		}
+		if config.Name == "" {
+			return loaded, fmt.Errorf("dba: missing connection name: connections[%d]", i)
+		}
+		if _, ok := configs[config.Name]; ok {
+			return loaded, fmt.Errorf("dba: duplicate connection name: %s", config.Name)
+		}
+		// CursorSecret不参与JSON序列化，单独读取
+		if v, ok := merged["cursor_secret"].(string); ok {
+			config.CursorSecret = v
+		}
+		config.Logger = opts.Logger
+		data, _ := json.Marshal(merged)
+		configs[config.Name] = &config
+		fingerprints[config.Name] = string(data)
+		names = append(names, config.Name)
+	}
+	next := &loadedConfig{connections: make(map[string]string), schemas: loaded.schemas}
+	if data, _ := json.Marshal(cfg.Schemas); len(cfg.Schemas) > 0 && string(data) != loaded.schemas {
+		schemas := make([]any, len(cfg.Schemas))
+		for i, item := range cfg.Schemas {
+			schemas[i] = item
+		}
+		if err := ns.RegisterSchema(schemas...); err != nil {
+			return loaded, err
+		}
+		next.schemas = string(data)
+	}
+
+	var errs []string
+	for _, name := range names {
+		old, ok := loaded.connections[name]
+		if ok && old == fingerprints[name] && ns.Session(name) != nil {
+			next.connections[name] = old
+			continue
+		}
+		if _, err := ns.Connect(configs[name]); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			if ok {
+				next.connections[name] = old
+			}
+			continue
+		}
+		if ok {
+			opts.Logger.Infof("Connection %s reloaded from %s", name, path)
+		}
+		next.connections[name] = fingerprints[name]
+	}
+	for name := range loaded.connections {
+		if _, ok := configs[name]; !ok {
+			ns.Disconnect(name)
+			opts.Logger.Infof("Connection %s removed by %s", name, path)
+		}
+	}
+	if len(errs) > 0 {
+		return next, fmt.Errorf("dba: connect failed: %s", strings.Join(errs, "; "))
+	}
+	return next, nil
+}
+
+// readConfigFile 按扩展名解析配置文件并替换环境变量
+func readConfigFile(path string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// 文件正在写入时可能读到空内容，不能视为删除全部连接
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("dba: empty config: %s", path)
+	}
+	var raw map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("dba: unsupported config format: %s", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dba: parse config %s failed: %w", path, err)
+	}
+	expanded, err := expandEnv(raw)
+	if err != nil {
+		return nil, err
+	}
+	var cfg configFile
+	if err := ConvertData(expanded, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// expandEnv 递归替换字符串中的${ENV}及${ENV:-默认值}
+func expandEnv(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		var missing []string
+		s := envPattern.ReplaceAllStringFunc(v, func(m string) string {
+			sub := envPattern.FindStringSubmatch(m)
+			if val, ok := lookupEnv(sub[1]); ok {
+				return val
+			}
+			if sub[2] != "" {
+				return sub[3]
+			}
+			missing = append(missing, sub[1])
+			return m
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("dba: environment variable not set: %s", strings.Join(missing, ", "))
+		}
+		return s, nil
+	case map[string]any:
+		for k, item := range v {
+			expanded, err := expandEnv(item)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = expanded
+		}
+	case []any:
+		for i, item := range v {
+			expanded, err := expandEnv(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+	case []map[string]any:
+		for _, item := range v {
+			if _, err := expandEnv(item); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return value, nil
+}
+
+// lookupEnv 读取环境变量，未设置时读取NAME_FILE指向的文件
+func lookupEnv(name string) (string, bool) {
+	if val, ok := os.LookupEnv(name); ok {
+		return val, true
+	}
+	if file, ok := os.LookupEnv(name + "_FILE"); ok {
+		if data, err := os.ReadFile(file); err == nil {
+			return strings.TrimRight(string(data), "\r\n"), true
+		}
+	}
+	return "", false
+}
+
+// resolveSecretFiles 将以_file结尾的配置替换为文件内容，如dsn_file设置dsn
+func resolveSecretFiles(config map[string]any) error {
+	for k, v := range config {
+		key, ok := strings.CutSuffix(k, "_file")
+		if !ok {
+			continue
+		}
+		file, ok := v.(string)
+		if !ok || file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("dba: read %s failed: %w", k, err)
+		}
+		delete(config, k)
+		config[key] = strings.TrimRight(string(data), "\r\n")
+	}
+	return nil
+}
+
+// normalizeValues 按ConnectConfig的字段类型转换字符串形式的配置（如环境变量替换后的"true"、"10"），
+// 时长支持"30s"、"5m"等写法
+func normalizeValues(config map[string]any) error {
+	t := reflect.TypeOf(ConnectConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		s, ok := config[key].(string)
+		if !ok || key == "" || key == "-" {
+			continue
+		}
+		var (
+			value any
+			err   error
+		)
+		switch {
+		case field.Type == reflect.TypeOf(time.Duration(0)):
+			var d time.Duration
+			d, err = time.ParseDuration(s)
+			value = int64(d)
+		case field.Type.Kind() == reflect.Bool:
+			value, err = strconv.ParseBool(s)
+		case field.Type.Kind() == reflect.Int:
+			value, err = strconv.Atoi(s)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("dba: invalid %s: %s", key, s)
+		}
+		config[key] = value
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package dba
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newConfigTestNamespace(t *testing.T) (*Namespace, *LoadConfigOptions) {
+	t.Helper()
+	ns := &Namespace{Name: t.Name(), connections: new(sync.Map), schemas: new(sync.Map)}
+	t.Cleanup(ns.DisconnectAll)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return ns, &LoadConfigOptions{Logger: logger}
+}
+
+// writeFile 先写入临时文件再重命名，避免热加载读到写入一半的配置
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DBA_TEST_DIR", dir)
+	writeFile(t, filepath.Join(dir, "dsn.secret"), filepath.Join(dir, "b.db")+"\n")
+	writeFile(t, filepath.Join(dir, "cursor.secret"), "s3cret")
+	t.Setenv("DBA_TEST_CURSOR_SECRET_FILE", filepath.Join(dir, "cursor.secret"))
+	path := filepath.Join(dir, "dba.yaml")
+	writeFile(t, path, `
+defaults:
+  driver: sqlite
+  max_open_conns: 4
+  conn_max_lifetime: 1m
+connections:
+  - name: a
+    dsn: ${DBA_TEST_DIR}/a.db
+    strict: ${DBA_TEST_STRICT:-true}
+    cursor_secret: ${DBA_TEST_CURSOR_SECRET}
+  - name: b
+    dsn_file: ${DBA_TEST_DIR}/dsn.secret
+    max_open_conns: 2
+schemas:
+  - name: CfgItem
+    native_name: cfg_item
+    fields:
+      ID: {name: ID, native_name: id, type: int, is_primary: true}
+      Name: {name: Name, native_name: name, type: string}
+`)
+	ns, opts := newConfigTestNamespace(t)
+	if err := ns.LoadConfig(path, opts); err != nil {
+		t.Fatal(err)
+	}
+	a, b := ns.Session("a"), ns.Session("b")
+	if a == nil || b == nil {
+		t.Fatalf("expected connections a and b, got %v", ns.ConnectionNames())
+	}
+	if a.DSN() != filepath.Join(dir, "a.db") || b.DSN() != filepath.Join(dir, "b.db") {
+		t.Fatalf("unexpected dsn: %s, %s", a.DSN(), b.DSN())
+	}
+	if !a.strict || string(a.cursorSecret) != "s3cret" {
+		t.Fatalf("unexpected connection options: strict=%v secret=%q", a.strict, a.cursorSecret)
+	}
+	if a.Stats().MaxOpenConnections != 4 || b.Stats().MaxOpenConnections != 2 {
+		t.Fatalf("unexpected pool settings: %d, %d", a.Stats().MaxOpenConnections, b.Stats().MaxOpenConnections)
+	}
+	if err := a.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.Model("CfgItem", &ModelOptions{ConnectionName: "a"}).Create(map[string]any{"ID": 1, "Name": "x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, path, "connections:\n  - name: c\n    driver: sqlite\n    dsn: ${DBA_TEST_MISSING}\n")
+	if err := ns.LoadConfig(path, opts); err == nil {
+		t.Fatal("expected error for missing environment variable")
+	}
+	if err := ns.LoadConfig(filepath.Join(dir, "dba.ini"), opts); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DBA_TEST_DIR", dir)
+	for name, content := range map[string]string{
+		"dba.json": `{"connections": [{"name": "json", "driver": "sqlite", "dsn": "${DBA_TEST_DIR}/json.db", "conn_max_idle_time": "30s"}]}`,
+		"dba.toml": "[[connections]]\nname = \"toml\"\ndriver = \"sqlite\"\ndsn = \"${DBA_TEST_DIR}/toml.db\"\nmax_open_conns = 3\n",
+	} {
+		path := filepath.Join(dir, name)
+		writeFile(t, path, content)
+		ns, opts := newConfigTestNamespace(t)
+		if err := ns.LoadConfig(path, opts); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if names := ns.ConnectionNames(); len(names) != 1 || ns.Session(names[0]).DSN() != filepath.Join(dir, names[0]+".db") {
+			t.Fatalf("%s: unexpected connections %v", name, names)
+		}
+	}
+}
+
+func TestLoadConfigWatch(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DBA_TEST_DIR", dir)
+	path := filepath.Join(dir, "dba.yaml")
+	writeFile(t, path, `
+connections:
+  - {name: a, driver: sqlite, dsn: "${DBA_TEST_DIR}/a.db"}
+  - {name: b, driver: sqlite, dsn: "${DBA_TEST_DIR}/b.db"}
+`)
+	ns, opts := newConfigTestNamespace(t)
+	opts.Watch = true
+	opts.WatchInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := ns.LoadConfigContext(ctx, path, opts); err != nil {
+		t.Fatal(err)
+	}
+	a := ns.Session("a")
+
+	// 连接a不变，移除b，新增c
+	writeFile(t, path, `
+connections:
+  - {name: a, driver: sqlite, dsn: "${DBA_TEST_DIR}/a.db"}
+  - {name: c, driver: sqlite, dsn: "${DBA_TEST_DIR}/c.db", max_open_conns: 5}
+`)
+	deadline := time.Now().Add(2 * time.Second)
+	for ns.Session("c") == nil || ns.Session("b") != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("config not reloaded: %v", ns.ConnectionNames())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ns.Session("a") != a {
+		t.Fatal("unchanged connection should not be reconnected")
+	}
+	if ns.Session("c").Stats().MaxOpenConnections != 5 {
+		t.Fatal("expected pool settings on reloaded connection")
+	}
+
+	// 变化的连接重新连接，原连接被关闭
+	writeFile(t, path, `
+connections:
+  - {name: a, driver: sqlite, dsn: "${DBA_TEST_DIR}/a2.db"}
+  - {name: c, driver: sqlite, dsn: "${DBA_TEST_DIR}/c.db", max_open_conns: 5}
+`)
+	deadline = time.Now().Add(2 * time.Second)
+	for ns.Session("a").DSN() != filepath.Join(dir, "a2.db") {
+		if time.Now().After(deadline) {
+			t.Fatal("changed connection not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := a.Exec("SELECT 1"); err == nil {
+		t.Fatal("expected replaced connection to be closed")
+	}
+}
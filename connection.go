@@ -1,11 +1,13 @@
 package dba
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"regexp"
-	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/jmoiron/sqlx"
@@ -18,11 +20,17 @@ type Connection struct {
 	dsn            string
 	name           string
 	xdb            *sqlx.DB
+	strict         bool
 	logger         *logrus.Logger
 	CreateTemplate *template.Template
 	UpdateTemplate *template.Template
 	DeleteTemplate *template.Template
 	QueryTemplate  *template.Template
+	cursorSecret   []byte
+	replicas       []*Replica
+	balancer       ReplicaBalancer
+	replicaStop    chan struct{}
+	replicaOnce    sync.Once
 }
 
 func (c *Connection) Name() string {
@@ -37,46 +45,154 @@ func (c *Connection) DSN() string {
 	return c.dsn
 }
 
+// Stats 返回主库的连接池统计
+func (c *Connection) Stats() sql.DBStats {
+	return c.xdb.Stats()
+}
+
+// Close 停止副本健康检查并关闭主库及副本的连接池
+func (c *Connection) Close() error {
+	c.closeReplicas()
+	return c.xdb.Close()
+}
+
 func (c *Connection) Begin() (*sqlx.Tx, error) {
 	return c.xdb.Beginx()
 }
 
+func (c *Connection) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	return c.xdb.BeginTxx(ctx, opts)
+}
+
+type InitOptions struct {
+	Schemas    map[string]*Schema // 未指定时使用命名空间下的全部模型
+	DryRun     bool               // 仅计算差异，不执行变更语句
+	AllowAlter bool               // 执行修改列、新增非空列、重建索引及外键、重建表等改写已有结构或数据的语句（默认只输出不执行）
+	AllowDrop  bool               // 执行删除列、索引及外键的语句（默认只输出不执行）
+}
+
+// Init 创建缺失的表，并新增缺失的可空列及索引，修改或删除已有结构需通过InitBy显式允许
 func (c *Connection) Init(schs ...map[string]*Schema) error {
+	return c.InitContext(context.Background(), schs...)
+}
+
+func (c *Connection) InitContext(ctx context.Context, schs ...map[string]*Schema) error {
 	var ss map[string]*Schema
 	if len(schs) > 0 {
 		ss = schs[0]
 	}
-	if ss == nil {
-		ss = c.ns.SchemaBys()
+	_, err := c.InitByContext(ctx, &InitOptions{Schemas: ss})
+	return err
+}
+
+func (c *Connection) InitBy(options ...*InitOptions) (*SchemaDiff, error) {
+	return c.InitByContext(context.Background(), options...)
+}
+
+// InitByContext 比较模型与数据库结构并按选项执行变更语句，返回计算出的差异
+func (c *Connection) InitByContext(ctx context.Context, options ...*InitOptions) (*SchemaDiff, error) {
+	opts := new(InitOptions)
+	if len(options) > 0 && options[0] != nil {
+		opts = options[0]
+	}
+	diff, err := c.DiffContext(ctx, opts.Schemas)
+	if err != nil {
+		return nil, err
+	}
+	stmts := diff.Statements(opts.AllowAlter, opts.AllowDrop)
+	if opts.DryRun || len(stmts) == 0 {
+		return diff, nil
+	}
+	if err := c.execDDL(ctx, stmts); err != nil {
+		c.logger.Errorf("Init failed: %v", err)
+		return diff, err
+	}
+	c.logger.WithField("statements", stmts).Infof("Init successful")
+	return diff, nil
+}
+
+// execDDL 在同一连接的事务中依次执行结构变更语句。
+// SQLite重建表时删除原表会触发外键级联动作，且事务内无法修改外键开关，
+// 因此在开启事务前关闭外键约束，提交前通过foreign_key_check确认数据仍满足外键约束
+func (c *Connection) execDDL(ctx context.Context, stmts []string) (err error) {
+	conn, err := c.xdb.Connx(ctx)
+	if err != nil {
+		return err
 	}
-	ddl := c.GenDDL(ss)
-	if ddl != "" {
-		_, err := c.BatchExec(ddl)
+	defer func() {
+		_ = conn.Close()
+	}()
+	var checkForeignKeys bool
+	if c.driver.Name() == SQLite {
+		if err := conn.GetContext(ctx, &checkForeignKeys, "PRAGMA foreign_keys"); err != nil {
+			return err
+		}
+		if checkForeignKeys {
+			if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+				return err
+			}
+			defer func() {
+				if _, e := conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON"); e != nil && err == nil {
+					err = e
+				}
+			}()
+		}
+	}
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
 		return err
 	}
-	return nil
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			_ = tx.Rollback()
+			c.logger.WithField("sql", stmt).Errorf("Exec DDL failed: %v", err)
+			return err
+		}
+	}
+	if checkForeignKeys {
+		var violations []struct {
+			Table  string        `db:"table"`
+			RowID  sql.NullInt64 `db:"rowid"`
+			Parent string        `db:"parent"`
+			FkID   int           `db:"fkid"`
+		}
+		if err := tx.SelectContext(ctx, &violations, "PRAGMA foreign_key_check"); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if len(violations) > 0 {
+			_ = tx.Rollback()
+			v := violations[0]
+			return fmt.Errorf("dba: foreign key check failed: %s (rowid %d) references missing %s row", v.Table, v.RowID.Int64, v.Parent)
+		}
+	}
+	return tx.Commit()
 }
+
 func (c *Connection) Namespace() *Namespace {
 	return c.ns
 }
 
 func (c *Connection) GenDDL(schs map[string]*Schema, ignoreComments ...bool) string {
-	var sortedNames []string
-	for name := range schs {
-		sortedNames = append(sortedNames, name)
-	}
-	sort.Strings(sortedNames)
-	return c.driver.GenDDL(sortedNames, schs, ignoreComments...)
+	return c.driver.GenDDL(sortSchemaNames(schs), schs, ignoreComments...)
 }
 
 func (c *Connection) Query(dst any, query string, args ...any) error {
-	query = formatSQL(query)
-	return autoScan(dst, c.xdb, query, args)
+	return c.QueryContext(context.Background(), dst, query, args...)
+}
+
+func (c *Connection) QueryContext(ctx context.Context, dst any, query string, args ...any) error {
+	query = c.Rebind(formatSQL(query))
+	return autoScan(ctx, dst, c.xdb, query, args)
 }
 
 func (c *Connection) Exec(query string, args ...any) (int, error) {
-	query = formatSQL(query)
-	r, err := c.xdb.Exec(query, args...)
+	return c.ExecContext(context.Background(), query, args...)
+}
+
+func (c *Connection) ExecContext(ctx context.Context, query string, args ...any) (int, error) {
+	query = c.Rebind(formatSQL(query))
+	r, err := c.xdb.ExecContext(ctx, query, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -86,32 +202,41 @@ func (c *Connection) Exec(query string, args ...any) (int, error) {
 }
 
 func (c *Connection) BatchExec(query string, args ...any) ([]int, error) {
-	query = formatSQL(query)
-	tx, err := c.xdb.Begin()
+	return c.BatchExecContext(context.Background(), query, args...)
+}
 
-	var results []int
+func (c *Connection) BatchExecContext(ctx context.Context, query string, args ...any) (results []int, err error) {
+	tx, err := c.xdb.BeginTxx(ctx, nil)
 	if err != nil {
 		return results, err
 	}
 	defer func() {
 		if err != nil {
-			err = tx.Rollback()
+			_ = tx.Rollback()
 		} else {
 			err = tx.Commit()
 		}
 	}()
-	var res sql.Result
+	return c.batchExecTx(ctx, tx, query, args...)
+}
+
+// batchExecTx 在指定事务中依次执行以分号分隔的多条语句
+func (c *Connection) batchExecTx(ctx context.Context, tx *sqlx.Tx, query string, args ...any) ([]int, error) {
+	var results []int
+	query = formatSQL(query)
 	sqlStatements := strings.Split(query, ";")
 	paramIndex := 0
 	for _, stmt := range sqlStatements {
 		stmt = strings.TrimSpace(stmt)
 		if stmt != "" {
-			stmtParams, newIndex, err := extractParams(stmt, args, paramIndex)
+			// 每条语句单独重绑定，保证PostgreSQL的$n从1开始编号
+			stmt = c.Rebind(stmt)
+			stmtParams, newIndex, err := extractParams(c.driver.BindType(), stmt, args, paramIndex)
 			if err != nil {
 				return results, err
 			}
 
-			res, err = tx.Exec(stmt, stmtParams...)
+			res, err := tx.ExecContext(ctx, stmt, stmtParams...)
 			if err != nil {
 				return results, err
 			}
@@ -123,10 +248,34 @@ func (c *Connection) BatchExec(query string, args ...any) ([]int, error) {
 	return results, nil
 }
 
-func extractParams(stmt string, params []any, paramIndex int) ([]any, int, error) {
-	placeholderPattern := regexp.MustCompile(`\?`)
-	placeholders := placeholderPattern.FindAllStringIndex(stmt, -1)
-	numPlaceholders := len(placeholders)
+// QuoteIdentifier 按当前驱动转义表名、列名等标识符
+func (c *Connection) QuoteIdentifier(name string) string {
+	return c.driver.QuoteIdentifier(name)
+}
+
+// Rebind 将查询中的?占位符转换为当前驱动的占位符风格
+func (c *Connection) Rebind(query string) string {
+	return sqlx.Rebind(c.driver.BindType(), query)
+}
+
+var (
+	questionPlaceholderPattern = regexp.MustCompile(`\?`)
+	dollarPlaceholderPattern   = regexp.MustCompile(`\$(\d+)`)
+)
+
+func extractParams(bindType int, stmt string, params []any, paramIndex int) ([]any, int, error) {
+	var numPlaceholders int
+	switch bindType {
+	case sqlx.DOLLAR:
+		// $n可重复引用，参数个数取最大序号
+		for _, match := range dollarPlaceholderPattern.FindAllStringSubmatch(stmt, -1) {
+			if n, err := strconv.Atoi(match[1]); err == nil && n > numPlaceholders {
+				numPlaceholders = n
+			}
+		}
+	default:
+		numPlaceholders = len(questionPlaceholderPattern.FindAllStringIndex(stmt, -1))
+	}
 
 	// 检查参数是否足够
 	if paramIndex+numPlaceholders > len(params) {
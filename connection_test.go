@@ -0,0 +1,148 @@
+package dba
+
+import (
+	"database/sql"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestConnectionPool(t *testing.T) {
+	dir := t.TempDir()
+	ns := &Namespace{Name: t.Name(), connections: new(sync.Map), schemas: new(sync.Map)}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	conn, err := ns.Connect(&ConnectConfig{
+		Name:            "pool",
+		Driver:          SQLite,
+		Dsn:             filepath.Join(dir, "primary.db"),
+		Replicas:        []string{filepath.Join(dir, "replica.db")},
+		Logger:          logger,
+		MaxOpenConns:    3,
+		MaxIdleConns:    -1,
+		ConnMaxLifetime: time.Minute,
+		ConnMaxIdleTime: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ns.DisconnectAll)
+	if stats := conn.Stats(); stats.MaxOpenConnections != 3 {
+		t.Fatalf("expected max open connections 3, got %d", stats.MaxOpenConnections)
+	}
+	if stats := conn.Replicas()[0].Stats(); stats.MaxOpenConnections != 3 {
+		t.Fatalf("expected pool settings on replica, got %d", stats.MaxOpenConnections)
+	}
+	// 不保留空闲连接
+	if _, err := conn.Exec("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := conn.Stats(); stats.Idle != 0 || stats.OpenConnections != 0 {
+		t.Fatalf("expected no idle connections, got %+v", stats)
+	}
+
+	// 断开连接时关闭连接池
+	ns.Disconnect("pool")
+	if _, err := conn.Exec("SELECT 1"); err == nil {
+		t.Fatal("expected closed connection pool")
+	}
+	if _, err := conn.Replicas()[0].xdb.Exec("SELECT 1"); err == nil {
+		t.Fatal("expected closed replica pool")
+	}
+}
+
+func TestConnectionReplaceCloses(t *testing.T) {
+	ns := &Namespace{Name: t.Name(), connections: new(sync.Map), schemas: new(sync.Map)}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	config := &ConnectConfig{Name: "a", Driver: SQLite, Dsn: filepath.Join(t.TempDir(), "test.db"), Logger: logger}
+	old, err := ns.Connect(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ns.DisconnectAll)
+	if _, err := ns.Connect(config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.Exec("SELECT 1"); err == nil {
+		t.Fatal("expected replaced connection to be closed")
+	}
+}
+
+func TestAioConnectionStats(t *testing.T) {
+	conn := newAioTestConnection(t)
+	reply := HandleAio(&AioArgs{Action: "connection_stats", Data: map[string]any{"names": []string{conn.name}}})
+	if reply.Code != 0 {
+		t.Fatal(reply.Msg)
+	}
+	stats := reply.Data["stats"].(map[string]sql.DBStats)
+	if _, ok := stats[conn.name]; !ok || len(stats) != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if reply := HandleAio(&AioArgs{Action: "connection_stats", Data: map[string]any{"names": []string{"missing"}}}); reply.Code == 0 {
+		t.Fatal("expected error for unknown connection")
+	}
+}
+
+func TestRebind(t *testing.T) {
+	for name, want := range map[string]string{
+		SQLite:     "SELECT * FROM t WHERE a = ? AND b IN (?,?)",
+		MySQL:      "SELECT * FROM t WHERE a = ? AND b IN (?,?)",
+		PostgreSQL: "SELECT * FROM t WHERE a = $1 AND b IN ($2,$3)",
+	} {
+		conn := &Connection{driver: drivers[name]}
+		if got := conn.Rebind("SELECT * FROM t WHERE a = ? AND b IN (?,?)"); got != want {
+			t.Fatalf("%s: got %s", name, got)
+		}
+	}
+
+	args := []any{1, 2, 3, 4}
+	// ?按出现次数计数，$n按最大序号计数
+	params, next, err := extractParams(drivers[MySQL].BindType(), "UPDATE t SET a = ? WHERE b = ?", args, 1)
+	if err != nil || next != 3 || len(params) != 2 || params[0] != 2 {
+		t.Fatalf("unexpected params: %v, %d, %v", params, next, err)
+	}
+	params, next, err = extractParams(drivers[PostgreSQL].BindType(), "UPDATE t SET a = $1, c = $1 WHERE b = $2", args, 2)
+	if err != nil || next != 4 || len(params) != 2 || params[0] != 3 {
+		t.Fatalf("unexpected params: %v, %d, %v", params, next, err)
+	}
+	if _, _, err := extractParams(drivers[PostgreSQL].BindType(), "SELECT $3", args, 2); err == nil {
+		t.Fatal("expected error for insufficient parameters")
+	}
+
+	conn := newTestConnection(t, testDSN(t))
+	results, err := conn.BatchExec("CREATE TABLE t (a INT); INSERT INTO t VALUES (?), (?); DELETE FROM t WHERE a = ?", 1, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[1] != 2 || results[2] != 1 {
+		t.Fatalf("unexpected results: %v", results)
+	}
+}
+
+type MapperItem struct {
+	ID       uint   `dba:"pk;incr"`
+	FullName string // 多单词字段按snake_case匹配full_name列
+	Nick     string `dba:"native=nick_name" db:"nick_name"`
+}
+
+func TestColumnMapper(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &MapperItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec("INSERT INTO mapper_item (full_name, nick_name) VALUES (?, ?)", "Ada Lovelace", "ada"); err != nil {
+		t.Fatal(err)
+	}
+	var item MapperItem
+	if err := conn.ns.Model("MapperItem").Find().One(&item); err != nil {
+		t.Fatal(err)
+	}
+	if item.FullName != "Ada Lovelace" || item.Nick != "ada" {
+		t.Fatalf("unexpected item: %+v", item)
+	}
+}
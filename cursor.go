@@ -0,0 +1,426 @@
+package dba
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultCursorSecret 未配置CursorSecret时使用的进程内随机密钥，进程重启后游标失效
+var defaultCursorSecret = func() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}()
+
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+// CursorPage 游标分页结果
+type CursorPage struct {
+	Next    string `json:"next,omitempty"` // 下一页游标，没有更多数据时为空
+	Prev    string `json:"prev,omitempty"` // 上一页游标，已是第一页时为空
+	HasNext bool   `json:"has_next"`
+	HasPrev bool   `json:"has_prev"`
+}
+
+// cursorToken 游标内容，签名后编码为URL安全的字符串
+type cursorToken struct {
+	Schema    string `json:"m"`
+	Order     string `json:"o"`
+	Direction string `json:"d"`
+	Filter    string `json:"f"` // 查询条件的摘要
+	Values    []any  `json:"v"`
+}
+
+// keysetClause 游标分页的查询条件及排序
+type keysetClause struct {
+	where   string
+	attrs   []any
+	orderBy string
+}
+
+// cursorKey 游标排序列
+type cursorKey struct {
+	field    *Field
+	desc     bool
+	nullable bool
+}
+
+func (r *Result) Cursor(after string, size int, dst any) (*CursorPage, error) {
+	return r.CursorContext(context.Background(), after, size, dst)
+}
+
+// CursorContext 按排序字段及主键进行游标（keyset）分页，after为空时查询第一页。
+// 可为空的排序字段中NULL视为最大值（升序排在最后），排序字段的值不应在查询钩子中修改，
+// 游标只能用于相同模型、查询条件及排序的查询
+func (r *Result) CursorContext(ctx context.Context, after string, size int, dst any) (*CursorPage, error) {
+	// FINAL
+	defer r.reset()
+
+	if size <= 0 {
+		return nil, fmt.Errorf("dba: invalid cursor size: %d", size)
+	}
+	if vi := NewReflectValue(dst).ValueIs(); vi != ValueIsStructArray && vi != ValueIsMapArray {
+		return nil, fmt.Errorf("dba: cursor destination must be a slice")
+	}
+	keys, order, err := r.cursorKeys()
+	if err != nil {
+		return nil, err
+	}
+	filter, err := r.cursorFilter()
+	if err != nil {
+		return nil, err
+	}
+	token := &cursorToken{Schema: r.dm.schema.Name, Order: order, Direction: cursorNext, Filter: filter}
+	if after != "" {
+		if token, err = r.dm.conn.decodeCursor(after); err != nil {
+			return nil, err
+		}
+		if token.Schema != r.dm.schema.Name || token.Order != order || token.Filter != filter || len(token.Values) != len(keys) ||
+			(token.Direction != cursorNext && token.Direction != cursorPrev) {
+			return nil, fmt.Errorf("dba: invalid cursor")
+		}
+	}
+	backward := token.Direction == cursorPrev
+	r.keyset, err = r.keysetClause(keys, token.Values, backward)
+	if err != nil {
+		return nil, err
+	}
+	r.ensureCursorFields(keys)
+	r.limit = size + 1
+	r.offset = 0
+	if err := r.AllContext(ctx, dst); err != nil {
+		return nil, err
+	}
+
+	rows := reflect.Indirect(reflect.ValueOf(dst))
+	hasMore := rows.Len() > size
+	if hasMore {
+		rows.Set(rows.Slice(0, size))
+	}
+	if backward {
+		swap := reflect.Swapper(rows.Interface())
+		for i, j := 0, rows.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+	page := &CursorPage{
+		HasNext: (!backward && hasMore) || (backward && after != ""),
+		HasPrev: (backward && hasMore) || (!backward && after != ""),
+	}
+	if rows.Len() == 0 {
+		return page, nil
+	}
+	encode := func(direction string, elem reflect.Value) (string, error) {
+		values, err := cursorValues(keys, elem)
+		if err != nil {
+			return "", err
+		}
+		return r.dm.conn.encodeCursor(&cursorToken{Schema: token.Schema, Order: order, Direction: direction, Filter: filter, Values: values})
+	}
+	if page.HasNext {
+		if page.Next, err = encode(cursorNext, rows.Index(rows.Len()-1)); err != nil {
+			return nil, err
+		}
+	}
+	if page.HasPrev {
+		if page.Prev, err = encode(cursorPrev, rows.Index(0)); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// cursorKeys 返回排序字段及主键（未参与排序时追加，保证顺序唯一），order为排序的签名
+func (r *Result) cursorKeys() ([]*cursorKey, string, error) {
+	sch := r.dm.schema
+	var (
+		keys  []*cursorKey
+		names []string
+		seen  = make(map[string]bool)
+	)
+	for _, key := range r.orderKeys {
+		_, field, err := resolveColumn(r.dm.conn.driver, sch, key, true)
+		if err != nil {
+			return nil, "", err
+		}
+		if field.IsVirtual || !field.IsScalarType() {
+			return nil, "", fmt.Errorf("dba: invalid cursor field: %s.%s", sch.Name, key)
+		}
+		if seen[field.Name] {
+			continue
+		}
+		seen[field.Name] = true
+		keys = append(keys, &cursorKey{field: field, desc: r.orderBys[key], nullable: !field.IsPrimary && !field.IsRequired()})
+	}
+	pks := append([]*Field(nil), sch.PrimaryFields()...)
+	if len(pks) == 0 {
+		return nil, "", fmt.Errorf("dba: cursor requires a primary key: %s", sch.Name)
+	}
+	sort.Slice(pks, func(i, j int) bool {
+		return pks[i].Name < pks[j].Name
+	})
+	for _, pk := range pks {
+		if !seen[pk.Name] {
+			seen[pk.Name] = true
+			keys = append(keys, &cursorKey{field: pk})
+		}
+	}
+	for _, key := range keys {
+		if key.desc {
+			names = append(names, "-"+key.field.Name)
+		} else {
+			names = append(names, key.field.Name)
+		}
+	}
+	return keys, strings.Join(names, ","), nil
+}
+
+// keysetClause 生成游标之后（backward时为之前）数据的查询条件：
+// (a > ?) OR (a = ? AND b > ?) OR ...，降序字段使用小于。
+// 可为空的字段中NULL视为最大值，排序时先按是否为NULL排序，条件中使用IS NULL比较
+func (r *Result) keysetClause(keys []*cursorKey, values []any, backward bool) (*keysetClause, error) {
+	driver := r.dm.conn.driver
+	clause := new(keysetClause)
+	var orderBys []string
+	for _, key := range keys {
+		column := driver.QuoteIdentifier(key.field.NativeName)
+		direction := ""
+		if key.desc != backward {
+			direction = " DESC"
+		}
+		if key.nullable {
+			orderBys = append(orderBys, fmt.Sprintf("(%s IS NULL)%s", column, direction))
+		}
+		orderBys = append(orderBys, column+direction)
+	}
+	clause.orderBy = strings.Join(orderBys, ",")
+	if len(values) == 0 {
+		return clause, nil
+	}
+	var (
+		ors    []string
+		equals []string
+		attrs  []any
+	)
+	for i, key := range keys {
+		column := driver.QuoteIdentifier(key.field.NativeName)
+		v, err := cursorValue(key.field, values[i])
+		if err != nil {
+			return nil, err
+		}
+		desc := key.desc != backward
+		var (
+			after     string
+			afterAttr bool
+		)
+		switch {
+		case v == nil && desc:
+			after = fmt.Sprintf("%s IS NOT NULL", column)
+		case v == nil:
+			// NULL为最大值，升序时之后没有数据
+		case desc:
+			after, afterAttr = fmt.Sprintf("%s < ?", column), true
+		case key.nullable:
+			after, afterAttr = fmt.Sprintf("(%s > ? OR %s IS NULL)", column, column), true
+		default:
+			after, afterAttr = fmt.Sprintf("%s > ?", column), true
+		}
+		if after != "" {
+			ors = append(ors, "("+strings.Join(append(append([]string(nil), equals...), after), " AND ")+")")
+			clause.attrs = append(clause.attrs, attrs...)
+			if afterAttr {
+				clause.attrs = append(clause.attrs, v)
+			}
+		}
+		if v == nil {
+			equals = append(equals, fmt.Sprintf("%s IS NULL", column))
+		} else {
+			equals = append(equals, fmt.Sprintf("%s = ?", column))
+			attrs = append(attrs, v)
+		}
+	}
+	if len(ors) == 0 {
+		clause.where = "1 = 0"
+	} else {
+		clause.where = strings.Join(ors, " OR ")
+	}
+	return clause, nil
+}
+
+// cursorFilter 返回查询条件（含软删除范围）的摘要，写入游标防止用于不同条件的查询
+func (r *Result) cursorFilter() (string, error) {
+	where, attrs, err := parseWhere(r.dm.conn.driver, r.dm.conn.ns, r.dm.schema, r.filters, r.dm.strict)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\x00%d", where, r.deleted)
+	for _, attr := range attrs {
+		v := reflect.ValueOf(attr)
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		switch {
+		case !v.IsValid() || v.Kind() == reflect.Ptr:
+			_, _ = fmt.Fprint(h, "\x00<nil>")
+		case v.Type() == reflect.TypeOf(time.Time{}):
+			_, _ = fmt.Fprintf(h, "\x00%s", v.Interface().(time.Time).UTC().Format(time.RFC3339Nano))
+		default:
+			_, _ = fmt.Fprintf(h, "\x00%v", v.Interface())
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16]), nil
+}
+
+// ensureCursorFields 确保查询字段包含游标排序列
+func (r *Result) ensureCursorFields(keys []*cursorKey) {
+	if len(r.fields) == 0 {
+		return
+	}
+	isKey := func(name string) bool {
+		for _, key := range keys {
+			if key.field.Name == name || key.field.NativeName == name {
+				return true
+			}
+		}
+		return false
+	}
+	if r.isOmit {
+		var fields []string
+		for _, name := range r.fields {
+			if !isKey(name) {
+				fields = append(fields, name)
+			}
+		}
+		r.fields = fields
+		return
+	}
+	fields := append([]string(nil), r.fields...)
+	for _, key := range keys {
+		selected := false
+		for _, name := range r.fields {
+			if name == key.field.Name || name == key.field.NativeName {
+				selected = true
+				break
+			}
+		}
+		if !selected {
+			fields = append(fields, key.field.Name)
+		}
+	}
+	r.fields = fields
+}
+
+// cursorValues 读取数据的排序列值，结构体按字段名、map按字段名或列名读取
+func cursorValues(keys []*cursorKey, elem reflect.Value) ([]any, error) {
+	ru := NewReflectValue(elem)
+	var values []any
+	for _, key := range keys {
+		val := ru.FieldByName(key.field.Name)
+		if val == nil {
+			val = ru.FieldByName(key.field.NativeName)
+		}
+		if val == nil {
+			return nil, fmt.Errorf("dba: cursor field not found: %s", key.field.Name)
+		}
+		v := reflect.Indirect(*val)
+		if v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if !v.IsValid() {
+			values = append(values, nil)
+			continue
+		}
+		switch raw := v.Interface().(type) {
+		case []byte:
+			values = append(values, string(raw))
+		default:
+			values = append(values, raw)
+		}
+	}
+	return values, nil
+}
+
+// cursorValue 将游标中的JSON值还原为字段类型的值
+func cursorValue(field *Field, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch field.Type {
+	case Integer:
+		if n, ok := value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return i, nil
+			}
+			return n.Float64()
+		}
+	case Float:
+		if n, ok := value.(json.Number); ok {
+			return n.Float64()
+		}
+	case Time:
+		if s, ok := value.(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return t, nil
+			}
+			return s, nil
+		}
+	}
+	if n, ok := value.(json.Number); ok {
+		return n.String(), nil
+	}
+	return value, nil
+}
+
+func (c *Connection) cursorSign(payload string) string {
+	secret := c.cursorSecret
+	if len(secret) == 0 {
+		secret = defaultCursorSecret
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// encodeCursor 编码并签名游标：base64url(JSON).签名
+func (c *Connection) encodeCursor(token *cursorToken) (string, error) {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + c.cursorSign(payload), nil
+}
+
+func (c *Connection) decodeCursor(cursor string) (*cursorToken, error) {
+	payload, sign, ok := strings.Cut(cursor, ".")
+	if !ok || !hmac.Equal([]byte(sign), []byte(c.cursorSign(payload))) {
+		return nil, fmt.Errorf("dba: invalid cursor")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("dba: invalid cursor")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var token cursorToken
+	if err := decoder.Decode(&token); err != nil {
+		return nil, fmt.Errorf("dba: invalid cursor")
+	}
+	return &token, nil
+}
@@ -0,0 +1,94 @@
+package dba
+
+import (
+	"reflect"
+	"testing"
+)
+
+type CurItem struct {
+	ID    uint `dba:"pk;incr"`
+	Kind  string
+	Score *int
+}
+
+func TestCursorPaging(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &CurItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// score含重复值及NULL
+	if _, err := conn.Exec(`INSERT INTO cur_item (id, kind, score) VALUES
+		(1, 'a', 3), (2, 'a', NULL), (3, 'a', 1), (4, 'b', 3), (5, 'a', NULL), (6, 'a', 2), (7, 'a', 3)`); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := func(order string, want []uint) {
+		t.Helper()
+		var (
+			ids      []uint
+			after    string
+			lastPage *CursorPage
+			lastSize int
+		)
+		for {
+			var items []*CurItem
+			page, err := conn.ns.Model("CurItem").Find("Kind", "a").OrderBy(order).Cursor(after, 2, &items)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (after != "") != page.HasPrev {
+				t.Fatalf("%s: unexpected HasPrev: %+v", order, page)
+			}
+			for _, item := range items {
+				ids = append(ids, item.ID)
+			}
+			lastPage, lastSize = page, len(items)
+			if !page.HasNext {
+				break
+			}
+			after = page.Next
+		}
+		if !reflect.DeepEqual(ids, want) {
+			t.Fatalf("%s: forward paging got %v, want %v", order, ids, want)
+		}
+
+		// 从最后一页向前翻页
+		var back []uint
+		before := lastPage.Prev
+		for before != "" {
+			var items []*CurItem
+			page, err := conn.ns.Model("CurItem").Find("Kind", "a").OrderBy(order).Cursor(before, 2, &items)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !page.HasNext {
+				t.Fatalf("%s: expected HasNext when paging backward", order)
+			}
+			var pageIDs []uint
+			for _, item := range items {
+				pageIDs = append(pageIDs, item.ID)
+			}
+			back = append(pageIDs, back...)
+			before = page.Prev
+		}
+		if !reflect.DeepEqual(back, want[:len(want)-lastSize]) {
+			t.Fatalf("%s: backward paging got %v, want %v", order, back, want[:len(want)-lastSize])
+		}
+	}
+	// NULL视为最大值：升序排在最后，降序排在最前
+	pages("Score", []uint{3, 6, 1, 7, 2, 5})
+	pages("-Score", []uint{2, 5, 1, 7, 6, 3})
+
+	// 游标不能用于不同查询条件
+	var items []*CurItem
+	page, err := conn.ns.Model("CurItem").Find("Kind", "a").OrderBy("Score").Cursor("", 2, &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ns.Model("CurItem").Find("Kind", "b").OrderBy("Score").Cursor(page.Next, 2, &items); err == nil {
+		t.Fatal("expected cursor to be rejected for a different filter")
+	}
+	if _, err := conn.ns.Model("CurItem").Find("Kind", "a").OrderBy("-Score").Cursor(page.Next, 2, &items); err == nil {
+		t.Fatal("expected cursor to be rejected for a different order")
+	}
+}
@@ -1,6 +1,9 @@
 package dba
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 var DefaultNamespace = &Namespace{
 	connections: new(sync.Map),
@@ -11,6 +14,14 @@ func Connect(config *ConnectConfig) (*Connection, error) {
 	return DefaultNamespace.Connect(config)
 }
 
+func LoadConfig(path string, options ...*LoadConfigOptions) error {
+	return DefaultNamespace.LoadConfig(path, options...)
+}
+
+func LoadConfigContext(ctx context.Context, path string, options ...*LoadConfigOptions) error {
+	return DefaultNamespace.LoadConfigContext(ctx, path, options...)
+}
+
 func Session(name ...string) *Connection {
 	return DefaultNamespace.Session(name...)
 }
@@ -27,6 +38,10 @@ func DisconnectAll() {
 	DefaultNamespace.DisconnectAll()
 }
 
+func SetActorProvider(provider ActorProvider) {
+	DefaultNamespace.SetActorProvider(provider)
+}
+
 func RegisterSchema(values ...any) error {
 	return DefaultNamespace.RegisterSchema(values...)
 }
@@ -48,11 +63,11 @@ func Model(schemaName string, options ...*ModelOptions) *DataModel {
 }
 
 func Exec(query string, args ...any) (int, error) {
-	return ExecBy("", query, args)
+	return ExecBy("", query, args...)
 }
 
 func ExecBatch(query string, args ...any) ([]int, error) {
-	return ExecByBatch("", query, args)
+	return ExecByBatch("", query, args...)
 }
 
 func ExecBy(connectionName string, query string, args ...any) (int, error) {
@@ -66,7 +81,7 @@ func ExecByBatch(connectionName string, query string, args ...any) ([]int, error
 }
 
 func Query(dst any, query string, args ...any) error {
-	return QueryBy("", dst, query, args)
+	return QueryBy("", dst, query, args...)
 }
 
 func QueryBy(connectionName string, dst any, query string, args ...any) error {
@@ -0,0 +1,32 @@
+package dba
+
+import (
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// testDSN 返回临时SQLite数据库的连接串，开启外键约束
+func testDSN(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "test.db") + "?_foreign_keys=on"
+}
+
+// newTestConnection 在独立的命名空间中连接SQLite数据库并注册模型
+func newTestConnection(t *testing.T, dsn string, schemas ...any) *Connection {
+	t.Helper()
+	ns := &Namespace{Name: t.Name(), connections: new(sync.Map), schemas: new(sync.Map)}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	conn, err := ns.Connect(&ConnectConfig{Driver: SQLite, Dsn: dsn, Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ns.DisconnectAll)
+	if err := ns.RegisterSchema(schemas...); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
@@ -0,0 +1,635 @@
+package dba
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ColumnInfo 数据库中已有列的信息
+type ColumnInfo struct {
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	Nullable        bool   `json:"nullable"`
+	IsPrimary       bool   `json:"is_primary"`
+	IsAutoIncrement bool   `json:"is_auto_increment"`
+	Comment         string `json:"comment,omitempty"`
+}
+
+// ForeignKeyInfo 数据库中已有的外键，NO ACTION（默认动作）记为空
+type ForeignKeyInfo struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"`
+	RefTable   string   `json:"ref_table"`
+	RefColumns []string `json:"ref_columns"`
+	OnDelete   string   `json:"on_delete,omitempty"`
+	OnUpdate   string   `json:"on_update,omitempty"`
+}
+
+// TableInfo 数据库中已有表的信息
+type TableInfo struct {
+	Name        string            `json:"name"`
+	Comment     string            `json:"comment,omitempty"`
+	Columns     []*ColumnInfo     `json:"columns"`
+	Indexes     []*Index          `json:"indexes,omitempty"` // 不含主键，索引列为列名
+	ForeignKeys []*ForeignKeyInfo `json:"foreign_keys,omitempty"`
+}
+
+func (t *TableInfo) Column(name string) *ColumnInfo {
+	for _, col := range t.Columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+func (t *TableInfo) Index(name string) *Index {
+	for _, idx := range t.Indexes {
+		if idx.Name == name {
+			return idx
+		}
+	}
+	return nil
+}
+
+// addIndexColumn 按索引名称合并多列索引
+func (t *TableInfo) addIndexColumn(name string, unique bool, column string, desc bool, where string) {
+	idx := t.Index(name)
+	if idx == nil {
+		idx = &Index{Name: name, Unique: unique, Where: where}
+		t.Indexes = append(t.Indexes, idx)
+	}
+	idx.Columns = append(idx.Columns, &IndexColumn{Name: column, Desc: desc})
+}
+
+func (t *TableInfo) foreignKey(name string) *ForeignKeyInfo {
+	for _, fk := range t.ForeignKeys {
+		if fk.Name == name {
+			return fk
+		}
+	}
+	return nil
+}
+
+// addForeignKey 按外键名称合并多列外键
+func (t *TableInfo) addForeignKey(name, column, refTable, refColumn, onDelete, onUpdate string) {
+	if fk := t.foreignKey(name); fk != nil {
+		fk.Columns = append(fk.Columns, column)
+		fk.RefColumns = append(fk.RefColumns, refColumn)
+		return
+	}
+	t.ForeignKeys = append(t.ForeignKeys, &ForeignKeyInfo{
+		Name:       name,
+		Columns:    []string{column},
+		RefTable:   refTable,
+		RefColumns: []string{refColumn},
+		OnDelete:   inspectedForeignKeyAction(onDelete),
+		OnUpdate:   inspectedForeignKeyAction(onUpdate),
+	})
+}
+
+// inspectedForeignKeyAction 规范化数据库返回的外键动作，NO ACTION为默认动作，记为空
+func inspectedForeignKeyAction(action string) string {
+	if action = foreignKeyAction(action); action == "NO ACTION" {
+		return ""
+	}
+	return action
+}
+
+// foreignKeyByColumns 按外键列查找外键
+func (t *TableInfo) foreignKeyByColumns(columns []string) *ForeignKeyInfo {
+	for _, fk := range t.ForeignKeys {
+		if strings.Join(fk.Columns, ",") == strings.Join(columns, ",") {
+			return fk
+		}
+	}
+	return nil
+}
+
+// ChangeKind 结构变更类型
+type ChangeKind string
+
+const (
+	ChangeCreateTable ChangeKind = "CREATE_TABLE"
+	ChangeAddColumn   ChangeKind = "ADD_COLUMN"
+	ChangeAlterColumn ChangeKind = "ALTER_COLUMN"
+	ChangeDropColumn  ChangeKind = "DROP_COLUMN"
+	ChangeAddIndex    ChangeKind = "ADD_INDEX"
+	ChangeAlterIndex  ChangeKind = "ALTER_INDEX" // 删除后重建
+	ChangeDropIndex   ChangeKind = "DROP_INDEX"
+
+	ChangeAddForeignKey   ChangeKind = "ADD_FOREIGN_KEY"
+	ChangeAlterForeignKey ChangeKind = "ALTER_FOREIGN_KEY" // 删除后重建
+	ChangeDropForeignKey  ChangeKind = "DROP_FOREIGN_KEY"
+)
+
+// SchemaChange 单项结构变更
+type SchemaChange struct {
+	Kind         ChangeKind  `json:"kind"`
+	Table        string      `json:"table"`
+	Column       string      `json:"column,omitempty"`
+	Field        *Field      `json:"field,omitempty"`         // 模型字段，删除列时为nil
+	Current      *ColumnInfo `json:"current,omitempty"`       // 数据库中的列，新增列时为nil
+	Index        *Index      `json:"index,omitempty"`         // 模型索引，删除索引时为nil
+	CurrentIndex *Index      `json:"current_index,omitempty"` // 数据库中的索引，新增索引时为nil
+
+	ForeignKey        *ForeignKeyInfo `json:"foreign_key,omitempty"`         // 模型外键，删除外键时为nil
+	CurrentForeignKey *ForeignKeyInfo `json:"current_foreign_key,omitempty"` // 数据库中的外键，新增外键时为nil
+
+	Rewrite    bool     `json:"rewrite,omitempty"` // 语句会重建表或回填数据后收紧约束
+	Statements []string `json:"statements,omitempty"`
+}
+
+// Destructive 是否为删除列、索引或外键的变更
+func (c *SchemaChange) Destructive() bool {
+	switch c.Kind {
+	case ChangeDropColumn, ChangeDropIndex, ChangeDropForeignKey:
+		return true
+	}
+	return false
+}
+
+// Rewriting 是否为修改已有结构或数据的变更：修改列、重建索引或外键、新增外键及重建表
+func (c *SchemaChange) Rewriting() bool {
+	switch c.Kind {
+	case ChangeAlterColumn, ChangeAlterIndex, ChangeAddForeignKey, ChangeAlterForeignKey:
+		return true
+	}
+	return c.Rewrite
+}
+
+// SchemaDiff 模型与数据库结构的差异，变更按建表、删除外键、新增列、修改列、索引、新增外键、删除列排序
+type SchemaDiff struct {
+	Changes []*SchemaChange `json:"changes"`
+}
+
+func (d *SchemaDiff) Empty() bool {
+	return d == nil || len(d.Changes) == 0
+}
+
+// Statements 返回按顺序执行的变更语句，默认只包含建表、新增可空列及新增索引；
+// allowAlter为true时包含改写类变更（见SchemaChange.Rewriting），allowDrop为true时包含删除类变更
+func (d *SchemaDiff) Statements(allowAlter, allowDrop bool) []string {
+	if d == nil {
+		return nil
+	}
+	var stmts []string
+	for _, change := range d.Changes {
+		if (change.Destructive() && !allowDrop) || (change.Rewriting() && !allowAlter) {
+			continue
+		}
+		stmts = append(stmts, change.Statements...)
+	}
+	return stmts
+}
+
+// SQL 返回变更脚本，参数同Statements
+func (d *SchemaDiff) SQL(allowAlter, allowDrop bool) string {
+	stmts := d.Statements(allowAlter, allowDrop)
+	if len(stmts) == 0 {
+		return ""
+	}
+	var buffer strings.Builder
+	for _, stmt := range stmts {
+		stmt = strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+		buffer.WriteString(stmt + ";\n")
+	}
+	return buffer.String()
+}
+
+func (c *Connection) Diff(schs ...map[string]*Schema) (*SchemaDiff, error) {
+	return c.DiffContext(context.Background(), schs...)
+}
+
+// DiffContext 读取数据库中的表结构并与模型比较，未指定模型时使用命名空间下的全部模型
+func (c *Connection) DiffContext(ctx context.Context, schs ...map[string]*Schema) (*SchemaDiff, error) {
+	var ss map[string]*Schema
+	if len(schs) > 0 {
+		ss = schs[0]
+	}
+	if ss == nil {
+		ss = c.ns.SchemaBys()
+	}
+	tables, err := c.driver.Inspect(ctx, c.xdb)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按外键依赖排序，保证被引用的表先创建
+	sortedNames := sortSchemaNames(ss)
+
+	diff := new(SchemaDiff)
+	var (
+		creates []*SchemaChange
+		alters  []*SchemaChange
+		created = make(map[string]*SchemaChange)
+	)
+	for _, name := range sortedNames {
+		sch := ss[name]
+		table := tables[sch.NativeName]
+		if table == nil {
+			ddl := c.driver.GenDDL([]string{name}, ss, true)
+			if ddl == "" {
+				continue
+			}
+			change := &SchemaChange{
+				Kind:       ChangeCreateTable,
+				Table:      sch.NativeName,
+				Statements: splitStatements(c.driver, ddl),
+			}
+			created[name] = change
+			creates = append(creates, change)
+			continue
+		}
+		alters = append(alters, c.diffTable(sch, table, ss)...)
+	}
+	// 循环依赖的外键在两张表都存在后添加，已有表缺少的外键由diffTable比较
+	for _, fk := range deferredForeignKeys(c.driver, ss) {
+		change := created[fk.Schema]
+		if change == nil {
+			continue
+		}
+		if refChange := created[fk.RefSchema]; refChange != nil {
+			change = refChange
+		}
+		change.Statements = append(change.Statements, addForeignKeyDDL(c.driver, ss[fk.Schema].NativeName, fk.info(ss)))
+	}
+	diff.Changes = append(creates, alters...)
+	return diff, nil
+}
+
+// diffTable 比较单表的列、索引及外键，变更按删除外键、新增列、修改列、索引、新增外键、删除列排序
+func (c *Connection) diffTable(sch *Schema, table *TableInfo, schs map[string]*Schema) []*SchemaChange {
+	var adds, modifies, drops []*SchemaChange
+	fields := ddlFields(sch, c.driver)
+	columns := make(map[string]bool)
+	for _, field := range fields {
+		columns[field.NativeName] = true
+		col := table.Column(field.NativeName)
+		if col == nil {
+			adds = append(adds, &SchemaChange{
+				Kind:   ChangeAddColumn,
+				Table:  sch.NativeName,
+				Column: field.NativeName,
+				Field:  field,
+			})
+			continue
+		}
+		changed := !sameColumnType(col.Type, c.driver.ColumnType(field))
+		// 主键的可空性由数据库决定，不做比较
+		if !field.IsPrimary && !col.IsPrimary && col.Nullable != columnNullable(field) {
+			changed = true
+		}
+		if changed {
+			modifies = append(modifies, &SchemaChange{
+				Kind:    ChangeAlterColumn,
+				Table:   sch.NativeName,
+				Column:  field.NativeName,
+				Field:   field,
+				Current: col,
+			})
+		}
+	}
+	for _, col := range table.Columns {
+		if !columns[col.Name] {
+			drops = append(drops, &SchemaChange{
+				Kind:    ChangeDropColumn,
+				Table:   sch.NativeName,
+				Column:  col.Name,
+				Current: col,
+			})
+		}
+	}
+	// 可空列先于非空列新增，SQLite新增非空列时重建表，重建需在其他新增列之后
+	sort.SliceStable(adds, func(i, j int) bool {
+		return columnNullable(adds[i].Field) && !columnNullable(adds[j].Field)
+	})
+	fkAdds, fkDrops := c.diffForeignKeys(sch, table, schs)
+	var changes []*SchemaChange
+	for _, group := range [][]*SchemaChange{fkDrops, adds, modifies, c.diffIndexes(sch, table), fkAdds, drops} {
+		changes = append(changes, group...)
+	}
+	if len(changes) > 0 {
+		c.driver.AlterDDL(sch, table, changes)
+	}
+	return changes
+}
+
+// diffIndexes 比较索引的列、排序及唯一性，部分索引条件会被数据库改写，不做比较
+func (c *Connection) diffIndexes(sch *Schema, table *TableInfo) []*SchemaChange {
+	var changes, drops []*SchemaChange
+	declared := make(map[string]bool)
+	for _, idx := range sch.Indexes {
+		declared[idx.Name] = true
+		current := table.Index(idx.Name)
+		if current == nil {
+			changes = append(changes, &SchemaChange{Kind: ChangeAddIndex, Table: sch.NativeName, Index: idx})
+			continue
+		}
+		if !sameIndex(sch, idx, current) {
+			changes = append(changes, &SchemaChange{Kind: ChangeAlterIndex, Table: sch.NativeName, Index: idx, CurrentIndex: current})
+		}
+	}
+	for _, current := range table.Indexes {
+		if !declared[current.Name] {
+			drops = append(drops, &SchemaChange{Kind: ChangeDropIndex, Table: sch.NativeName, CurrentIndex: current})
+		}
+	}
+	return append(drops, changes...)
+}
+
+// diffForeignKeys 按外键列比较模型关系推导的外键，引用表、引用列或动作不同时重建
+func (c *Connection) diffForeignKeys(sch *Schema, table *TableInfo, schs map[string]*Schema) (changes, drops []*SchemaChange) {
+	declared := make(map[string]bool)
+	for _, fk := range foreignKeys(schs)[sch.Name] {
+		info := fk.info(schs)
+		declared[strings.Join(info.Columns, ",")] = true
+		current := table.foreignKeyByColumns(info.Columns)
+		if current == nil {
+			changes = append(changes, &SchemaChange{Kind: ChangeAddForeignKey, Table: sch.NativeName, ForeignKey: info})
+			continue
+		}
+		if !sameForeignKey(c.driver, info, current) {
+			changes = append(changes, &SchemaChange{Kind: ChangeAlterForeignKey, Table: sch.NativeName, ForeignKey: info, CurrentForeignKey: current})
+		}
+	}
+	for _, current := range table.ForeignKeys {
+		if !declared[strings.Join(current.Columns, ",")] {
+			drops = append(drops, &SchemaChange{Kind: ChangeDropForeignKey, Table: sch.NativeName, CurrentForeignKey: current})
+		}
+	}
+	return changes, drops
+}
+
+// sameForeignKey 比较引用表、引用列及动作，SQLite未声明引用列时表示引用主键，不比较引用列
+func sameForeignKey(driver Driver, fk, current *ForeignKeyInfo) bool {
+	sameAction := func(a, b string) bool {
+		if driver.Name() == MySQL {
+			// InnoDB中RESTRICT与NO ACTION等价
+			a, b = strings.TrimPrefix(a, "RESTRICT"), strings.TrimPrefix(b, "RESTRICT")
+		}
+		return a == b
+	}
+	if fk.RefTable != current.RefTable || !sameAction(fk.OnDelete, current.OnDelete) || !sameAction(fk.OnUpdate, current.OnUpdate) {
+		return false
+	}
+	if len(current.RefColumns) > 0 && current.RefColumns[0] == "" {
+		return true
+	}
+	return strings.Join(fk.RefColumns, ",") == strings.Join(current.RefColumns, ",")
+}
+
+func sameIndex(sch *Schema, idx, current *Index) bool {
+	if idx.Unique != current.Unique || len(idx.Columns) != len(current.Columns) {
+		return false
+	}
+	for i, col := range idx.Columns {
+		name := col.Name
+		if f := sch.Fields[col.Name]; f != nil {
+			name = f.NativeName
+		}
+		if name != current.Columns[i].Name || col.Desc != current.Columns[i].Desc {
+			return false
+		}
+	}
+	return true
+}
+
+// columnNullable 字段对应的列是否可空
+func columnNullable(field *Field) bool {
+	return !field.IsRequired() && !field.IsPrimary
+}
+
+// nullableField 返回可空的字段副本，用于先新增可空列、回填后再收紧非空约束
+func nullableField(field *Field) *Field {
+	copied := field.Clone()
+	copied.RequiredConfig = ""
+	return copied
+}
+
+// backfillLiteral 收紧非空约束前回填空值使用的字面量：优先使用默认值配置，否则使用类型零值
+func backfillLiteral(driver Driver, field *Field) string {
+	if field.DefaultConfig != "" {
+		if v, err := field.DefaultValue(); err == nil {
+			switch v := v.(type) {
+			case int64, float64:
+				return fmt.Sprint(v)
+			case bool:
+				return booleanLiteral(driver, v)
+			case string:
+				return quoteString(v)
+			case time.Time:
+				if !strings.EqualFold(field.DefaultConfig, "now") {
+					return quoteString(v.Format("2006-01-02 15:04:05"))
+				}
+			}
+		}
+	}
+	switch field.Type {
+	case Integer, Float:
+		return "0"
+	case Boolean:
+		return booleanLiteral(driver, false)
+	case Time:
+		return "CURRENT_TIMESTAMP"
+	default:
+		return "''"
+	}
+}
+
+func booleanLiteral(driver Driver, v bool) string {
+	if driver.Name() == PostgreSQL {
+		return strings.ToUpper(fmt.Sprint(v))
+	}
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+var (
+	columnTypeSpacePattern    = regexp.MustCompile(`\s*([(),])\s*`)
+	columnTypeIntWidthPattern = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|integer|bigint)\((\d+)\)`)
+	columnTypeAliases         = map[string]string{
+		"int":         "integer",
+		"int4":        "integer",
+		"serial":      "integer",
+		"int8":        "bigint",
+		"bigserial":   "bigint",
+		"bool":        "boolean",
+		"float8":      "double precision",
+		"double":      "double precision",
+		"timestamptz": "timestamp with time zone",
+		"varchar":     "character varying",
+		"decimal":     "numeric",
+	}
+)
+
+// normalizeColumnType 统一列类型的写法，用于比较
+func normalizeColumnType(t string) string {
+	t = strings.ToLower(strings.Join(strings.Fields(t), " "))
+	t = columnTypeSpacePattern.ReplaceAllString(t, "$1")
+	// 忽略MySQL整数类型的显示宽度，TINYINT(1)表示布尔值除外
+	if m := columnTypeIntWidthPattern.FindStringSubmatch(t); m != nil && !(m[1] == "tinyint" && m[2] == "1") {
+		t = m[1] + t[len(m[0]):]
+	}
+	base, rest := t, ""
+	if i := strings.IndexAny(t, "( "); i > 0 {
+		base, rest = t[:i], t[i:]
+	}
+	if alias, ok := columnTypeAliases[base]; ok {
+		base = alias
+	}
+	return base + rest
+}
+
+func sameColumnType(a, b string) bool {
+	return normalizeColumnType(a) == normalizeColumnType(b)
+}
+
+// splitStatements 按分号拆分多条语句，字符串、引用标识符、注释及PostgreSQL美元引用中的分号不拆分，
+// 触发器的BEGIN...END语句块整体作为一条语句，仅包含注释的语句会被忽略
+func splitStatements(driver Driver, script string) []string {
+	var (
+		stmts   []string
+		start   int
+		hasCode bool
+		words   []string // 语句开头的关键字，用于识别触发器
+		depth   int      // 触发器中BEGIN/CASE的嵌套层数
+	)
+	mysql := driver.Name() == MySQL
+	isTrigger := func() bool {
+		if len(words) == 0 || words[0] != "CREATE" {
+			return false
+		}
+		for _, word := range words {
+			if word == "TRIGGER" {
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < len(script); {
+		c := script[i]
+		switch {
+		case c == '-' && strings.HasPrefix(script[i:], "--"), c == '#' && mysql:
+			if n := strings.IndexByte(script[i:], '\n'); n >= 0 {
+				i += n + 1
+			} else {
+				i = len(script)
+			}
+			continue
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			// MySQL的/*!...*/为条件执行的语句
+			if mysql && strings.HasPrefix(script[i:], "/*!") {
+				hasCode = true
+			}
+			if n := strings.Index(script[i+2:], "*/"); n >= 0 {
+				i += n + 4
+			} else {
+				i = len(script)
+			}
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			// 反斜杠转义：MySQL字符串及PostgreSQL的E'...'
+			escape := c == '\'' && (mysql || (i > 0 && (script[i-1] == 'E' || script[i-1] == 'e') && (i < 2 || !isIdentByte(script[i-2]))))
+			hasCode = true
+			i++
+			for i < len(script) {
+				if escape && script[i] == '\\' {
+					i += 2
+					continue
+				}
+				if script[i] == c {
+					if i+1 < len(script) && script[i+1] == c {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+			continue
+		case c == '$' && (i == 0 || !isIdentByte(script[i-1])):
+			if tag := dollarQuoteTag(script[i:]); tag != "" {
+				hasCode = true
+				if n := strings.Index(script[i+len(tag):], tag); n >= 0 {
+					i += n + 2*len(tag)
+				} else {
+					i = len(script)
+				}
+				continue
+			}
+		case isIdentByte(c) && (i == 0 || !isIdentByte(script[i-1])):
+			hasCode = true
+			j := i
+			for j < len(script) && isIdentByte(script[j]) {
+				j++
+			}
+			word := strings.ToUpper(script[i:j])
+			if len(words) < 8 {
+				words = append(words, word)
+			}
+			if isTrigger() {
+				switch word {
+				case "BEGIN", "CASE":
+					depth++
+				case "END":
+					// END IF、END LOOP等结束的是未计数的语句块
+					k := j
+					for k < len(script) && (script[k] == ' ' || script[k] == '\t' || script[k] == '\r' || script[k] == '\n') {
+						k++
+					}
+					l := k
+					for l < len(script) && isIdentByte(script[l]) {
+						l++
+					}
+					switch strings.ToUpper(script[k:l]) {
+					case "IF", "LOOP", "WHILE", "REPEAT":
+					default:
+						if depth > 0 {
+							depth--
+						}
+					}
+				}
+			}
+			i = j
+			continue
+		case c == ';' && depth == 0:
+			if stmt := strings.TrimSpace(script[start:i]); hasCode && stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			start, hasCode, words = i+1, false, nil
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			hasCode = true
+		}
+		i++
+	}
+	if stmt := strings.TrimSpace(script[start:]); hasCode && stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// dollarQuoteTag 返回PostgreSQL美元引用的起始标记，如$$、$body$，不是美元引用时返回空
+func dollarQuoteTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1]
+		}
+		if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(i > 1 && c >= '0' && c <= '9') && c < 0x80 {
+			return ""
+		}
+	}
+	return ""
+}
@@ -0,0 +1,189 @@
+package dba
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type DiffOrg struct {
+	Code  string      `dba:"pk"`
+	Name  string      `dba:"name=名称"`
+	Users []*DiffUser `dba:"rel=HAS_MANY,Code->OrgCode;on_delete=cascade"`
+}
+
+type DiffUser struct {
+	ID      uint `dba:"pk;incr"`
+	OrgCode string
+	Name    string
+}
+
+// DiffOrgV2 名称改为非空并新增带默认值的非空列，需要重建diff_org表
+type DiffOrgV2 struct {
+	Code  string        `dba:"pk"`
+	Name  string        `dba:"name=名称;req"`
+	Level int           `dba:"req;default=3"`
+	Users []*DiffUserV2 `dba:"rel=HAS_MANY,Code->OrgCode;on_delete=cascade"`
+}
+
+func (DiffOrgV2) Schema() Schema {
+	return Schema{NativeName: "diff_org"}
+}
+
+// DiffUserV2 名称改为非空，需要重建diff_user表（含外键）
+type DiffUserV2 struct {
+	ID      uint `dba:"pk;incr"`
+	OrgCode string
+	Name    string `dba:"req"`
+}
+
+func (DiffUserV2) Schema() Schema {
+	return Schema{NativeName: "diff_user"}
+}
+
+// DiffOrgV3 外键动作改为SET NULL
+type DiffOrgV3 struct {
+	Code  string      `dba:"pk"`
+	Name  string      `dba:"name=名称"`
+	Users []*DiffUser `dba:"rel=HAS_MANY,Code->OrgCode;on_delete=set_null"`
+}
+
+func (DiffOrgV3) Schema() Schema {
+	return Schema{NativeName: "diff_org"}
+}
+
+func TestInitSQLiteRebuild(t *testing.T) {
+	dsn := testDSN(t)
+	conn := newTestConnection(t, dsn, &DiffOrg{}, &DiffUser{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"INSERT INTO diff_org (code, name) VALUES ('a', 'A'), ('b', NULL)",
+		"INSERT INTO diff_user (org_code, name) VALUES ('a', 'u1'), ('a', NULL), ('b', 'u3')",
+	} {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn2 := newTestConnection(t, dsn, &DiffOrgV2{}, &DiffUserV2{})
+	// 默认不执行改写类变更
+	diff, err := conn2.InitBy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Statements(false, false)) != 0 {
+		t.Fatalf("unexpected safe statements: %v", diff.Statements(false, false))
+	}
+	var rewrites int
+	for _, change := range diff.Changes {
+		if change.Rewriting() {
+			rewrites++
+		}
+	}
+	if rewrites == 0 {
+		t.Fatalf("expected rewriting changes, got %+v", diff.Changes)
+	}
+	if tables, err := conn2.driver.Inspect(context.Background(), conn2.xdb); err != nil {
+		t.Fatal(err)
+	} else if tables["diff_org"].Column("level") != nil {
+		t.Fatal("level column added without AllowAlter")
+	}
+
+	if _, err := conn2.InitBy(&InitOptions{AllowAlter: true}); err != nil {
+		t.Fatal(err)
+	}
+	if diff, err := conn2.Diff(); err != nil {
+		t.Fatal(err)
+	} else if !diff.Empty() {
+		t.Fatalf("expected no changes after rebuild, got %s", diff.SQL(true, true))
+	}
+
+	var orgs []struct {
+		Code  string
+		Name  string
+		Level int
+	}
+	if err := conn2.Query(&orgs, "SELECT code, name, level FROM diff_org ORDER BY code"); err != nil {
+		t.Fatal(err)
+	}
+	if len(orgs) != 2 || orgs[0].Name != "A" || orgs[1].Name != "" || orgs[0].Level != 3 || orgs[1].Level != 3 {
+		t.Fatalf("unexpected orgs after rebuild: %+v", orgs)
+	}
+	// 重建父表不能级联删除子表数据
+	var users int
+	if err := conn2.xdb.Get(&users, "SELECT COUNT(*) FROM diff_user"); err != nil {
+		t.Fatal(err)
+	}
+	if users != 3 {
+		t.Fatalf("expected 3 users after rebuild, got %d", users)
+	}
+
+	tables, err := conn2.driver.Inspect(context.Background(), conn2.xdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fks := tables["diff_user"].ForeignKeys
+	if len(fks) != 1 || fks[0].OnDelete != "CASCADE" || fks[0].RefTable != "diff_org" {
+		t.Fatalf("foreign key not preserved: %+v", fks)
+	}
+	// 外键动作仍然生效
+	if _, err := conn2.Exec("DELETE FROM diff_org WHERE code = 'a'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn2.xdb.Get(&users, "SELECT COUNT(*) FROM diff_user"); err != nil {
+		t.Fatal(err)
+	}
+	if users != 1 {
+		t.Fatalf("expected cascade delete to leave 1 user, got %d", users)
+	}
+}
+
+func TestDiffForeignKeyAction(t *testing.T) {
+	dsn := testDSN(t)
+	conn := newTestConnection(t, dsn, &DiffOrg{}, &DiffUser{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec("INSERT INTO diff_org (code, name) VALUES ('a', 'A')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec("INSERT INTO diff_user (org_code, name) VALUES ('a', 'u1')"); err != nil {
+		t.Fatal(err)
+	}
+
+	conn2 := newTestConnection(t, dsn, &DiffOrgV3{}, &DiffUser{})
+	diff, err := conn2.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, change := range diff.Changes {
+		if change.Kind == ChangeAlterForeignKey && change.Table == "diff_user" {
+			found = true
+			if change.CurrentForeignKey.OnDelete != "CASCADE" || change.ForeignKey.OnDelete != "SET NULL" {
+				t.Fatalf("unexpected foreign key change: %+v", change)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected ALTER_FOREIGN_KEY, got %s", diff.SQL(true, true))
+	}
+	if _, err := conn2.InitBy(&InitOptions{AllowAlter: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn2.Exec("DELETE FROM diff_org WHERE code = 'a'"); err != nil {
+		t.Fatal(err)
+	}
+	var orgCode *string
+	if err := conn2.xdb.Get(&orgCode, "SELECT org_code FROM diff_user"); err != nil {
+		t.Fatal(err)
+	}
+	if orgCode != nil {
+		t.Fatalf("expected org_code to be set null, got %q", *orgCode)
+	}
+	if sql := diff.SQL(true, true); !strings.Contains(sql, "ON DELETE SET NULL") {
+		t.Fatalf("rebuild does not declare the new action: %s", sql)
+	}
+}
@@ -1,6 +1,15 @@
 package dba
 
-import "github.com/jmoiron/sqlx"
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
 
 const (
 	MySQL      = "mysql"
@@ -16,10 +25,102 @@ var drivers = map[string]Driver{
 
 type Driver interface {
 	Name() string
+	BindType() int // 占位符风格，取值同sqlx.BindType（如sqlx.QUESTION、sqlx.DOLLAR）
+	QuoteIdentifier(name string) string
+	SupportsReturning() bool // 是否支持INSERT ... RETURNING
 	Connect(config *ConnectConfig) (*sqlx.DB, error)
 	GenDDL(sortedNames []string, schs map[string]*Schema, ignoreComments ...bool) string
+	ColumnType(field *Field) string                                                             // 字段对应的列类型，非数据库列时返回空
+	Inspect(ctx context.Context, q sqlx.QueryerContext) (map[string]*TableInfo, error)          // 读取数据库中已有的表结构
+	AlterDDL(sch *Schema, table *TableInfo, changes []*SchemaChange)                            // 生成变更语句，写入各变更的Statements
+	AcquireLock(ctx context.Context, conn *sqlx.Conn, name string, timeout time.Duration) error // 获取跨进程的命名锁，超时返回错误
+	ReleaseLock(ctx context.Context, conn *sqlx.Conn, name string) error
 	CreateClauses() string
 	DeleteClauses() string
 	UpdateClauses() string
 	QueryClauses() string
 }
+
+// quoteIdentifier 使用指定引号转义标识符，支持"table.column"形式
+func quoteIdentifier(name string, quote string) string {
+	if name == "" || name == "*" {
+		return name
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if part == "*" {
+			continue
+		}
+		parts[i] = quote + strings.ReplaceAll(part, quote, quote+quote) + quote
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteString 转义SQL字符串字面量
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// ddlFields 返回需要建列的字段：排除虚拟字段，主键在前，其余按列名排序
+func ddlFields(sch *Schema, driver Driver) []*Field {
+	var fields []*Field
+	for _, field := range sch.Fields {
+		if field.IsVirtual || driver.ColumnType(field) == "" {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].IsPrimary != fields[j].IsPrimary {
+			return fields[i].IsPrimary
+		}
+		return fields[i].NativeName < fields[j].NativeName
+	})
+	return fields
+}
+
+// indexColumnList 返回索引的列定义，format生成单列写法，字段不存在时按列名处理
+func indexColumnList(sch *Schema, idx *Index, format func(column string, field *Field) string) string {
+	var columns []string
+	for _, col := range idx.Columns {
+		name, field := col.Name, sch.Fields[col.Name]
+		if field != nil {
+			name = field.NativeName
+		}
+		definition := format(name, field)
+		if col.Desc {
+			definition += " DESC"
+		}
+		columns = append(columns, definition)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// createIndexDDL 生成独立的建索引语句（PostgreSQL、SQLite）
+func createIndexDDL(driver Driver, sch *Schema, idx *Index) string {
+	var buffer strings.Builder
+	buffer.WriteString("CREATE ")
+	if idx.Unique {
+		buffer.WriteString("UNIQUE ")
+	}
+	buffer.WriteString(fmt.Sprintf("INDEX IF NOT EXISTS %s ON %s (%s)", driver.QuoteIdentifier(idx.Name), driver.QuoteIdentifier(sch.NativeName),
+		indexColumnList(sch, idx, func(column string, _ *Field) string {
+			return driver.QuoteIdentifier(column)
+		})))
+	if idx.Where != "" {
+		buffer.WriteString(" WHERE " + idx.Where)
+	}
+	return buffer.String()
+}
+
+// errLockTimeout 获取命名锁超时
+func errLockTimeout(name string) error {
+	return fmt.Errorf("dba: acquire lock %s timeout", name)
+}
+
+// lockKey 将锁名称转换为数字键（PostgreSQL advisory lock）
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
@@ -2,10 +2,13 @@ package dba
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"strings"
+	"time"
 )
 
 type mysqlDriver struct {
@@ -15,6 +18,18 @@ func (m *mysqlDriver) Name() string {
 	return "mysql"
 }
 
+func (m *mysqlDriver) BindType() int {
+	return sqlx.QUESTION
+}
+
+func (m *mysqlDriver) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, "`")
+}
+
+func (m *mysqlDriver) SupportsReturning() bool {
+	return false
+}
+
 func (m *mysqlDriver) Connect(config *ConnectConfig) (*sqlx.DB, error) {
 	return sqlx.Connect(m.Name(), config.Dsn)
 }
@@ -27,46 +42,11 @@ func (m *mysqlDriver) GenDDL(sortedNames []string, schs map[string]*Schema, igno
 			columns        []string
 			primaryColumns []string
 		)
-		for _, field := range sch.Fields {
-			var buffer bytes.Buffer
-			buffer.WriteString(fmt.Sprintf("`%s`\t", field.NativeName))
-			nativeType := strings.TrimSpace(field.NativeType)
-			if nativeType == "" {
-				switch field.Type {
-				case String:
-					nativeType = "TEXT"
-				case Integer:
-					nativeType = "BIGINT"
-				case Float:
-					nativeType = "DOUBLE"
-				case Boolean:
-					nativeType = "TINYINT(1)"
-				case Time:
-					nativeType = "DATETIME(3)"
-				}
-			}
-			if nativeType == "" {
-				continue
-			}
-			buffer.WriteString(nativeType)
-			if field.IsUnsigned {
-				buffer.WriteString(" UNSIGNED")
-			}
-			if field.IsRequired() || field.IsPrimary {
-				buffer.WriteString(" NOT NULL")
-			} else {
-				buffer.WriteString(" NULL")
-			}
-			if field.IsAutoIncrement {
-				buffer.WriteString(" AUTO_INCREMENT")
-			}
+		for _, field := range ddlFields(sch, m) {
 			if field.IsPrimary {
-				primaryColumns = append(primaryColumns, fmt.Sprintf("`%s`", field.NativeName))
-			}
-			if field.Title != "" {
-				buffer.WriteString(fmt.Sprintf(" COMMENT '%s'", field.Title))
+				primaryColumns = append(primaryColumns, m.QuoteIdentifier(field.NativeName))
 			}
-			columns = append(columns, buffer.String())
+			columns = append(columns, m.columnDefinition(field))
 		}
 		if len(columns) == 0 {
 			continue
@@ -74,16 +54,241 @@ func (m *mysqlDriver) GenDDL(sortedNames []string, schs map[string]*Schema, igno
 		if len(primaryColumns) > 0 {
 			columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryColumns, ",")))
 		}
+		columns = append(columns, tableForeignKeys(m, name, schs)...)
+		for _, idx := range sch.Indexes {
+			columns = append(columns, m.indexDefinition(sch, idx))
+		}
 		var buffer bytes.Buffer
 		if len(ignoreComments) > 0 && !ignoreComments[0] {
 			buffer.WriteString(fmt.Sprintf("-- create \"%s\" table\n", sch.NativeName))
 		}
-		buffer.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (\n%s\n);", sch.NativeName, strings.Join(columns, ",\n")))
+		buffer.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n);", m.QuoteIdentifier(sch.NativeName), strings.Join(columns, ",\n")))
+		for _, stmt := range deferredForeignKeyDDL(m, name, sortedNames, schs) {
+			buffer.WriteString("\n" + stmt + ";")
+		}
 		ddls = append(ddls, buffer.String())
 	}
 	return strings.Join(ddls, "\n\n")
 }
 
+func (m *mysqlDriver) ColumnType(field *Field) string {
+	nativeType := strings.TrimSpace(field.NativeType)
+	if nativeType == "" {
+		switch field.Type {
+		case String:
+			nativeType = "TEXT"
+		case Integer:
+			nativeType = "BIGINT"
+		case Float:
+			nativeType = "DOUBLE"
+		case Boolean:
+			nativeType = "TINYINT(1)"
+		case Time:
+			nativeType = "DATETIME(3)"
+		}
+	}
+	if nativeType != "" && field.IsUnsigned {
+		nativeType += " UNSIGNED"
+	}
+	return nativeType
+}
+
+func (m *mysqlDriver) columnDefinition(field *Field) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(m.QuoteIdentifier(field.NativeName) + "\t")
+	buffer.WriteString(m.ColumnType(field))
+	if field.IsRequired() || field.IsPrimary {
+		buffer.WriteString(" NOT NULL")
+	} else {
+		buffer.WriteString(" NULL")
+	}
+	if field.IsAutoIncrement {
+		buffer.WriteString(" AUTO_INCREMENT")
+	}
+	if field.Title != "" {
+		buffer.WriteString(" COMMENT " + quoteString(field.Title))
+	}
+	return buffer.String()
+}
+
+// indexDefinition 索引定义，TEXT/BLOB列使用前缀索引
+func (m *mysqlDriver) indexDefinition(sch *Schema, idx *Index) string {
+	kind := "KEY"
+	if idx.Unique {
+		kind = "UNIQUE KEY"
+	}
+	return fmt.Sprintf("%s %s (%s)", kind, m.QuoteIdentifier(idx.Name), indexColumnList(sch, idx, func(column string, field *Field) string {
+		definition := m.QuoteIdentifier(column)
+		if field != nil {
+			if t := strings.ToUpper(m.ColumnType(field)); strings.Contains(t, "TEXT") || strings.Contains(t, "BLOB") {
+				definition += "(191)"
+			}
+		}
+		return definition
+	}))
+}
+
+func (m *mysqlDriver) Inspect(ctx context.Context, q sqlx.QueryerContext) (map[string]*TableInfo, error) {
+	var rows []struct {
+		TableName     string `db:"table_name"`
+		ColumnName    string `db:"column_name"`
+		ColumnType    string `db:"column_type"`
+		IsNullable    string `db:"is_nullable"`
+		ColumnKey     string `db:"column_key"`
+		Extra         string `db:"extra"`
+		ColumnComment string `db:"column_comment"`
+	}
+	err := sqlx.SelectContext(ctx, q, &rows, `SELECT TABLE_NAME AS table_name, COLUMN_NAME AS column_name, COLUMN_TYPE AS column_type,
+		IS_NULLABLE AS is_nullable, COLUMN_KEY AS column_key, EXTRA AS extra, COLUMN_COMMENT AS column_comment
+		FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() ORDER BY TABLE_NAME, ORDINAL_POSITION`)
+	if err != nil {
+		return nil, err
+	}
+	tables := make(map[string]*TableInfo)
+	for _, row := range rows {
+		table := tables[row.TableName]
+		if table == nil {
+			table = &TableInfo{Name: row.TableName}
+			tables[row.TableName] = table
+		}
+		table.Columns = append(table.Columns, &ColumnInfo{
+			Name:            row.ColumnName,
+			Type:            row.ColumnType,
+			Nullable:        row.IsNullable == "YES",
+			IsPrimary:       row.ColumnKey == "PRI",
+			IsAutoIncrement: strings.Contains(strings.ToLower(row.Extra), "auto_increment"),
+			Comment:         row.ColumnComment,
+		})
+	}
+
+	var comments []struct {
+		TableName    string `db:"table_name"`
+		TableComment string `db:"table_comment"`
+	}
+	err = sqlx.SelectContext(ctx, q, &comments, `SELECT TABLE_NAME AS table_name, TABLE_COMMENT AS table_comment
+		FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE()`)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range comments {
+		if table := tables[row.TableName]; table != nil {
+			table.Comment = row.TableComment
+		}
+	}
+
+	var fks []struct {
+		TableName      string `db:"table_name"`
+		ConstraintName string `db:"constraint_name"`
+		ColumnName     string `db:"column_name"`
+		RefTableName   string `db:"ref_table_name"`
+		RefColumnName  string `db:"ref_column_name"`
+		OnDelete       string `db:"on_delete"`
+		OnUpdate       string `db:"on_update"`
+	}
+	err = sqlx.SelectContext(ctx, q, &fks, `SELECT k.TABLE_NAME AS table_name, k.CONSTRAINT_NAME AS constraint_name, k.COLUMN_NAME AS column_name,
+		k.REFERENCED_TABLE_NAME AS ref_table_name, k.REFERENCED_COLUMN_NAME AS ref_column_name,
+		rc.DELETE_RULE AS on_delete, rc.UPDATE_RULE AS on_update
+		FROM information_schema.KEY_COLUMN_USAGE k
+		JOIN information_schema.REFERENTIAL_CONSTRAINTS rc ON rc.CONSTRAINT_SCHEMA = k.TABLE_SCHEMA AND rc.TABLE_NAME = k.TABLE_NAME AND rc.CONSTRAINT_NAME = k.CONSTRAINT_NAME
+		WHERE k.TABLE_SCHEMA = DATABASE() AND k.REFERENCED_TABLE_NAME IS NOT NULL
+		ORDER BY k.TABLE_NAME, k.CONSTRAINT_NAME, k.ORDINAL_POSITION`)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range fks {
+		if table := tables[row.TableName]; table != nil {
+			table.addForeignKey(row.ConstraintName, row.ColumnName, row.RefTableName, row.RefColumnName, row.OnDelete, row.OnUpdate)
+		}
+	}
+
+	var indexes []struct {
+		TableName  string         `db:"table_name"`
+		IndexName  string         `db:"index_name"`
+		NonUnique  int            `db:"non_unique"`
+		ColumnName sql.NullString `db:"column_name"`
+		Collation  sql.NullString `db:"collation"`
+	}
+	err = sqlx.SelectContext(ctx, q, &indexes, `SELECT TABLE_NAME AS table_name, INDEX_NAME AS index_name, NON_UNIQUE AS non_unique,
+		COLUMN_NAME AS column_name, COLLATION AS collation
+		FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND INDEX_NAME <> 'PRIMARY'
+		ORDER BY TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX`)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range indexes {
+		table := tables[row.TableName]
+		// 忽略函数索引及MySQL为外键自动创建的索引
+		if table == nil || !row.ColumnName.Valid || table.foreignKey(row.IndexName) != nil {
+			continue
+		}
+		table.addIndexColumn(row.IndexName, row.NonUnique == 0, row.ColumnName.String, row.Collation.String == "D", "")
+	}
+	return tables, nil
+}
+
+func (m *mysqlDriver) AlterDDL(sch *Schema, table *TableInfo, changes []*SchemaChange) {
+	tableName := m.QuoteIdentifier(sch.NativeName)
+	for _, change := range changes {
+		column := m.QuoteIdentifier(change.Column)
+		switch change.Kind {
+		case ChangeAddColumn:
+			if columnNullable(change.Field) {
+				change.Statements = []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableName, m.columnDefinition(change.Field))}
+				continue
+			}
+			// 非空列先以可空列新增，回填后再收紧约束，避免已有数据时失败
+			change.Rewrite = true
+			change.Statements = []string{
+				fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableName, m.columnDefinition(nullableField(change.Field))),
+				fmt.Sprintf("UPDATE %s SET %s = %s", tableName, column, backfillLiteral(m, change.Field)),
+				fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", tableName, m.columnDefinition(change.Field)),
+			}
+		case ChangeAlterColumn:
+			change.Statements = nil
+			if !columnNullable(change.Field) && change.Current.Nullable {
+				change.Statements = append(change.Statements, fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NULL", tableName, column, backfillLiteral(m, change.Field), column))
+			}
+			change.Statements = append(change.Statements, fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", tableName, m.columnDefinition(change.Field)))
+		case ChangeDropColumn:
+			change.Statements = []string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, column)}
+		case ChangeAddIndex:
+			change.Statements = []string{fmt.Sprintf("ALTER TABLE %s ADD %s", tableName, m.indexDefinition(sch, change.Index))}
+		case ChangeAlterIndex:
+			change.Statements = []string{
+				fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", tableName, m.QuoteIdentifier(change.CurrentIndex.Name)),
+				fmt.Sprintf("ALTER TABLE %s ADD %s", tableName, m.indexDefinition(sch, change.Index)),
+			}
+		case ChangeDropIndex:
+			change.Statements = []string{fmt.Sprintf("ALTER TABLE %s DROP INDEX %s", tableName, m.QuoteIdentifier(change.CurrentIndex.Name))}
+		case ChangeAddForeignKey:
+			change.Statements = []string{addForeignKeyDDL(m, sch.NativeName, change.ForeignKey)}
+		case ChangeAlterForeignKey:
+			change.Statements = []string{
+				fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s", tableName, m.QuoteIdentifier(change.CurrentForeignKey.Name)),
+				addForeignKeyDDL(m, sch.NativeName, change.ForeignKey),
+			}
+		case ChangeDropForeignKey:
+			change.Statements = []string{fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s", tableName, m.QuoteIdentifier(change.CurrentForeignKey.Name))}
+		}
+	}
+}
+
+func (m *mysqlDriver) AcquireLock(ctx context.Context, conn *sqlx.Conn, name string, timeout time.Duration) error {
+	var ok sql.NullInt64
+	if err := conn.GetContext(ctx, &ok, "SELECT GET_LOCK(?, ?)", name, int(timeout.Seconds())); err != nil {
+		return err
+	}
+	if !ok.Valid || ok.Int64 != 1 {
+		return errLockTimeout(name)
+	}
+	return nil
+}
+
+func (m *mysqlDriver) ReleaseLock(ctx context.Context, conn *sqlx.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", name)
+	return err
+}
+
 func (m *mysqlDriver) CreateClauses() string {
 	//INSERT INTO table_name (column1, column2, column3, ...)
 	//VALUES
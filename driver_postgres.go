@@ -2,10 +2,13 @@ package dba
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"strings"
+	"time"
 )
 
 type postgresDriver struct {
@@ -15,6 +18,18 @@ func (m *postgresDriver) Name() string {
 	return "postgres"
 }
 
+func (m *postgresDriver) BindType() int {
+	return sqlx.DOLLAR
+}
+
+func (m *postgresDriver) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`)
+}
+
+func (m *postgresDriver) SupportsReturning() bool {
+	return true
+}
+
 func (m *postgresDriver) Connect(config *ConnectConfig) (*sqlx.DB, error) {
 	return sqlx.Connect(m.Name(), config.Dsn)
 }
@@ -26,45 +41,17 @@ func (m *postgresDriver) GenDDL(sortedNames []string, schs map[string]*Schema, i
 		var (
 			columns        []string
 			primaryColumns []string
+			comments       []string
 		)
-		for _, field := range sch.Fields {
-			var buffer bytes.Buffer
-			buffer.WriteString(fmt.Sprintf(`"%s"	`, field.NativeName))
-			nativeType := strings.TrimSpace(field.NativeType)
-			if nativeType == "" {
-				switch field.Type {
-				case String:
-					nativeType = "TEXT"
-				case Integer:
-					if field.IsAutoIncrement {
-						nativeType = "BIGSERIAL"
-					} else {
-						nativeType = "BIGINT"
-					}
-				case Float:
-					nativeType = "DOUBLE PRECISION"
-				case Boolean:
-					nativeType = "BOOLEAN"
-				case Time:
-					nativeType = "TIMESTAMP WITH TIME ZONE"
-				}
-			}
-			if nativeType == "" {
-				continue
-			}
-			buffer.WriteString(nativeType)
-			if field.IsRequired() || field.IsPrimary {
-				buffer.WriteString(" NOT NULL")
-			} else {
-				buffer.WriteString(" NULL")
-			}
+		for _, field := range ddlFields(sch, m) {
 			if field.IsPrimary {
-				primaryColumns = append(primaryColumns, fmt.Sprintf(`"%s"`, field.NativeName))
+				primaryColumns = append(primaryColumns, m.QuoteIdentifier(field.NativeName))
 			}
-			if field.Title != "" {
-				buffer.WriteString(fmt.Sprintf(` COMMENT '%s'`, field.Title))
+			columns = append(columns, m.columnDefinition(field))
+			// PostgreSQL不支持列定义中的COMMENT，使用COMMENT ON COLUMN
+			if comment := m.columnComment(sch, field); comment != "" {
+				comments = append(comments, comment)
 			}
-			columns = append(columns, buffer.String())
 		}
 		if len(columns) == 0 {
 			continue
@@ -72,16 +59,263 @@ func (m *postgresDriver) GenDDL(sortedNames []string, schs map[string]*Schema, i
 		if len(primaryColumns) > 0 {
 			columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryColumns, ",")))
 		}
+		columns = append(columns, tableForeignKeys(m, name, schs)...)
 		var buffer bytes.Buffer
 		if len(ignoreComments) > 0 && !ignoreComments[0] {
 			buffer.WriteString(fmt.Sprintf("-- create \"%s\" table\n", sch.NativeName))
 		}
-		buffer.WriteString(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (%s);`, sch.NativeName, strings.Join(columns, ",\n")))
+		buffer.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n);", m.QuoteIdentifier(sch.NativeName), strings.Join(columns, ",\n")))
+		for _, comment := range comments {
+			buffer.WriteString("\n" + comment + ";")
+		}
+		for _, idx := range sch.Indexes {
+			buffer.WriteString("\n" + createIndexDDL(m, sch, idx) + ";")
+		}
+		for _, stmt := range deferredForeignKeyDDL(m, name, sortedNames, schs) {
+			buffer.WriteString("\n" + stmt + ";")
+		}
 		ddls = append(ddls, buffer.String())
 	}
 	return strings.Join(ddls, "\n\n")
 }
 
+func (m *postgresDriver) ColumnType(field *Field) string {
+	nativeType := strings.TrimSpace(field.NativeType)
+	if nativeType == "" {
+		switch field.Type {
+		case String:
+			nativeType = "TEXT"
+		case Integer:
+			if field.IsAutoIncrement {
+				nativeType = "BIGSERIAL"
+			} else {
+				nativeType = "BIGINT"
+			}
+		case Float:
+			nativeType = "DOUBLE PRECISION"
+		case Boolean:
+			nativeType = "BOOLEAN"
+		case Time:
+			nativeType = "TIMESTAMP WITH TIME ZONE"
+		}
+	}
+	return nativeType
+}
+
+func (m *postgresDriver) columnDefinition(field *Field) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(m.QuoteIdentifier(field.NativeName) + "\t")
+	buffer.WriteString(m.ColumnType(field))
+	if field.IsRequired() || field.IsPrimary {
+		buffer.WriteString(" NOT NULL")
+	} else {
+		buffer.WriteString(" NULL")
+	}
+	return buffer.String()
+}
+
+func (m *postgresDriver) columnComment(sch *Schema, field *Field) string {
+	if field.Title == "" {
+		return ""
+	}
+	return fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", m.QuoteIdentifier(sch.NativeName), m.QuoteIdentifier(field.NativeName), quoteString(field.Title))
+}
+
+func (m *postgresDriver) Inspect(ctx context.Context, q sqlx.QueryerContext) (map[string]*TableInfo, error) {
+	var rows []struct {
+		TableName       string         `db:"table_name"`
+		ColumnName      string         `db:"column_name"`
+		ColumnType      string         `db:"column_type"`
+		Nullable        bool           `db:"nullable"`
+		IsPrimary       bool           `db:"is_primary"`
+		IsAutoIncrement bool           `db:"is_auto_increment"`
+		ColumnComment   sql.NullString `db:"column_comment"`
+		TableComment    sql.NullString `db:"table_comment"`
+	}
+	err := sqlx.SelectContext(ctx, q, &rows, `SELECT c.relname AS table_name, a.attname AS column_name,
+		format_type(a.atttypid, a.atttypmod) AS column_type, NOT a.attnotnull AS nullable,
+		EXISTS (SELECT 1 FROM pg_index i WHERE i.indrelid = c.oid AND i.indisprimary AND a.attnum = ANY(i.indkey)) AS is_primary,
+		COALESCE(pg_get_expr(d.adbin, d.adrelid) LIKE 'nextval(%', false) AS is_auto_increment,
+		col_description(c.oid, a.attnum) AS column_comment, obj_description(c.oid, 'pg_class') AS table_comment
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE c.relkind = 'r' AND n.nspname = current_schema() AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY c.relname, a.attnum`)
+	if err != nil {
+		return nil, err
+	}
+	tables := make(map[string]*TableInfo)
+	for _, row := range rows {
+		table := tables[row.TableName]
+		if table == nil {
+			table = &TableInfo{Name: row.TableName}
+			tables[row.TableName] = table
+		}
+		table.Columns = append(table.Columns, &ColumnInfo{
+			Name:            row.ColumnName,
+			Type:            row.ColumnType,
+			Nullable:        row.Nullable,
+			IsPrimary:       row.IsPrimary,
+			IsAutoIncrement: row.IsAutoIncrement,
+			Comment:         row.ColumnComment.String,
+		})
+		table.Comment = row.TableComment.String
+	}
+
+	var fks []struct {
+		TableName      string `db:"table_name"`
+		ConstraintName string `db:"constraint_name"`
+		ColumnName     string `db:"column_name"`
+		RefTableName   string `db:"ref_table_name"`
+		RefColumnName  string `db:"ref_column_name"`
+		OnDelete       string `db:"on_delete"`
+		OnUpdate       string `db:"on_update"`
+	}
+	err = sqlx.SelectContext(ctx, q, &fks, `SELECT c.relname AS table_name, con.conname AS constraint_name, a.attname AS column_name,
+		rc.relname AS ref_table_name, ra.attname AS ref_column_name,
+		CASE con.confdeltype WHEN 'r' THEN 'RESTRICT' WHEN 'c' THEN 'CASCADE' WHEN 'n' THEN 'SET NULL' WHEN 'd' THEN 'SET DEFAULT' ELSE 'NO ACTION' END AS on_delete,
+		CASE con.confupdtype WHEN 'r' THEN 'RESTRICT' WHEN 'c' THEN 'CASCADE' WHEN 'n' THEN 'SET NULL' WHEN 'd' THEN 'SET DEFAULT' ELSE 'NO ACTION' END AS on_update
+		FROM pg_constraint con
+		JOIN pg_class c ON c.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_class rc ON rc.oid = con.confrelid
+		CROSS JOIN LATERAL unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(attnum, ref_attnum, ord)
+		JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+		JOIN pg_attribute ra ON ra.attrelid = con.confrelid AND ra.attnum = k.ref_attnum
+		WHERE con.contype = 'f' AND n.nspname = current_schema()
+		ORDER BY c.relname, con.conname, k.ord`)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range fks {
+		if table := tables[row.TableName]; table != nil {
+			table.addForeignKey(row.ConstraintName, row.ColumnName, row.RefTableName, row.RefColumnName, row.OnDelete, row.OnUpdate)
+		}
+	}
+
+	var indexes []struct {
+		TableName  string `db:"table_name"`
+		IndexName  string `db:"index_name"`
+		IsUnique   bool   `db:"is_unique"`
+		ColumnName string `db:"column_name"`
+		IsDesc     bool   `db:"is_desc"`
+		Condition  string `db:"condition"`
+	}
+	err = sqlx.SelectContext(ctx, q, &indexes, `SELECT t.relname AS table_name, i.relname AS index_name, ix.indisunique AS is_unique,
+		a.attname AS column_name, (ix.indoption[k.ord - 1] & 1) = 1 AS is_desc,
+		COALESCE(pg_get_expr(ix.indpred, ix.indrelid), '') AS condition
+		FROM pg_index ix
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		CROSS JOIN LATERAL unnest(ix.indkey::smallint[]) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+		WHERE n.nspname = current_schema() AND NOT ix.indisprimary
+		ORDER BY t.relname, i.relname, k.ord`)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range indexes {
+		if table := tables[row.TableName]; table != nil {
+			table.addIndexColumn(row.IndexName, row.IsUnique, row.ColumnName, row.IsDesc, row.Condition)
+		}
+	}
+	return tables, nil
+}
+
+func (m *postgresDriver) AlterDDL(sch *Schema, table *TableInfo, changes []*SchemaChange) {
+	tableName := m.QuoteIdentifier(sch.NativeName)
+	for _, change := range changes {
+		column := m.QuoteIdentifier(change.Column)
+		switch change.Kind {
+		case ChangeAddColumn:
+			if columnNullable(change.Field) {
+				change.Statements = []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableName, m.columnDefinition(change.Field))}
+			} else {
+				// 非空列先以可空列新增，回填后再收紧约束，避免已有数据时失败
+				change.Rewrite = true
+				change.Statements = []string{
+					fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableName, m.columnDefinition(nullableField(change.Field))),
+					fmt.Sprintf("UPDATE %s SET %s = %s", tableName, column, backfillLiteral(m, change.Field)),
+					fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL", tableName, column),
+				}
+			}
+			if comment := m.columnComment(sch, change.Field); comment != "" {
+				change.Statements = append(change.Statements, comment)
+			}
+		case ChangeAlterColumn:
+			change.Statements = nil
+			if nativeType := m.ColumnType(change.Field); !sameColumnType(change.Current.Type, nativeType) {
+				// 序列类型仅用于建表，修改类型时使用对应的整数类型
+				switch strings.ToUpper(nativeType) {
+				case "BIGSERIAL":
+					nativeType = "BIGINT"
+				case "SERIAL":
+					nativeType = "INTEGER"
+				}
+				change.Statements = append(change.Statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s", tableName, column, nativeType, column, nativeType))
+			}
+			if nullable := columnNullable(change.Field); nullable != change.Current.Nullable {
+				action := "SET NOT NULL"
+				if nullable {
+					action = "DROP NOT NULL"
+				} else {
+					change.Statements = append(change.Statements, fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NULL", tableName, column, backfillLiteral(m, change.Field), column))
+				}
+				change.Statements = append(change.Statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s", tableName, column, action))
+			}
+		case ChangeDropColumn:
+			change.Statements = []string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, column)}
+		case ChangeAddIndex:
+			change.Statements = []string{createIndexDDL(m, sch, change.Index)}
+		case ChangeAlterIndex:
+			change.Statements = []string{
+				fmt.Sprintf("DROP INDEX IF EXISTS %s", m.QuoteIdentifier(change.CurrentIndex.Name)),
+				createIndexDDL(m, sch, change.Index),
+			}
+		case ChangeDropIndex:
+			change.Statements = []string{fmt.Sprintf("DROP INDEX IF EXISTS %s", m.QuoteIdentifier(change.CurrentIndex.Name))}
+		case ChangeAddForeignKey:
+			change.Statements = []string{addForeignKeyDDL(m, sch.NativeName, change.ForeignKey)}
+		case ChangeAlterForeignKey:
+			change.Statements = []string{
+				fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", tableName, m.QuoteIdentifier(change.CurrentForeignKey.Name)),
+				addForeignKeyDDL(m, sch.NativeName, change.ForeignKey),
+			}
+		case ChangeDropForeignKey:
+			change.Statements = []string{fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", tableName, m.QuoteIdentifier(change.CurrentForeignKey.Name))}
+		}
+	}
+}
+
+func (m *postgresDriver) AcquireLock(ctx context.Context, conn *sqlx.Conn, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		var ok bool
+		if err := conn.GetContext(ctx, &ok, "SELECT pg_try_advisory_lock($1)", lockKey(name)); err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errLockTimeout(name)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (m *postgresDriver) ReleaseLock(ctx context.Context, conn *sqlx.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey(name))
+	return err
+}
+
 func (m *postgresDriver) CreateClauses() string {
 	return `INSERT INTO {{.Table}} ({{.Columns}})
 			VALUES
@@ -91,6 +325,9 @@ func (m *postgresDriver) CreateClauses() string {
 			SET {{.ConflictUpdates}}
 			{{else if eq .ConflictKind "IGNORE"}}
 			ON CONFLICT {{if .ConflictColumns}}({{.ConflictColumns}}){{end}} DO NOTHING
+			{{end}}
+			{{if .Returning}}
+			RETURNING {{.Returning}}
 			{{end}}`
 }
 
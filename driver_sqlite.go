@@ -2,80 +2,453 @@ package dba
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
+	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 type sqliteDriver struct {
+	locks sync.Map // *sqlx.Conn -> *sqliteLock
 }
 
 func (m *sqliteDriver) Name() string {
 	return "sqlite"
 }
 
+func (m *sqliteDriver) BindType() int {
+	return sqlx.QUESTION
+}
+
+func (m *sqliteDriver) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, "`")
+}
+
+func (m *sqliteDriver) SupportsReturning() bool {
+	return true
+}
+
 func (m *sqliteDriver) Connect(config *ConnectConfig) (*sqlx.DB, error) {
-	return sqlx.Connect(m.Name(), config.Dsn)
+	// go-sqlite3 注册的驱动名为sqlite3
+	return sqlx.Connect("sqlite3", config.Dsn)
 }
 
 func (m *sqliteDriver) GenDDL(sortedNames []string, schs map[string]*Schema, ignoreComments ...bool) string {
 	var ddls []string
 	for _, name := range sortedNames {
 		sch := schs[name]
-		var (
-			columns        []string
-			primaryColumns []string
-		)
-		for _, field := range sch.Fields {
-			var buffer bytes.Buffer
-			buffer.WriteString(fmt.Sprintf("`%s`\t", field.NativeName))
-			nativeType := strings.TrimSpace(field.NativeType)
-			if nativeType == "" {
-				switch field.Type {
-				case String:
-					nativeType = "TEXT"
-				case Integer:
-					nativeType = "INTEGER"
-				case Float:
-					nativeType = "REAL"
-				case Boolean:
-					nativeType = "INTEGER"
-				case Time:
-					nativeType = "DATETIME"
-				}
-			}
-			if nativeType == "" {
-				continue
-			}
-			buffer.WriteString(nativeType)
-			if field.IsPrimary {
-				if field.IsPrimary {
-					primaryColumns = append(primaryColumns, field.NativeName)
-				}
-			} else {
-				if field.IsRequired() {
-					buffer.WriteString(" NOT NULL")
-				} else {
-					buffer.WriteString(" NULL")
-				}
-			}
-			columns = append(columns, buffer.String())
-		}
+		columns := m.tableColumns(sch, nil)
 		if len(columns) == 0 {
 			continue
 		}
-		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryColumns, ",")))
+		columns = append(columns, tableForeignKeys(m, name, schs)...)
 		var buffer bytes.Buffer
 		if len(ignoreComments) > 0 && !ignoreComments[0] {
 			buffer.WriteString(fmt.Sprintf("-- create \"%s\" table\n", sch.NativeName))
 		}
-		buffer.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` (\n%s\n);", sch.NativeName, strings.Join(columns, ",\n")))
+		buffer.WriteString(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n%s\n);", m.QuoteIdentifier(sch.NativeName), strings.Join(columns, ",\n")))
+		for _, idx := range sch.Indexes {
+			buffer.WriteString("\n" + createIndexDDL(m, sch, idx) + ";")
+		}
 		ddls = append(ddls, buffer.String())
 	}
 	return strings.Join(ddls, "\n\n")
 }
 
+// tableColumns 返回建表的列定义及主键约束，extra为需要保留的非模型列
+func (m *sqliteDriver) tableColumns(sch *Schema, extra []*ColumnInfo) []string {
+	var (
+		columns        []string
+		primaryColumns []string
+	)
+	for _, field := range ddlFields(sch, m) {
+		if field.IsPrimary {
+			primaryColumns = append(primaryColumns, m.QuoteIdentifier(field.NativeName))
+		}
+		columns = append(columns, m.columnDefinition(field))
+	}
+	for _, col := range extra {
+		definition := m.QuoteIdentifier(col.Name) + "\t" + col.Type
+		if !col.Nullable {
+			definition += " NOT NULL"
+		}
+		columns = append(columns, definition)
+	}
+	if len(primaryColumns) > 0 {
+		columns = append(columns, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryColumns, ",")))
+	}
+	return columns
+}
+
+func (m *sqliteDriver) ColumnType(field *Field) string {
+	nativeType := strings.TrimSpace(field.NativeType)
+	if nativeType == "" {
+		switch field.Type {
+		case String:
+			nativeType = "TEXT"
+		case Integer:
+			nativeType = "INTEGER"
+		case Float:
+			nativeType = "REAL"
+		case Boolean:
+			nativeType = "INTEGER"
+		case Time:
+			nativeType = "DATETIME"
+		}
+	}
+	return nativeType
+}
+
+func (m *sqliteDriver) columnDefinition(field *Field) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(m.QuoteIdentifier(field.NativeName) + "\t")
+	buffer.WriteString(m.ColumnType(field))
+	if !field.IsPrimary {
+		if field.IsRequired() {
+			buffer.WriteString(" NOT NULL")
+		} else {
+			buffer.WriteString(" NULL")
+		}
+	}
+	return buffer.String()
+}
+
+func (m *sqliteDriver) Inspect(ctx context.Context, q sqlx.QueryerContext) (map[string]*TableInfo, error) {
+	var names []string
+	err := sqlx.SelectContext(ctx, q, &names, `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	tables := make(map[string]*TableInfo)
+	for _, name := range names {
+		var rows []struct {
+			Cid       int            `db:"cid"`
+			Name      string         `db:"name"`
+			Type      string         `db:"type"`
+			NotNull   bool           `db:"notnull"`
+			DfltValue sql.NullString `db:"dflt_value"`
+			Pk        int            `db:"pk"`
+		}
+		if err := sqlx.SelectContext(ctx, q, &rows, fmt.Sprintf("PRAGMA table_info(%s)", m.QuoteIdentifier(name))); err != nil {
+			return nil, err
+		}
+		var pkCount int
+		for _, row := range rows {
+			if row.Pk > 0 {
+				pkCount++
+			}
+		}
+		table := &TableInfo{Name: name}
+		for _, row := range rows {
+			table.Columns = append(table.Columns, &ColumnInfo{
+				Name:            row.Name,
+				Type:            row.Type,
+				Nullable:        !row.NotNull && row.Pk == 0,
+				IsPrimary:       row.Pk > 0,
+				IsAutoIncrement: row.Pk > 0 && pkCount == 1 && strings.EqualFold(row.Type, "INTEGER"),
+			})
+		}
+
+		var fks []struct {
+			ID       int            `db:"id"`
+			Seq      int            `db:"seq"`
+			Table    string         `db:"table"`
+			From     string         `db:"from"`
+			To       sql.NullString `db:"to"`
+			OnUpdate string         `db:"on_update"`
+			OnDelete string         `db:"on_delete"`
+			Match    string         `db:"match"`
+		}
+		if err := sqlx.SelectContext(ctx, q, &fks, fmt.Sprintf("PRAGMA foreign_key_list(%s)", m.QuoteIdentifier(name))); err != nil {
+			return nil, err
+		}
+		sort.SliceStable(fks, func(i, j int) bool {
+			return fks[i].ID < fks[j].ID || (fks[i].ID == fks[j].ID && fks[i].Seq < fks[j].Seq)
+		})
+		for _, fk := range fks {
+			// 未指定引用列时引用目标表主键，由调用方解析
+			table.addForeignKey(fmt.Sprintf("fk_%s_%d", name, fk.ID), fk.From, fk.Table, fk.To.String, fk.OnDelete, fk.OnUpdate)
+		}
+
+		if err := m.inspectIndexes(ctx, q, table); err != nil {
+			return nil, err
+		}
+		tables[name] = table
+	}
+	return tables, nil
+}
+
+// inspectIndexes 读取通过CREATE INDEX创建的索引，忽略主键及唯一约束自动创建的索引
+func (m *sqliteDriver) inspectIndexes(ctx context.Context, q sqlx.QueryerContext, table *TableInfo) error {
+	var list []struct {
+		Seq     int    `db:"seq"`
+		Name    string `db:"name"`
+		Unique  bool   `db:"unique"`
+		Origin  string `db:"origin"`
+		Partial bool   `db:"partial"`
+	}
+	if err := sqlx.SelectContext(ctx, q, &list, fmt.Sprintf("PRAGMA index_list(%s)", m.QuoteIdentifier(table.Name))); err != nil {
+		return err
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	for _, item := range list {
+		if item.Origin != "c" {
+			continue
+		}
+		var columns []struct {
+			Seqno int            `db:"seqno"`
+			Cid   int            `db:"cid"`
+			Name  sql.NullString `db:"name"`
+			Desc  bool           `db:"desc"`
+			Coll  sql.NullString `db:"coll"`
+			Key   bool           `db:"key"`
+		}
+		if err := sqlx.SelectContext(ctx, q, &columns, fmt.Sprintf("PRAGMA index_xinfo(%s)", m.QuoteIdentifier(item.Name))); err != nil {
+			return err
+		}
+		var where string
+		if item.Partial {
+			var ddl sql.NullString
+			if err := sqlx.GetContext(ctx, q, &ddl, "SELECT sql FROM sqlite_master WHERE type = 'index' AND name = ?", item.Name); err != nil {
+				return err
+			}
+			if i := strings.LastIndex(strings.ToUpper(ddl.String), " WHERE "); i >= 0 {
+				where = strings.TrimSpace(ddl.String[i+len(" WHERE "):])
+			}
+		}
+		for _, col := range columns {
+			if col.Key && col.Name.Valid {
+				table.addIndexColumn(item.Name, item.Unique, col.Name.String, col.Desc, where)
+			}
+		}
+	}
+	return nil
+}
+
+// AlterDDL SQLite不支持修改列及外键，类型或可空性变化、新增非空列及外键变更时通过重建表实现。
+// 重建表需在关闭外键约束（PRAGMA foreign_keys = OFF）时执行，否则删除原表会触发级联动作，Init会自动处理
+func (m *sqliteDriver) AlterDDL(sch *Schema, table *TableInfo, changes []*SchemaChange) {
+	tableName := m.QuoteIdentifier(sch.NativeName)
+	var (
+		rebuild *SchemaChange
+		dropFKs []*SchemaChange
+	)
+	for _, change := range changes {
+		switch change.Kind {
+		case ChangeAddColumn:
+			if columnNullable(change.Field) {
+				change.Statements = []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", tableName, m.columnDefinition(change.Field))}
+				continue
+			}
+			// 非空列由重建表新增并回填
+			change.Rewrite = true
+			rebuild = change
+		case ChangeAlterColumn, ChangeAddForeignKey, ChangeAlterForeignKey:
+			rebuild = change
+		case ChangeDropForeignKey:
+			dropFKs = append(dropFKs, change)
+		case ChangeDropColumn:
+			change.Statements = []string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, m.QuoteIdentifier(change.Column))}
+		case ChangeAddIndex:
+			change.Statements = []string{createIndexDDL(m, sch, change.Index)}
+		case ChangeAlterIndex:
+			change.Statements = []string{
+				fmt.Sprintf("DROP INDEX IF EXISTS %s", m.QuoteIdentifier(change.CurrentIndex.Name)),
+				createIndexDDL(m, sch, change.Index),
+			}
+		case ChangeDropIndex:
+			change.Statements = []string{fmt.Sprintf("DROP INDEX IF EXISTS %s", m.QuoteIdentifier(change.CurrentIndex.Name))}
+		}
+	}
+	// 仅删除外键时由删除外键的变更重建表，否则重建时保留待删除的外键（由下次同步删除）
+	dropping := rebuild == nil && len(dropFKs) > 0
+	if dropping {
+		rebuild = dropFKs[len(dropFKs)-1]
+	}
+	if rebuild == nil {
+		return
+	}
+	rebuild.Rewrite = true
+	rebuild.Statements = m.rebuildStatements(sch, table, changes, dropping)
+}
+
+// rebuildStatements 重建表：保留非模型列及未声明的索引（由删除语句单独处理），复制已有数据，
+// 新增的非空列及收紧约束的列使用默认值或零值回填。重建语句在所有新增可空列之后执行
+func (m *sqliteDriver) rebuildStatements(sch *Schema, table *TableInfo, changes []*SchemaChange, dropFKs bool) []string {
+	var (
+		extra    []*ColumnInfo
+		targets  []string
+		values   []string
+		fields   = sch.NativeFields()
+		name     = m.QuoteIdentifier(sch.NativeName)
+		tmpName  = m.QuoteIdentifier("__dba_tmp_" + sch.NativeName)
+		replaced = make(map[string]*ForeignKeyInfo)
+		dropped  = make(map[string]bool)
+	)
+	for _, col := range table.Columns {
+		f := fields[col.Name]
+		if f == nil || f.IsVirtual || m.ColumnType(f) == "" {
+			extra = append(extra, col)
+		}
+		column := m.QuoteIdentifier(col.Name)
+		targets = append(targets, column)
+		if f != nil && !f.IsPrimary && !columnNullable(f) && col.Nullable {
+			values = append(values, fmt.Sprintf("COALESCE(%s, %s)", column, backfillLiteral(m, f)))
+		} else {
+			values = append(values, column)
+		}
+	}
+	for _, change := range changes {
+		switch change.Kind {
+		case ChangeAddColumn:
+			if !columnNullable(change.Field) {
+				targets = append(targets, m.QuoteIdentifier(change.Column))
+				values = append(values, backfillLiteral(m, change.Field))
+			}
+		case ChangeAddForeignKey, ChangeAlterForeignKey:
+			replaced[strings.Join(change.ForeignKey.Columns, ",")] = change.ForeignKey
+		case ChangeDropForeignKey:
+			if dropFKs {
+				dropped[strings.Join(change.CurrentForeignKey.Columns, ",")] = true
+			}
+		}
+	}
+	definitions := m.tableColumns(sch, extra)
+	// 保留原表的外键约束及其动作，SQLite的外键名称由Inspect生成，重建时不声明约束名
+	for _, fk := range table.ForeignKeys {
+		key := strings.Join(fk.Columns, ",")
+		if dropped[key] || replaced[key] != nil {
+			continue
+		}
+		kept := *fk
+		kept.Name = ""
+		definitions = append(definitions, foreignKeyInfoDefinition(m, &kept))
+	}
+	for _, key := range sortedKeys(replaced) {
+		definitions = append(definitions, foreignKeyInfoDefinition(m, replaced[key]))
+	}
+	stmts := []string{
+		fmt.Sprintf("CREATE TABLE %s (\n%s\n)", tmpName, strings.Join(definitions, ",\n")),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", tmpName, strings.Join(targets, ", "), strings.Join(values, ", "), name),
+		fmt.Sprintf("DROP TABLE %s", name),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tmpName, name),
+	}
+	// 删除原表时索引一并删除，重建模型声明的索引及未声明的已有索引
+	declared := make(map[string]bool)
+	for _, idx := range sch.Indexes {
+		declared[idx.Name] = true
+		stmts = append(stmts, createIndexDDL(m, sch, idx))
+	}
+	for _, idx := range table.Indexes {
+		if !declared[idx.Name] {
+			stmts = append(stmts, createIndexDDL(m, sch, idx))
+		}
+	}
+	return stmts
+}
+
+// sqliteLockTable SQLite命名锁使用的锁表
+const sqliteLockTable = "dba_locks"
+
+// sqliteLockTTL 锁的有效期，持有期间定期续期，进程异常退出后锁在过期后可被其他进程获取
+const sqliteLockTTL = time.Minute
+
+// sqliteLock 当前进程持有的锁
+type sqliteLock struct {
+	name  string
+	owner string
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// AcquireLock SQLite不支持命名锁，通过锁表的唯一约束实现，锁记录持有者及过期时间
+func (m *sqliteDriver) AcquireLock(ctx context.Context, conn *sqlx.Conn, name string, timeout time.Duration) error {
+	table := m.QuoteIdentifier(sqliteLockTable)
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (`name` TEXT NOT NULL PRIMARY KEY, `owner` TEXT NOT NULL, `locked_at` INTEGER NOT NULL, `expires_at` INTEGER NOT NULL)", table)); err != nil {
+		return err
+	}
+	owner, err := lockOwner()
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		now := time.Now()
+		// 清理已过期的锁
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE `name` = ? AND `expires_at` < ?", table), name, now.UnixMilli()); err != nil {
+			return err
+		}
+		_, err := conn.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (`name`, `owner`, `locked_at`, `expires_at`) VALUES (?, ?, ?, ?)", table),
+			name, owner, now.UnixMilli(), now.Add(sqliteLockTTL).UnixMilli())
+		if err == nil {
+			lock := &sqliteLock{name: name, owner: owner, stop: make(chan struct{}), done: make(chan struct{})}
+			m.locks.Store(conn, lock)
+			go m.renewLock(conn, lock)
+			return nil
+		}
+		if !strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return errLockTimeout(name)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// renewLock 持有锁期间定期延长过期时间
+func (m *sqliteDriver) renewLock(conn *sqlx.Conn, lock *sqliteLock) {
+	defer close(lock.done)
+	ticker := time.NewTicker(sqliteLockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lock.stop:
+			return
+		case <-ticker.C:
+			_, _ = conn.ExecContext(context.Background(), fmt.Sprintf("UPDATE %s SET `expires_at` = ? WHERE `name` = ? AND `owner` = ?", m.QuoteIdentifier(sqliteLockTable)),
+				time.Now().Add(sqliteLockTTL).UnixMilli(), lock.name, lock.owner)
+		}
+	}
+}
+
+// ReleaseLock 释放当前连接持有的锁，只删除本进程写入的锁记录
+func (m *sqliteDriver) ReleaseLock(ctx context.Context, conn *sqlx.Conn, name string) error {
+	v, ok := m.locks.LoadAndDelete(conn)
+	if !ok {
+		return nil
+	}
+	lock := v.(*sqliteLock)
+	close(lock.stop)
+	<-lock.done
+	_, err := conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE `name` = ? AND `owner` = ?", m.QuoteIdentifier(sqliteLockTable)), lock.name, lock.owner)
+	return err
+}
+
+// lockOwner 生成锁持有者标识：主机名:进程号:随机串
+func lockOwner() (string, error) {
+	host, _ := os.Hostname()
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(b)), nil
+}
+
 func (m *sqliteDriver) CreateClauses() string {
 	return `INSERT {{if eq .ConflictKind "IGNORE"}}OR IGNORE {{end}}INTO {{.Table}} ({{.Columns}})
 			VALUES
@@ -83,6 +456,9 @@ func (m *sqliteDriver) CreateClauses() string {
 			{{if .ConflictUpdates}}
 			ON CONFLICT {{if .ConflictColumns}}({{.ConflictColumns}}){{end}} DO UPDATE 
 			SET {{.ConflictUpdates}}
+			{{end}}
+			{{if .Returning}}
+			RETURNING {{.Returning}}
 			{{end}}`
 }
 
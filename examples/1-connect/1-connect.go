@@ -2,19 +2,16 @@ package main
 
 import (
 	"github.com/iamdanielyin/dba"
+	_ "github.com/joho/godotenv/autoload"
 	"log"
 )
 
 func main() {
-	config := dba.ConnectConfig{
-		Driver: "mysql",
-		Dsn:    "root:yHD9xA4uXfGJ5v4d@tcp(127.0.0.1:3306)/dba?tls=skip-verify&charset=utf8mb4&parseTime=true&loc=Asia%2FShanghai",
-	}
-
-	sess, err := dba.Connect(&config)
-	if err != nil {
+	// DSN通过环境变量MYSQL_DSN（或MYSQL_DSN_FILE指向的文件）提供，可写在.env中
+	if err := dba.LoadConfig("dba.yaml"); err != nil {
 		log.Fatal(err)
 	}
 
-	log.Printf("Connected to %q with DSN:\n\t%q", sess.Name(), sess.DSN())
+	sess := dba.Session("default")
+	log.Printf("Connected to %q with driver %q", sess.Name(), sess.Driver())
 }
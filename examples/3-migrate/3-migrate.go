@@ -4,14 +4,26 @@ import (
 	"fmt"
 	"github.com/iamdanielyin/dba"
 	_ "github.com/joho/godotenv/autoload"
+	"log"
 	"os"
 	"time"
 )
 
 func main() {
-	schs := dba.SchemaBys()
-	if ddl := dba.Session().GenDDL(schs); ddl != "" {
+	conn := dba.Session()
+	diff, err := conn.Diff(dba.SchemaBys())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if ddl := diff.SQL(true, false); ddl != "" {
 		_ = dba.EnsureDir("migrations")
-		_ = os.WriteFile(fmt.Sprintf("migrations/%s.sql", time.Now().Format("20060102150405")), []byte(ddl), os.ModePerm)
+		_ = os.WriteFile(fmt.Sprintf("migrations/%s_auto.up.sql", time.Now().Format("20060102150405")), []byte(ddl), os.ModePerm)
+	}
+	applied, err := conn.Migrator().Up()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, m := range applied {
+		fmt.Println("applied", m.Version, m.Name)
 	}
 }
@@ -104,25 +104,25 @@ type Tag struct {
 }
 
 type AuditInfoTime struct {
-	CreatedAt time.Time  `dba:"name=创建时间"`
-	UpdatedAt time.Time  `dba:"name=最后修改时间"`
-	DeletedAt *time.Time `dba:"name=逻辑删除时间"`
+	CreatedAt time.Time  `dba:"name=创建时间;created_at"`
+	UpdatedAt time.Time  `dba:"name=最后修改时间;updated_at"`
+	DeletedAt *time.Time `dba:"name=逻辑删除时间;soft_delete"`
 }
 
 type AuditInfoUser struct {
-	CreatedByID      int    `dba:"name=创建人ID"`
-	CreatedByAccount string `dba:"name=创建人账号"`
-	CreatedByName    string `dba:"name=创建人名称"`
+	CreatedByID      int    `dba:"name=创建人ID;created_by=id"`
+	CreatedByAccount string `dba:"name=创建人账号;created_by=account"`
+	CreatedByName    string `dba:"name=创建人名称;created_by=name"`
 
-	UpdatedByID      int    `dba:"name=修改人ID"`
-	UpdatedByAccount string `dba:"name=修改人账号"`
-	UpdatedByName    string `dba:"name=修改人名称"`
+	UpdatedByID      int    `dba:"name=修改人ID;updated_by=id"`
+	UpdatedByAccount string `dba:"name=修改人账号;updated_by=account"`
+	UpdatedByName    string `dba:"name=修改人名称;updated_by=name"`
 
-	DeletedByID      int    `dba:"name=删除人ID"`
-	DeletedByAccount string `dba:"name=删除人账号"`
-	DeletedByName    string `dba:"name=删除人名称"`
+	DeletedByID      int    `dba:"name=删除人ID;deleted_by=id"`
+	DeletedByAccount string `dba:"name=删除人账号;deleted_by=account"`
+	DeletedByName    string `dba:"name=删除人名称;deleted_by=name"`
 }
 
 type AuditInfoOrg struct {
-	OrgCode int `dba:"name=组织代码"`
+	CreatedByOrg string `dba:"name=创建人组织代码;created_by=org"`
 }
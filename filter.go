@@ -50,6 +50,24 @@ type Entry struct {
 
 type Cond map[string]any
 
+// Expression 过滤值中直接内联的SQL表达式，Args按顺序对应SQL中的?占位符，
+// 可用于数据库函数、列比较及子查询，如Expr("LOWER(?)", v)、Raw("updated_at")
+type Expression struct {
+	SQL  string
+	Args []any
+	err  error
+}
+
+// Expr 创建带参数的SQL表达式
+func Expr(sql string, args ...any) *Expression {
+	return &Expression{SQL: sql, Args: args}
+}
+
+// Raw 创建不带参数的SQL表达式，SQL原样内联，不能包含用户输入
+func Raw(sql string) *Expression {
+	return &Expression{SQL: sql}
+}
+
 func parseEntryOp(rawKey string) (key string, op entryOp) {
 	rawKey = strings.TrimSpace(rawKey)
 	op = entryOpEqual
@@ -79,7 +97,7 @@ func Or(conditions ...any) *Filter {
 	filters := parseConditions(filterOperatorOr, conditions)
 	if len(filters) > 0 {
 		return &Filter{
-			operator:  filterOperatorAnd,
+			operator:  filterOperatorOr,
 			entryType: entryTypeFilterList,
 			entryList: filters,
 		}
@@ -0,0 +1,82 @@
+package dba
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type ExprUser struct {
+	ID    uint `dba:"pk"`
+	Name  string
+	Score int
+	Bonus int
+}
+
+type ExprOrder struct {
+	ID     uint `dba:"pk"`
+	UserID uint
+	Amount int
+}
+
+func TestExpressionFilter(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &ExprUser{}, &ExprOrder{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"INSERT INTO expr_user (id, name, score, bonus) VALUES (1, 'alice', 10, 5), (2, 'bob', 3, 8), (3, 'carol', 7, 7)",
+		"INSERT INTO expr_order (id, user_id, amount) VALUES (1, 1, 50), (2, 2, 150), (3, 3, 200), (4, 3, 20)",
+	} {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(conditions ...any) []uint {
+		t.Helper()
+		var users []*ExprUser
+		if err := conn.ns.Model("ExprUser").Find(conditions...).All(&users); err != nil {
+			t.Fatal(err)
+		}
+		var result []uint
+		for _, u := range users {
+			result = append(result, u.ID)
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+		return result
+	}
+	orders := func() *Result {
+		return conn.ns.Model("ExprOrder").Find("Amount >", 100).Select("UserID")
+	}
+	for _, tc := range []struct {
+		name       string
+		conditions []any
+		want       []uint
+	}{
+		{"function", []any{"Name", Expr("LOWER(?)", "ALICE")}, []uint{1}},
+		{"column", []any{"Score >", Raw("bonus")}, []uint{1}},
+		{"column expression", []any{"Score >=", Expr("bonus + ?", 0)}, []uint{1, 3}},
+		{"subquery", []any{"ID $IN", orders().AsSubquery()}, []uint{2, 3}},
+		{"not in subquery", []any{"ID $NIN", orders().AsSubquery()}, []uint{1}},
+		// Or的各条件之间为OR关系
+		{"or", []any{Or("Name", "alice", "Score", 7)}, []uint{1, 3}},
+		{"or groups", []any{Or(And("Name", "bob"), And("Score >", 8))}, []uint{1, 2}},
+		// 参数按出现顺序合并，包括嵌套的And/Or
+		{"nested", []any{Or(
+			And("Score >", Expr("? + ?", 5, 4), "Name", "alice"),
+			And("ID $IN", orders().AsSubquery(), "Bonus", Expr("?", 7)),
+		), "Name !=", "bob"}, []uint{1, 3}},
+	} {
+		if got := ids(tc.conditions...); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	var users []*ExprUser
+	if err := conn.ns.Model("ExprUser").Find("Name $LIKE", Expr("?", "a")).All(&users); err == nil {
+		t.Fatal("expected error for expression with $LIKE")
+	}
+	if err := conn.ns.Model("ExprUser").Find("ID $IN", conn.ns.Model("ExprOrder").Find("Missing", 1).AsSubquery()).All(&users); err == nil {
+		t.Fatal("expected subquery error to be returned")
+	}
+}
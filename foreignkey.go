@@ -0,0 +1,263 @@
+package dba
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ForeignKey 由关系推导的外键约束，字段均为字段名称
+type ForeignKey struct {
+	Name      string   `json:"name"`
+	Schema    string   `json:"schema"` // 外键所在模型
+	Fields    []string `json:"fields"`
+	RefSchema string   `json:"ref_schema"`
+	RefFields []string `json:"ref_fields"`
+	OnDelete  string   `json:"on_delete,omitempty"`
+	OnUpdate  string   `json:"on_update,omitempty"`
+}
+
+// foreignKeyAction 规范化外键动作，如set_null转换为SET NULL
+func foreignKeyAction(action string) string {
+	action = strings.ToUpper(strings.TrimSpace(strings.ReplaceAll(action, "_", " ")))
+	switch action {
+	case "CASCADE", "RESTRICT", "SET NULL", "SET DEFAULT", "NO ACTION":
+		return action
+	default:
+		return ""
+	}
+}
+
+// foreignKeys 根据模型关系推导外键，按所在模型分组：
+// HAS_ONE/HAS_MANY的外键在目标表，REF_ONE的外键在当前表，REF_MANY的外键在中间表。
+// 关系需声明fk或on_delete/on_update才生成外键，原生中间表始终生成指向两侧的外键
+func foreignKeys(schs map[string]*Schema) map[string][]*ForeignKey {
+	result := make(map[string][]*ForeignKey)
+	seen := make(map[string]bool)
+	add := func(owner string, field string, ref string, refField string, rel *Relation, defaultOnDelete string) {
+		ownerSch, refSch := schs[owner], schs[ref]
+		if ownerSch == nil || refSch == nil {
+			return
+		}
+		f, rf := ownerSch.Fields[field], refSch.Fields[refField]
+		if f == nil || rf == nil {
+			return
+		}
+		fk := &ForeignKey{
+			Name:      fmt.Sprintf("fk_%s_%s", ownerSch.NativeName, f.NativeName),
+			Schema:    owner,
+			Fields:    []string{field},
+			RefSchema: ref,
+			RefFields: []string{refField},
+			OnDelete:  foreignKeyAction(rel.OnDelete),
+			OnUpdate:  foreignKeyAction(rel.OnUpdate),
+		}
+		if fk.OnDelete == "" {
+			fk.OnDelete = defaultOnDelete
+		}
+		if seen[fk.Name] {
+			return
+		}
+		seen[fk.Name] = true
+		result[owner] = append(result[owner], fk)
+	}
+	for _, name := range sortedKeys(schs) {
+		sch := schs[name]
+		for _, fieldName := range sortedKeys(sch.Fields) {
+			rel := sch.Fields[fieldName].Relation
+			if !rel.Valid() {
+				continue
+			}
+			enabled := rel.ForeignKey || rel.OnDelete != "" || rel.OnUpdate != ""
+			switch rel.Kind {
+			case HasOne, HasMany:
+				if enabled {
+					add(rel.DstSchema, rel.DstField, name, rel.SrcField, rel, "")
+				}
+			case ReferencesOne:
+				if enabled {
+					add(name, rel.SrcField, rel.DstSchema, rel.DstField, rel, "")
+				}
+			case ReferencesMany:
+				if rel.BrgSchema == "" || (!enabled && !rel.BrgIsNative) {
+					continue
+				}
+				// 中间表数据随两侧数据删除
+				defaultOnDelete := ""
+				if rel.BrgIsNative {
+					defaultOnDelete = "CASCADE"
+				}
+				add(rel.BrgSchema, rel.BrgSrcField, name, rel.SrcField, rel, defaultOnDelete)
+				add(rel.BrgSchema, rel.BrgDstField, rel.DstSchema, rel.DstField, rel, defaultOnDelete)
+			}
+		}
+	}
+	return result
+}
+
+// info 转换为以表名、列名表示的外键
+func (fk *ForeignKey) info(schs map[string]*Schema) *ForeignKeyInfo {
+	nativeNames := func(sch *Schema, names []string) []string {
+		var columns []string
+		for _, name := range names {
+			columns = append(columns, sch.Fields[name].NativeName)
+		}
+		return columns
+	}
+	sch, refSch := schs[fk.Schema], schs[fk.RefSchema]
+	return &ForeignKeyInfo{
+		Name:       fk.Name,
+		Columns:    nativeNames(sch, fk.Fields),
+		RefTable:   refSch.NativeName,
+		RefColumns: nativeNames(refSch, fk.RefFields),
+		OnDelete:   inspectedForeignKeyAction(fk.OnDelete),
+		OnUpdate:   inspectedForeignKeyAction(fk.OnUpdate),
+	}
+}
+
+// foreignKeyDefinition 生成建表语句中的外键约束
+func foreignKeyDefinition(driver Driver, fk *ForeignKey, schs map[string]*Schema) string {
+	return foreignKeyInfoDefinition(driver, fk.info(schs))
+}
+
+// foreignKeyInfoDefinition 生成外键约束定义，名称为空时不声明约束名
+func foreignKeyInfoDefinition(driver Driver, fk *ForeignKeyInfo) string {
+	quoteColumns := func(names []string) string {
+		var columns []string
+		for _, name := range names {
+			columns = append(columns, driver.QuoteIdentifier(name))
+		}
+		return strings.Join(columns, ", ")
+	}
+	var definition string
+	if fk.Name != "" {
+		definition = fmt.Sprintf("CONSTRAINT %s ", driver.QuoteIdentifier(fk.Name))
+	}
+	definition += fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s", quoteColumns(fk.Columns), driver.QuoteIdentifier(fk.RefTable))
+	if len(fk.RefColumns) > 0 && fk.RefColumns[0] != "" {
+		definition += fmt.Sprintf(" (%s)", quoteColumns(fk.RefColumns))
+	}
+	if fk.OnDelete != "" {
+		definition += " ON DELETE " + fk.OnDelete
+	}
+	if fk.OnUpdate != "" {
+		definition += " ON UPDATE " + fk.OnUpdate
+	}
+	return definition
+}
+
+// tableForeignKeys 返回建表时可以声明的外键，循环依赖中引用后建表的外键由deferredForeignKeys在建表后添加
+func tableForeignKeys(driver Driver, name string, schs map[string]*Schema) []string {
+	deferred := make(map[string]bool)
+	for _, fk := range deferredForeignKeys(driver, schs) {
+		deferred[fk.Name] = true
+	}
+	var definitions []string
+	for _, fk := range foreignKeys(schs)[name] {
+		if !deferred[fk.Name] {
+			definitions = append(definitions, foreignKeyDefinition(driver, fk, schs))
+		}
+	}
+	return definitions
+}
+
+// deferredForeignKeys 返回循环依赖中引用表按sortSchemaNames排在后面、需在两张表都创建后再添加的外键。
+// SQLite建表时不检查引用表是否存在，且不支持ALTER TABLE ADD CONSTRAINT，外键始终在建表时声明
+func deferredForeignKeys(driver Driver, schs map[string]*Schema) []*ForeignKey {
+	if driver.Name() == SQLite {
+		return nil
+	}
+	position := make(map[string]int)
+	for i, n := range sortSchemaNames(schs) {
+		position[n] = i
+	}
+	fks := foreignKeys(schs)
+	var deferred []*ForeignKey
+	for _, name := range sortedKeys(fks) {
+		for _, fk := range fks[name] {
+			if fk.RefSchema != name && position[fk.RefSchema] > position[name] {
+				deferred = append(deferred, fk)
+			}
+		}
+	}
+	return deferred
+}
+
+// addForeignKeyDDL 生成为已有表添加外键的语句
+func addForeignKeyDDL(driver Driver, table string, fk *ForeignKeyInfo) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s", driver.QuoteIdentifier(table), foreignKeyInfoDefinition(driver, fk))
+}
+
+// deferredForeignKeyDDL 返回name建表后需要添加的外键语句，即sortedNames中先创建的表引用name的循环外键
+func deferredForeignKeyDDL(driver Driver, name string, sortedNames []string, schs map[string]*Schema) []string {
+	created := make(map[string]bool)
+	for _, n := range sortedNames {
+		if n == name {
+			break
+		}
+		created[n] = true
+	}
+	var stmts []string
+	for _, fk := range deferredForeignKeys(driver, schs) {
+		if fk.RefSchema == name && created[fk.Schema] {
+			stmts = append(stmts, addForeignKeyDDL(driver, schs[fk.Schema].NativeName, fk.info(schs)))
+		}
+	}
+	return stmts
+}
+
+// sortSchemaNames 按外键依赖排序模型名称，被引用的模型在前，无依赖关系时按名称排序
+func sortSchemaNames(schs map[string]*Schema) []string {
+	deps := make(map[string]map[string]bool)
+	for owner, fks := range foreignKeys(schs) {
+		for _, fk := range fks {
+			if fk.RefSchema == owner {
+				continue
+			}
+			if deps[owner] == nil {
+				deps[owner] = make(map[string]bool)
+			}
+			deps[owner][fk.RefSchema] = true
+		}
+	}
+	var (
+		names   = sortedKeys(schs)
+		sorted  []string
+		visited = make(map[string]bool)
+	)
+	for len(sorted) < len(names) {
+		progressed := false
+		for _, name := range names {
+			if visited[name] {
+				continue
+			}
+			ready := true
+			for dep := range deps[name] {
+				if !visited[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				visited[name] = true
+				sorted = append(sorted, name)
+				progressed = true
+			}
+		}
+		if !progressed {
+			// 循环依赖：按名称顺序取第一个，其引用后建表的外键在建表后添加
+			for _, name := range names {
+				if !visited[name] {
+					visited[name] = true
+					sorted = append(sorted, name)
+					break
+				}
+			}
+		}
+	}
+	return sorted
+}
+
+// ForeignKeys 返回命名空间下模型关系推导的外键，按所在模型分组
+func (ns *Namespace) ForeignKeys() map[string][]*ForeignKey {
+	return foreignKeys(ns.SchemaBys())
+}
@@ -0,0 +1,111 @@
+package dba
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// CycDept 与CycUser互相引用
+type CycDept struct {
+	ID        uint     `dba:"pk;incr"`
+	ManagerID *uint    `dba:""`
+	Manager   *CycUser `dba:"rel=REF_ONE,ManagerID->ID;on_delete=set_null"`
+}
+
+type CycUser struct {
+	ID     uint     `dba:"pk;incr"`
+	DeptID *uint    `dba:""`
+	Dept   *CycDept `dba:"rel=REF_ONE,DeptID->ID;fk"`
+}
+
+func TestCyclicForeignKeys(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &CycDept{}, &CycUser{})
+	schs := conn.ns.SchemaBys()
+	sortedNames := sortSchemaNames(schs)
+	if len(sortedNames) != 2 {
+		t.Fatalf("unexpected schemas: %v", sortedNames)
+	}
+	first, second := schs[sortedNames[0]].NativeName, schs[sortedNames[1]].NativeName
+
+	for _, name := range []string{MySQL, PostgreSQL} {
+		driver := drivers[name]
+		stmts := splitStatements(driver, driver.GenDDL(sortedNames, schs, true))
+		var creates, alters []string
+		for _, stmt := range stmts {
+			if strings.HasPrefix(stmt, "CREATE TABLE") {
+				creates = append(creates, stmt)
+			} else if strings.HasPrefix(stmt, "ALTER TABLE") {
+				alters = append(alters, stmt)
+			}
+		}
+		// 先建的表不能引用后建的表，外键在两张表都创建后添加
+		if len(creates) != 2 || strings.Contains(creates[0], "REFERENCES") || !strings.Contains(creates[1], "REFERENCES") {
+			t.Fatalf("%s: unexpected create statements: %v", name, creates)
+		}
+		if len(alters) != 1 || !strings.Contains(alters[0], driver.QuoteIdentifier(first)) ||
+			!strings.Contains(alters[0], "REFERENCES "+driver.QuoteIdentifier(second)) {
+			t.Fatalf("%s: unexpected deferred foreign keys: %v", name, alters)
+		}
+		// 单独生成先建的表时同样不声明循环外键
+		if ddl := driver.GenDDL(sortedNames[:1], schs, true); strings.Contains(ddl, "REFERENCES") {
+			t.Fatalf("%s: cyclic foreign key declared in create table: %s", name, ddl)
+		}
+	}
+
+	// SQLite建表时声明全部外键
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := conn.driver.Inspect(context.Background(), conn.xdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables[first].ForeignKeys) != 1 || len(tables[second].ForeignKeys) != 1 {
+		t.Fatalf("unexpected foreign keys: %+v, %+v", tables[first].ForeignKeys, tables[second].ForeignKeys)
+	}
+	if diff, err := conn.Diff(); err != nil {
+		t.Fatal(err)
+	} else if !diff.Empty() {
+		t.Fatalf("expected no changes, got %s", diff.SQL(true, true))
+	}
+}
+
+type BrgUser struct {
+	ID    uint       `dba:"pk;incr"`
+	Roles []*BrgRole `dba:"rel=REF_MANY,BrgUserRole(ID->UserID,ID->RoleID)"`
+}
+
+type BrgRole struct {
+	ID uint `dba:"pk;incr"`
+}
+
+type BrgUserRole struct {
+	UserID uint `dba:"pk"`
+	RoleID uint `dba:"pk"`
+	Note   string
+}
+
+func TestNativeBridgeRelation(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &BrgUser{}, &BrgRole{})
+	isNative := func() bool {
+		return conn.ns.SchemaBy("BrgUser").Fields["Roles"].Relation.BrgIsNative
+	}
+	if !isNative() {
+		t.Fatal("expected native bridge")
+	}
+	// 重复解析关系不改变原生中间表的判断
+	if err := conn.ns.RegisterSchema(&BrgRole{}); err != nil {
+		t.Fatal(err)
+	}
+	if !isNative() {
+		t.Fatal("native bridge changed after re-registering")
+	}
+	// 注册同名模型后使用该模型作为中间表
+	if err := conn.ns.RegisterSchema(&BrgUserRole{}); err != nil {
+		t.Fatal(err)
+	}
+	if isNative() {
+		t.Fatal("registered bridge schema treated as native")
+	}
+}
@@ -0,0 +1,182 @@
+package dba
+
+import (
+	"context"
+	"reflect"
+)
+
+// HookEvent 模型生命周期事件
+type HookEvent string
+
+const (
+	HookBeforeCreate HookEvent = "before_create"
+	HookAfterCreate  HookEvent = "after_create"
+	HookBeforeUpdate HookEvent = "before_update"
+	HookAfterUpdate  HookEvent = "after_update"
+	HookBeforeDelete HookEvent = "before_delete"
+	HookAfterDelete  HookEvent = "after_delete"
+	HookAfterFind    HookEvent = "after_find"
+)
+
+// HookScope 钩子执行时的上下文信息
+type HookScope struct {
+	Event        HookEvent
+	Model        *DataModel
+	Result       *Result // 更新、删除、查询时的查询条件
+	Doc          any     // 新增、查询时为单条数据，更新时为更新内容，删除时为nil
+	RowsAffected int     // 更新、删除后的影响行数
+}
+
+// HookFunc 全局钩子函数，返回错误时中止操作
+type HookFunc func(ctx context.Context, scope *HookScope) error
+
+// 模型结构体可实现的钩子接口，删除钩子以模型零值作为接收者
+type (
+	BeforeCreateHook interface {
+		BeforeCreate(ctx context.Context, scope *HookScope) error
+	}
+	AfterCreateHook interface {
+		AfterCreate(ctx context.Context, scope *HookScope) error
+	}
+	BeforeUpdateHook interface {
+		BeforeUpdate(ctx context.Context, scope *HookScope) error
+	}
+	AfterUpdateHook interface {
+		AfterUpdate(ctx context.Context, scope *HookScope) error
+	}
+	BeforeDeleteHook interface {
+		BeforeDelete(ctx context.Context, scope *HookScope) error
+	}
+	AfterDeleteHook interface {
+		AfterDelete(ctx context.Context, scope *HookScope) error
+	}
+	AfterFindHook interface {
+		AfterFind(ctx context.Context, scope *HookScope) error
+	}
+)
+
+// AllSchemas 注册全局钩子时匹配所有模型
+const AllSchemas = "*"
+
+// RegisterHook 按模型名称注册全局钩子，模型名称为AllSchemas时作用于所有模型
+func (ns *Namespace) RegisterHook(schemaName string, event HookEvent, fn HookFunc) {
+	ns.hooksMu.Lock()
+	defer ns.hooksMu.Unlock()
+	if ns.hooks == nil {
+		ns.hooks = make(map[string]map[HookEvent][]HookFunc)
+	}
+	if ns.hooks[schemaName] == nil {
+		ns.hooks[schemaName] = make(map[HookEvent][]HookFunc)
+	}
+	ns.hooks[schemaName][event] = append(ns.hooks[schemaName][event], fn)
+}
+
+// UnregisterHooks 移除模型的全局钩子，未指定事件时移除全部
+func (ns *Namespace) UnregisterHooks(schemaName string, events ...HookEvent) {
+	ns.hooksMu.Lock()
+	defer ns.hooksMu.Unlock()
+	if len(events) == 0 {
+		delete(ns.hooks, schemaName)
+		return
+	}
+	for _, event := range events {
+		delete(ns.hooks[schemaName], event)
+	}
+}
+
+func (ns *Namespace) hooksOf(schemaName string, event HookEvent) []HookFunc {
+	ns.hooksMu.RLock()
+	defer ns.hooksMu.RUnlock()
+	var fns []HookFunc
+	fns = append(fns, ns.hooks[AllSchemas][event]...)
+	if schemaName != AllSchemas {
+		fns = append(fns, ns.hooks[schemaName][event]...)
+	}
+	return fns
+}
+
+// runHooks 依次执行模型结构体钩子和全局钩子
+func (dm *DataModel) runHooks(ctx context.Context, scope *HookScope) error {
+	scope.Model = dm
+	receiver := scope.Doc
+	if receiver == nil && dm.schema.structType != nil {
+		receiver = reflect.New(dm.schema.structType).Interface()
+	}
+	var err error
+	switch scope.Event {
+	case HookBeforeCreate:
+		if h, ok := receiver.(BeforeCreateHook); ok {
+			err = h.BeforeCreate(ctx, scope)
+		}
+	case HookAfterCreate:
+		if h, ok := receiver.(AfterCreateHook); ok {
+			err = h.AfterCreate(ctx, scope)
+		}
+	case HookBeforeUpdate:
+		if h, ok := receiver.(BeforeUpdateHook); ok {
+			err = h.BeforeUpdate(ctx, scope)
+		}
+	case HookAfterUpdate:
+		if h, ok := receiver.(AfterUpdateHook); ok {
+			err = h.AfterUpdate(ctx, scope)
+		}
+	case HookBeforeDelete:
+		if h, ok := receiver.(BeforeDeleteHook); ok {
+			err = h.BeforeDelete(ctx, scope)
+		}
+	case HookAfterDelete:
+		if h, ok := receiver.(AfterDeleteHook); ok {
+			err = h.AfterDelete(ctx, scope)
+		}
+	case HookAfterFind:
+		if h, ok := receiver.(AfterFindHook); ok {
+			err = h.AfterFind(ctx, scope)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	for _, fn := range dm.conn.ns.hooksOf(dm.schema.Name, scope.Event) {
+		if err := fn(ctx, scope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runDocHooks 对每条数据执行钩子
+func (dm *DataModel) runDocHooks(ctx context.Context, event HookEvent, docs []reflect.Value) error {
+	for _, doc := range docs {
+		if err := dm.runHooks(ctx, &HookScope{Event: event, Doc: settableOf(doc)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// afterFind 查询完成后对结果中的每条数据执行AfterFind钩子
+func (r *Result) afterFind(ctx context.Context, scope *HookScope, dst any) error {
+	ru := NewReflectValue(dst)
+	if !ru.IsArray() {
+		scope.Doc = dst
+		return r.dm.runHooks(ctx, scope)
+	}
+	for i := 0; i < ru.Len(); i++ {
+		item := *scope
+		item.Doc = settableOf(ru.Index(i))
+		if err := r.dm.runHooks(ctx, &item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// abort 出错（如钩子返回错误）时回滚模型自行开启的事务，调用方通过ModelOptions.Tx传入的事务由调用方处理
+func (dm *DataModel) abort(err error) error {
+	if dm.xtx != nil && dm.ownTx {
+		_ = dm.xtx.Rollback()
+		dm.xtx = nil
+		dm.ownTx = false
+	}
+	return err
+}
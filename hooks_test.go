@@ -0,0 +1,157 @@
+package dba
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type HookItem struct {
+	ID   uint `dba:"pk;incr"`
+	Name string
+}
+
+// OrderHookItem 结构体钩子记录执行顺序
+type OrderHookItem struct {
+	ID     uint `dba:"pk;incr"`
+	Name   string
+	Loaded bool `dba:"-"`
+}
+
+var hookEvents []string
+
+func (o *OrderHookItem) BeforeCreate(ctx context.Context, scope *HookScope) error {
+	hookEvents = append(hookEvents, "struct:before_create:"+o.Name)
+	if o.Name == "invalid" {
+		return errors.New("invalid name")
+	}
+	o.Name = strings.ToUpper(o.Name)
+	return nil
+}
+
+func (o *OrderHookItem) AfterCreate(ctx context.Context, scope *HookScope) error {
+	hookEvents = append(hookEvents, "struct:after_create:"+o.Name)
+	return nil
+}
+
+func (o *OrderHookItem) AfterFind(ctx context.Context, scope *HookScope) error {
+	o.Loaded = true
+	return nil
+}
+
+func TestHookOrder(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &OrderHookItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	hookEvents = nil
+	t.Cleanup(func() { hookEvents = nil })
+	record := func(prefix string) HookFunc {
+		return func(ctx context.Context, scope *HookScope) error {
+			hookEvents = append(hookEvents, prefix+":"+string(scope.Event))
+			return nil
+		}
+	}
+	for _, event := range []HookEvent{HookBeforeCreate, HookAfterCreate, HookBeforeUpdate, HookAfterUpdate} {
+		conn.ns.RegisterHook("OrderHookItem", event, record("model"))
+		conn.ns.RegisterHook(AllSchemas, event, record("all"))
+	}
+	model := func() *DataModel { return conn.ns.Model("OrderHookItem") }
+
+	// 结构体钩子先于全局钩子，AllSchemas先于按模型注册的钩子
+	if err := model().Create(&OrderHookItem{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ns.Model("OrderHookItem", &ModelOptions{Tx: tx}).Find("Name", "A").Update(map[string]any{"Name": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"struct:before_create:a", "all:before_create", "model:before_create",
+		"struct:after_create:A", "all:after_create", "model:after_create",
+		"all:before_update", "model:before_update",
+		"all:after_update", "model:after_update",
+	}
+	if !reflect.DeepEqual(hookEvents, want) {
+		t.Fatalf("got %v, want %v", hookEvents, want)
+	}
+
+	var items []*OrderHookItem
+	if err := model().Find().All(&items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "b" || !items[0].Loaded {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+
+	// 前置钩子返回错误时中止写入，后置钩子返回错误时回滚
+	hookEvents = nil
+	if err := model().Create([]*OrderHookItem{{Name: "c"}, {Name: "invalid"}}); err == nil {
+		t.Fatal("expected before create error")
+	}
+	errAbort := errors.New("abort")
+	conn.ns.RegisterHook("OrderHookItem", HookAfterCreate, func(ctx context.Context, scope *HookScope) error {
+		return errAbort
+	})
+	conn.ns.RegisterHook("OrderHookItem", HookAfterUpdate, func(ctx context.Context, scope *HookScope) error {
+		return errAbort
+	})
+	if err := model().Create(&OrderHookItem{Name: "d"}); !errors.Is(err, errAbort) {
+		t.Fatalf("expected after create error, got %v", err)
+	}
+	if _, err := model().Find("Name", "b").Update(map[string]any{"Name": "e"}); !errors.Is(err, errAbort) {
+		t.Fatalf("expected after update error, got %v", err)
+	}
+	if err := model().Find().All(&items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "b" {
+		t.Fatalf("expected writes rolled back, got %+v", items)
+	}
+}
+
+type SoftHookItem struct {
+	ID        uint `dba:"pk;incr"`
+	Name      string
+	DeletedAt *time.Time `dba:"soft_delete"`
+}
+
+func TestDeleteHookRollback(t *testing.T) {
+	conn := newTestConnection(t, filepath.Join(t.TempDir(), "test.db"), &HookItem{}, &SoftHookItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	errAbort := errors.New("abort")
+	for _, name := range []string{"HookItem", "SoftHookItem"} {
+		conn.ns.RegisterHook(name, HookAfterDelete, func(ctx context.Context, scope *HookScope) error {
+			return errAbort
+		})
+		if err := conn.ns.Model(name).Create(map[string]any{"Name": "a"}); err != nil {
+			t.Fatal(err)
+		}
+		// 删除后钩子失败时回滚删除（软删除及物理删除）
+		if _, err := conn.ns.Model(name).Find("Name", "a").Delete(); !errors.Is(err, errAbort) {
+			t.Fatalf("%s: expected hook error, got %v", name, err)
+		}
+		if _, err := conn.ns.Model(name).Find("Name", "a").HardDelete(); !errors.Is(err, errAbort) {
+			t.Fatalf("%s: expected hook error, got %v", name, err)
+		}
+		if n, err := conn.ns.Model(name).Find("Name", "a").Count(); err != nil || n != 1 {
+			t.Fatalf("%s: expected delete rolled back, got %d, %v", name, n, err)
+		}
+		conn.ns.UnregisterHooks(name)
+		if n, err := conn.ns.Model(name).Find("Name", "a").Delete(); err != nil || n != 1 {
+			t.Fatalf("%s: expected delete, got %d, %v", name, n, err)
+		}
+	}
+}
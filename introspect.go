@@ -0,0 +1,467 @@
+package dba
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+)
+
+// introspectFieldOrderKey 反向生成的模型在缓存中记录字段顺序（与表中列顺序一致）
+const introspectFieldOrderKey = "INTROSPECT_FIELD_ORDER"
+
+type IntrospectOptions struct {
+	Tables  []string // 仅读取指定的表，默认读取全部
+	Exclude []string // 排除的表，迁移记录表及锁表始终排除
+}
+
+func (c *Connection) IntrospectSchemas(options ...*IntrospectOptions) ([]*Schema, error) {
+	return c.IntrospectSchemasContext(context.Background(), options...)
+}
+
+// IntrospectSchemasContext 读取数据库中已有的表结构生成模型，并根据外键推导REF_ONE/HAS_MANY关系
+func (c *Connection) IntrospectSchemasContext(ctx context.Context, options ...*IntrospectOptions) ([]*Schema, error) {
+	var opts IntrospectOptions
+	if len(options) > 0 && options[0] != nil {
+		opts = *options[0]
+	}
+	tables, err := c.driver.Inspect(ctx, c.xdb)
+	if err != nil {
+		return nil, err
+	}
+	include := make(map[string]bool)
+	for _, name := range opts.Tables {
+		include[name] = true
+	}
+	exclude := map[string]bool{DefaultMigrationTable: true, sqliteLockTable: true}
+	for _, name := range opts.Exclude {
+		exclude[name] = true
+	}
+
+	var names []string
+	for name := range tables {
+		if exclude[name] || (len(include) > 0 && !include[name]) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	byTable := make(map[string]*Schema)
+	var result []*Schema
+	for _, name := range names {
+		sch := c.introspectSchema(tables[name])
+		byTable[name] = sch
+		result = append(result, sch)
+	}
+	for _, name := range names {
+		for _, fk := range tables[name].ForeignKeys {
+			introspectRelation(byTable[name], byTable[fk.RefTable], fk)
+		}
+	}
+	return result, nil
+}
+
+func (c *Connection) introspectSchema(table *TableInfo) *Schema {
+	sch := &Schema{
+		Name:        goIdentifier(table.Name),
+		NativeName:  table.Name,
+		Description: table.Comment,
+		Fields:      make(map[string]*Field),
+	}
+	var order []string
+	for _, col := range table.Columns {
+		name := goIdentifier(col.Name)
+		if _, exists := sch.Fields[name]; exists || name == "" {
+			continue
+		}
+		field := &Field{
+			Name:            name,
+			NativeName:      col.Name,
+			Title:           col.Comment,
+			IsPrimary:       col.IsPrimary,
+			IsAutoIncrement: col.IsAutoIncrement,
+		}
+		nativeType := col.Type
+		field.Type, field.IsUnsigned = schemaTypeOf(nativeType)
+		if field.IsUnsigned {
+			nativeType = strings.TrimSpace(unsignedPattern.ReplaceAllString(nativeType, ""))
+		}
+		if !col.Nullable && !col.IsPrimary {
+			field.RequiredConfig = "true"
+		}
+		// 与驱动默认类型一致时不记录原生类型
+		if !sameColumnType(col.Type, c.driver.ColumnType(field)) {
+			field.NativeType = nativeType
+		}
+		sch.Fields[name] = field
+		order = append(order, name)
+	}
+	sch.Cache().Store(introspectFieldOrderKey, order)
+	introspectIndexes(sch, table)
+	return sch
+}
+
+// introspectIndexes 将数据库索引转换为模型索引，并回填字段的索引配置用于生成标签
+func introspectIndexes(sch *Schema, table *TableInfo) {
+	fields := sch.NativeFields()
+	for _, current := range table.Indexes {
+		idx := current.Clone()
+		var valid = len(idx.Columns) > 0
+		for _, col := range idx.Columns {
+			if f := fields[col.Name]; f != nil {
+				col.Name = f.Name
+			} else {
+				valid = false
+			}
+		}
+		if !valid {
+			continue
+		}
+		sch.Indexes = append(sch.Indexes, idx)
+		for _, col := range idx.Columns {
+			f := sch.Fields[col.Name]
+			config, prefix := &f.IndexConfig, "idx"
+			if idx.Unique {
+				config, prefix = &f.UniqueConfig, "uk"
+			}
+			value := idx.Name
+			if len(idx.Columns) == 1 && value == fmt.Sprintf("%s_%s_%s", prefix, sch.NativeName, f.NativeName) {
+				value = "true"
+			}
+			if *config != "" {
+				value = *config + "," + value
+			}
+			*config = value
+			if col.Desc {
+				f.IndexOrder = "DESC"
+			}
+			if idx.Where != "" {
+				f.IndexWhere = idx.Where
+			}
+		}
+	}
+}
+
+// introspectRelation 根据单列外键在子表生成REF_ONE关系，在父表生成HAS_MANY关系，
+// 外键约束（含删除、更新动作）记录在REF_ONE关系上，重新建表时保留
+func introspectRelation(child, parent *Schema, fk *ForeignKeyInfo) {
+	if child == nil || parent == nil || len(fk.Columns) != 1 {
+		return
+	}
+	srcField := child.NativeFields()[fk.Columns[0]]
+	dstField := parent.PrimaryField()
+	if fk.RefColumns[0] != "" {
+		dstField = parent.NativeFields()[fk.RefColumns[0]]
+	}
+	if srcField == nil || dstField == nil {
+		return
+	}
+
+	refName := strings.TrimSuffix(srcField.Name, "ID")
+	if refName == "" || child.Fields[refName] != nil {
+		refName = parent.Name
+	}
+	if child.Fields[refName] != nil {
+		refName = parent.Name + "By" + srcField.Name
+	}
+	addIntrospectField(child, &Field{
+		Name:           refName,
+		NativeName:     strcase.ToSnake(refName),
+		Type:           Object,
+		RelationConfig: fmt.Sprintf("REF_ONE,%s->%s", srcField.Name, dstField.Name),
+		Relation: &Relation{
+			Kind:       ReferencesOne,
+			Field:      refName,
+			SrcSchema:  child.Name,
+			SrcField:   srcField.Name,
+			DstSchema:  parent.Name,
+			DstField:   dstField.Name,
+			ForeignKey: true,
+			OnDelete:   relationAction(fk.OnDelete),
+			OnUpdate:   relationAction(fk.OnUpdate),
+		},
+	})
+
+	manyName := pluralize(child.Name)
+	if parent.Fields[manyName] != nil {
+		manyName = manyName + "By" + srcField.Name
+	}
+	addIntrospectField(parent, &Field{
+		Name:           manyName,
+		NativeName:     strcase.ToSnake(manyName),
+		Type:           Array,
+		ItemType:       child.Name,
+		RelationConfig: fmt.Sprintf("HAS_MANY,%s->%s", dstField.Name, srcField.Name),
+		Relation: &Relation{
+			Kind:      HasMany,
+			Field:     manyName,
+			SrcSchema: parent.Name,
+			SrcField:  dstField.Name,
+			DstSchema: child.Name,
+			DstField:  srcField.Name,
+		},
+	})
+}
+
+// relationAction 将数据库中的外键动作转换为标签写法，如SET NULL转换为set_null，NO ACTION视为未设置
+func relationAction(action string) string {
+	return strings.ToLower(strings.ReplaceAll(inspectedForeignKeyAction(action), " ", "_"))
+}
+
+func addIntrospectField(sch *Schema, field *Field) {
+	if sch.Fields[field.Name] != nil {
+		return
+	}
+	sch.Fields[field.Name] = field
+	sch.Cache().Delete("NATIVE_FIELDS")
+	sch.Cache().Delete("SCALAR_FIELDS")
+	if v, ok := sch.Cache().Load(introspectFieldOrderKey); ok {
+		sch.Cache().Store(introspectFieldOrderKey, append(v.([]string), field.Name))
+	}
+}
+
+var (
+	unsignedPattern   = regexp.MustCompile(`(?i)\s+unsigned\b`)
+	identifierPattern = regexp.MustCompile(`[^0-9A-Za-z_]+`)
+)
+
+// schemaTypeOf 根据原生列类型推导字段类型
+func schemaTypeOf(nativeType string) (SchemaType, bool) {
+	t := normalizeColumnType(nativeType)
+	unsigned := unsignedPattern.MatchString(" " + t)
+	base := t
+	if i := strings.IndexAny(t, "( "); i > 0 {
+		base = t[:i]
+	}
+	switch {
+	case t == "tinyint(1)" || base == "boolean":
+		return Boolean, false
+	case base == "integer" || base == "int2" || strings.HasSuffix(base, "int") || strings.HasSuffix(base, "serial"):
+		return Integer, unsigned
+	case base == "numeric" || base == "real" || base == "float" || strings.HasPrefix(t, "double"):
+		return Float, unsigned
+	case strings.HasPrefix(base, "timestamp") || base == "datetime" || base == "date" || base == "time":
+		return Time, false
+	default:
+		return String, false
+	}
+}
+
+// goIdentifier 将表名或列名转换为导出的Go标识符，ID等缩写保持大写
+func goIdentifier(name string) string {
+	name = identifierPattern.ReplaceAllString(name, "_")
+	var buffer strings.Builder
+	for _, word := range strings.Split(strcase.ToSnake(name), "_") {
+		if word == "" {
+			continue
+		}
+		switch word {
+		case "id", "url", "uuid", "ip", "api", "json", "sql", "http":
+			buffer.WriteString(strings.ToUpper(word))
+		default:
+			buffer.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	s := buffer.String()
+	if s != "" && s[0] >= '0' && s[0] <= '9' {
+		s = "T" + s
+	}
+	return s
+}
+
+func pluralize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	case strings.HasSuffix(name, "y") && len(name) > 1 && !strings.ContainsAny(name[len(name)-2:len(name)-1], "aeiou"):
+		return name[:len(name)-1] + "ies"
+	default:
+		return name + "s"
+	}
+}
+
+type GenStructsOptions struct {
+	Package string // 包名，默认models
+}
+
+// GenStructs 根据模型生成带dba标签的Go结构体源码
+func GenStructs(schs []*Schema, options ...*GenStructsOptions) ([]byte, error) {
+	var opts GenStructsOptions
+	if len(options) > 0 && options[0] != nil {
+		opts = *options[0]
+	}
+	if opts.Package == "" {
+		opts.Package = "models"
+	}
+	sorted := append([]*Schema(nil), schs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	var (
+		body       bytes.Buffer
+		importTime bool
+		importDBA  bool
+	)
+	for _, sch := range sorted {
+		if sch.Description != "" {
+			body.WriteString(fmt.Sprintf("// %s %s\n", sch.Name, sch.Description))
+		}
+		body.WriteString(fmt.Sprintf("type %s struct {\n", sch.Name))
+		for _, field := range structFieldOrder(sch) {
+			goType := goTypeOf(field)
+			if strings.Contains(goType, "time.") {
+				importTime = true
+			}
+			body.WriteString(fmt.Sprintf("\t%s %s", field.Name, goType))
+			if tag := structTagOf(field); tag != "" {
+				body.WriteString(fmt.Sprintf(" `dba:%q`", tag))
+			}
+			body.WriteString("\n")
+		}
+		body.WriteString("}\n\n")
+		if sch.NativeName != strcase.ToSnake(sch.Name) {
+			importDBA = true
+			body.WriteString(fmt.Sprintf("func (%s) Schema() dba.Schema {\n\treturn dba.Schema{NativeName: %q}\n}\n\n", sch.Name, sch.NativeName))
+		}
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("package %s\n\n", opts.Package))
+	if importTime || importDBA {
+		buffer.WriteString("import (\n")
+		if importTime {
+			buffer.WriteString("\t\"time\"\n")
+		}
+		if importDBA {
+			buffer.WriteString("\t\"github.com/iamdanielyin/dba\"\n")
+		}
+		buffer.WriteString(")\n\n")
+	}
+	buffer.Write(body.Bytes())
+	return format.Source(buffer.Bytes())
+}
+
+// structFieldOrder 反向生成的模型按列顺序输出，其余按主键、普通字段、关系字段排序
+func structFieldOrder(sch *Schema) []*Field {
+	if v, ok := sch.Cache().Load(introspectFieldOrderKey); ok {
+		var fields []*Field
+		for _, name := range v.([]string) {
+			if f := sch.Fields[name]; f != nil {
+				fields = append(fields, f)
+			}
+		}
+		if len(fields) == len(sch.Fields) {
+			return fields
+		}
+	}
+	var fields []*Field
+	for _, f := range sch.Fields {
+		if !f.IsVirtual {
+			fields = append(fields, f)
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i], fields[j]
+		if a.IsPrimary != b.IsPrimary {
+			return a.IsPrimary
+		}
+		if (a.Relation != nil) != (b.Relation != nil) {
+			return a.Relation == nil
+		}
+		return a.Name < b.Name
+	})
+	return fields
+}
+
+func goTypeOf(field *Field) string {
+	switch field.Type {
+	case Object:
+		if field.Relation != nil && field.Relation.DstSchema != "" {
+			return "*" + field.Relation.DstSchema
+		}
+		return "map[string]any"
+	case Array:
+		if field.Relation != nil && field.ItemType != "" {
+			return "[]*" + field.ItemType
+		}
+		return "[]any"
+	}
+	var goType string
+	switch field.Type {
+	case Integer:
+		goType = "int"
+		if field.IsUnsigned {
+			goType = "uint"
+		}
+	case Float:
+		goType = "float64"
+	case Boolean:
+		goType = "bool"
+	case Time:
+		goType = "time.Time"
+	default:
+		goType = "string"
+	}
+	if !field.IsPrimary && !field.IsRequired() {
+		goType = "*" + goType
+	}
+	return goType
+}
+
+func structTagOf(field *Field) string {
+	var items []string
+	if field.Title != "" {
+		items = append(items, "name="+strings.ReplaceAll(field.Title, ";", ","))
+	}
+	if field.Relation == nil && field.NativeName != strcase.ToSnake(field.Name) {
+		items = append(items, "native="+field.NativeName)
+	}
+	if field.NativeType != "" {
+		items = append(items, "type="+field.NativeType)
+	}
+	if field.IsPrimary {
+		items = append(items, "pk")
+	}
+	if field.IsAutoIncrement {
+		items = append(items, "incr")
+	}
+	if field.IsRequired() && !field.IsPrimary {
+		items = append(items, "req")
+	}
+	for _, item := range []struct{ key, value string }{
+		{"index", field.IndexConfig},
+		{"unique", field.UniqueConfig},
+		{"index_order", field.IndexOrder},
+		{"index_where", strings.ReplaceAll(field.IndexWhere, ";", "")},
+	} {
+		switch item.value {
+		case "":
+		case "true":
+			items = append(items, item.key)
+		default:
+			items = append(items, item.key+"="+item.value)
+		}
+	}
+	if field.RelationConfig != "" {
+		items = append(items, "rel="+field.RelationConfig)
+	}
+	if rel := field.Relation; rel != nil {
+		if rel.OnDelete != "" {
+			items = append(items, "on_delete="+rel.OnDelete)
+		}
+		if rel.OnUpdate != "" {
+			items = append(items, "on_update="+rel.OnUpdate)
+		}
+		if rel.ForeignKey && rel.OnDelete == "" && rel.OnUpdate == "" {
+			items = append(items, "fk")
+		}
+	}
+	return strings.Join(items, ";")
+}
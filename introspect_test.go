@@ -0,0 +1,102 @@
+package dba
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// schemaSummary 提取模型中参与比较的字段信息
+func schemaSummary(schs []*Schema) []string {
+	var result []string
+	for _, sch := range schs {
+		for _, f := range sch.Fields {
+			result = append(result, fmt.Sprintf("%s.%s %s %s pk=%v incr=%v req=%s unique=%s rel=%s",
+				sch.Name, f.Name, f.NativeName, f.Type, f.IsPrimary, f.IsAutoIncrement, f.RequiredConfig, f.UniqueConfig, f.RelationConfig))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestIntrospectRoundTrip(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t))
+	if _, err := conn.BatchExec(`
+		CREATE TABLE author (id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(64) NOT NULL, email VARCHAR(128));
+		CREATE UNIQUE INDEX uk_author_email ON author (email);
+		CREATE TABLE post (id INTEGER PRIMARY KEY AUTOINCREMENT, author_id INTEGER NOT NULL REFERENCES author (id) ON DELETE CASCADE, title TEXT, score REAL, published_at DATETIME, draft BOOLEAN)`); err != nil {
+		t.Fatal(err)
+	}
+	schs, err := conn.IntrospectSchemas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Author.Email email string pk=false incr=false req= unique=true rel=",
+		"Author.ID id int pk=true incr=true req= unique= rel=",
+		"Author.Name name string pk=false incr=false req=true unique= rel=",
+		"Author.Posts posts array pk=false incr=false req= unique= rel=HAS_MANY,ID->AuthorID",
+		"Post.Author author object pk=false incr=false req= unique= rel=REF_ONE,AuthorID->ID",
+		"Post.AuthorID author_id int pk=false incr=false req=true unique= rel=",
+		"Post.Draft draft bool pk=false incr=false req= unique= rel=",
+		"Post.ID id int pk=true incr=true req= unique= rel=",
+		"Post.PublishedAt published_at time pk=false incr=false req= unique= rel=",
+		"Post.Score score float pk=false incr=false req= unique= rel=",
+		"Post.Title title string pk=false incr=false req= unique= rel=",
+	}
+	if got := schemaSummary(schs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if only, err := conn.IntrospectSchemas(&IntrospectOptions{Tables: []string{"author"}}); err != nil || len(only) != 1 {
+		t.Fatalf("unexpected filtered schemas: %v, %v", only, err)
+	}
+
+	// 反向生成的模型建表后再次读取，字段及外键推导的关系保持一致
+	values := make([]any, len(schs))
+	for i, sch := range schs {
+		values[i] = sch
+	}
+	copied := newTestConnection(t, testDSN(t), values...)
+	if err := copied.Init(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := copied.IntrospectSchemas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := schemaSummary(again); !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip got\n%s", strings.Join(got, "\n"))
+	}
+	if err := copied.ns.Model("Author").Create(map[string]any{"Name": "a", "Email": "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := copied.ns.Model("Author").Create(map[string]any{"Name": "b", "Email": "a@example.com"}); err == nil {
+		t.Fatal("expected unique constraint error")
+	}
+	tables, err := copied.driver.Inspect(context.Background(), copied.xdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fks := tables["post"].ForeignKeys; len(fks) != 1 || fks[0].RefTable != "author" || fks[0].OnDelete != "CASCADE" {
+		t.Fatalf("unexpected foreign keys: %+v", fks)
+	}
+
+	src, err := GenStructs(schs, &GenStructsOptions{Package: "models"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"package models",
+		"Email *string `dba:\"type=VARCHAR(128);unique\"`",
+		"Posts []*Post `dba:\"rel=HAS_MANY,ID->AuthorID\"`",
+		"PublishedAt *time.Time",
+		"Author      *Author `dba:\"rel=REF_ONE,AuthorID->ID;on_delete=cascade\"`",
+	} {
+		if !strings.Contains(string(src), s) {
+			t.Fatalf("generated source missing %q:\n%s", s, src)
+		}
+	}
+}
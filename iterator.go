@@ -0,0 +1,241 @@
+package dba
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultIterateBatchSize 流式查询时每批处理（关联查询、虚拟字段、钩子）的数据条数
+const DefaultIterateBatchSize = 100
+
+type IterateOptions struct {
+	BatchSize int // 每批处理的数据条数，默认100
+}
+
+// Iterator 流式读取查询结果，用法与sql.Rows一致：
+//
+//	it, err := Model("User").Find().Iterate()
+//	defer it.Close()
+//	for it.Next() {
+//		var user User
+//		if err := it.Scan(&user); err != nil { ... }
+//	}
+//	err = it.Err()
+type Iterator struct {
+	ctx       context.Context
+	r         *Result
+	scope     *HookScope
+	rows      *sqlx.Rows
+	batchSize int
+	batch     reflect.Value // 当前批次已处理完成的数据
+	pos       int
+	closed    bool
+	err       error
+}
+
+func (r *Result) Iterate(options ...*IterateOptions) (*Iterator, error) {
+	return r.IterateContext(context.Background(), options...)
+}
+
+// IterateContext 执行查询并返回流式读取的迭代器，关联查询、虚拟字段及查询钩子按批次执行，使用完毕后需调用Close
+func (r *Result) IterateContext(ctx context.Context, options ...*IterateOptions) (*Iterator, error) {
+	// FINAL
+	defer r.reset()
+	ctx = r.readContext(ctx)
+
+	var opts IterateOptions
+	if len(options) > 0 && options[0] != nil {
+		opts = *options[0]
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultIterateBatchSize
+	}
+	it := &Iterator{
+		ctx:       ctx,
+		r:         r.clone(),
+		scope:     &HookScope{Event: HookAfterFind, Result: r.clone()},
+		batchSize: opts.BatchSize,
+	}
+	data, attrs, err := r.beforeQuery()
+	if err != nil {
+		return nil, err
+	}
+	var buff bytes.Buffer
+	if err := r.dm.queryTemplate.Execute(&buff, data); err != nil {
+		return nil, err
+	}
+	sql := buff.String()
+	sql = r.dm.conn.Rebind(formatSQL(sql))
+
+	rows, err := r.queryer(ctx).QueryxContext(ctx, sql, attrs...)
+	if err != nil {
+		r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).Errorf("Iterate failed: %v", err)
+		return nil, err
+	}
+	r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).Infof("Iterate successful")
+	it.rows = rows
+	return it, nil
+}
+
+// Next 移动到下一条数据，没有更多数据或出错时返回false
+func (it *Iterator) Next() bool {
+	if it.closed || it.err != nil {
+		return false
+	}
+	if it.batch.IsValid() {
+		if it.pos+1 < it.batch.Len() {
+			it.pos++
+			return true
+		}
+		it.batch = reflect.Value{}
+	}
+	if it.rows.Next() {
+		return true
+	}
+	it.err = it.rows.Err()
+	_ = it.Close()
+	return false
+}
+
+// Scan 读取当前数据，dst为结构体指针、map[string]any或其指针，同一迭代器需使用相同的类型
+func (it *Iterator) Scan(dst any) error {
+	if it.err != nil {
+		return it.err
+	}
+	if it.closed {
+		return fmt.Errorf("dba: iterator is closed")
+	}
+	typ, err := iterateElemType(dst)
+	if err != nil {
+		return err
+	}
+	if !it.batch.IsValid() {
+		if err := it.loadBatch(typ); err != nil {
+			it.err = err
+			return err
+		}
+	}
+	if it.batch.Type().Elem() != typ {
+		return fmt.Errorf("dba: iterator scan type mismatch: %s", typ)
+	}
+	doc := it.batch.Index(it.pos)
+	switch v := dst.(type) {
+	case map[string]any:
+		for k := range v {
+			delete(v, k)
+		}
+		for k, val := range doc.Interface().(map[string]any) {
+			v[k] = val
+		}
+	default:
+		reflect.ValueOf(dst).Elem().Set(doc)
+	}
+	return nil
+}
+
+// loadBatch 从当前数据开始读取一批数据，并执行关联查询、虚拟字段计算及查询钩子
+func (it *Iterator) loadBatch(typ reflect.Type) error {
+	batch := reflect.New(reflect.SliceOf(typ))
+	for {
+		var elem reflect.Value
+		if typ.Kind() == reflect.Map {
+			doc := make(map[string]any)
+			if err := it.rows.MapScan(doc); err != nil {
+				return err
+			}
+			elem = reflect.ValueOf(doc)
+		} else {
+			doc := reflect.New(typ)
+			if err := it.rows.StructScan(doc.Interface()); err != nil {
+				return err
+			}
+			elem = doc.Elem()
+		}
+		batch.Elem().Set(reflect.Append(batch.Elem(), elem))
+		if batch.Elem().Len() >= it.batchSize || !it.rows.Next() {
+			break
+		}
+	}
+	if err := it.rows.Err(); err != nil {
+		return err
+	}
+	dst := batch.Interface()
+	it.r.computeVirtuals(it.ctx, dst)
+	if err := it.r.afterQuery(it.ctx, dst); err != nil {
+		return err
+	}
+	if err := it.r.afterFind(it.ctx, it.scope, dst); err != nil {
+		return err
+	}
+	it.batch = batch.Elem()
+	it.pos = 0
+	return nil
+}
+
+// Err 返回迭代过程中的错误
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Close 关闭迭代器并释放数据库连接，可重复调用
+func (it *Iterator) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+	it.batch = reflect.Value{}
+	return it.rows.Close()
+}
+
+func (r *Result) Each(dst any, fn func(doc any) error, options ...*IterateOptions) error {
+	return r.EachContext(context.Background(), dst, fn, options...)
+}
+
+// EachContext 流式读取查询结果，dst用于指定数据类型（结构体指针或map[string]any），
+// fn每次接收一个新的同类型数据，返回错误时停止读取
+func (r *Result) EachContext(ctx context.Context, dst any, fn func(doc any) error, options ...*IterateOptions) error {
+	typ, err := iterateElemType(dst)
+	if err != nil {
+		r.reset()
+		return err
+	}
+	it, err := r.IterateContext(ctx, options...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = it.Close()
+	}()
+	for it.Next() {
+		var doc any
+		if typ.Kind() == reflect.Map {
+			doc = make(map[string]any)
+		} else {
+			doc = reflect.New(typ).Interface()
+		}
+		if err := it.Scan(doc); err != nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// iterateElemType 返回流式读取的数据类型：结构体或map[string]any
+func iterateElemType(dst any) (reflect.Type, error) {
+	switch dst.(type) {
+	case map[string]any, *map[string]any:
+		return reflect.TypeOf(map[string]any{}), nil
+	}
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("dba: iterator destination must be a struct pointer or map[string]any")
+	}
+	return rv.Elem().Type(), nil
+}
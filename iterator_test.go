@@ -0,0 +1,88 @@
+package dba
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type IterItem struct {
+	ID   uint `dba:"pk;incr"`
+	Name string
+}
+
+func TestIterator(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &IterItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	const total = 25
+	items := make([]*IterItem, total)
+	for i := range items {
+		items[i] = &IterItem{Name: fmt.Sprintf("n%02d", i+1)}
+	}
+	model := func() *DataModel { return conn.ns.Model("IterItem") }
+	if err := model().Create(items); err != nil {
+		t.Fatal(err)
+	}
+	var found int
+	conn.ns.RegisterHook("IterItem", HookAfterFind, func(ctx context.Context, scope *HookScope) error {
+		found++
+		return nil
+	})
+
+	it, err := model().Find().OrderBy("ID").Iterate(&IterateOptions{BatchSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for it.Next() {
+		var item IterItem
+		if err := it.Scan(&item); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, item.Name)
+		if len(names) == 1 {
+			if err := it.Scan(map[string]any{}); err == nil {
+				t.Fatal("expected scan type mismatch error")
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != total || names[0] != "n01" || names[total-1] != "n25" {
+		t.Fatalf("unexpected names: %v", names)
+	}
+	// 钩子按批次对每条数据执行
+	if found != total {
+		t.Fatalf("expected %d after find hooks, got %d", total, found)
+	}
+	if err := it.Scan(&IterItem{}); err == nil {
+		t.Fatal("expected error for closed iterator")
+	}
+
+	// 提前停止时释放连接
+	errStop := errors.New("stop")
+	var rows []map[string]any
+	err = model().Find("ID >", 5).Each(map[string]any{}, func(doc any) error {
+		rows = append(rows, doc.(map[string]any))
+		if len(rows) == 3 {
+			return errStop
+		}
+		return nil
+	}, &IterateOptions{BatchSize: 2})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("expected stop error, got %v", err)
+	}
+	if len(rows) != 3 || rows[0]["name"] != "n06" || rows[2]["name"] != "n08" {
+		t.Fatalf("unexpected rows: %v", rows)
+	}
+	if n := conn.xdb.Stats().InUse; n != 0 {
+		t.Fatalf("expected connections released, %d in use", n)
+	}
+	if err := model().Find().Each([]IterItem{}, func(doc any) error { return nil }); err == nil {
+		t.Fatal("expected error for invalid destination")
+	}
+}
@@ -0,0 +1,370 @@
+package dba
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	DefaultMigrationsDir  = "migrations"
+	DefaultMigrationTable = "dba_schema_migrations"
+)
+
+// migrationFilePattern 迁移文件名：<版本号>[_名称][.up|.down].sql，无up/down后缀时视为up
+var migrationFilePattern = regexp.MustCompile(`^(\d+)(?:_([^.]+))?(?:\.(up|down))?\.sql$`)
+
+// Migration 单个版本的迁移脚本
+type Migration struct {
+	Version  string `json:"version"`
+	Name     string `json:"name,omitempty"`
+	Up       string `json:"-"`
+	Down     string `json:"-"`
+	Checksum string `json:"checksum"` // up脚本的SHA-256
+}
+
+// MigrationStatus 迁移状态
+type MigrationStatus struct {
+	Version          string     `json:"version"`
+	Name             string     `json:"name,omitempty"`
+	Checksum         string     `json:"checksum,omitempty"`
+	Applied          bool       `json:"applied"`
+	AppliedAt        *time.Time `json:"applied_at,omitempty"`
+	ChecksumMismatch bool       `json:"checksum_mismatch,omitempty"` // 已执行的脚本被修改
+	Missing          bool       `json:"missing,omitempty"`           // 已执行但迁移文件不存在
+}
+
+// schemaMigration 迁移记录表
+type schemaMigration struct {
+	Version   string    `dba:"pk;type=VARCHAR(255)"`
+	Name      string    `dba:"type=VARCHAR(255)"`
+	Checksum  string    `dba:"req;type=VARCHAR(64)"`
+	AppliedAt time.Time `dba:"req"`
+}
+
+type MigrateOptions struct {
+	Dir         string        // 迁移文件目录，默认migrations
+	FS          fs.FS         // 迁移文件所在文件系统，设置后忽略Dir
+	Table       string        // 迁移记录表，默认dba_schema_migrations
+	LockTimeout time.Duration // 等待其他迁移进程释放锁的时间，默认15秒
+}
+
+type Migrator struct {
+	conn *Connection
+	opts MigrateOptions
+}
+
+// Migrator 创建迁移执行器
+func (c *Connection) Migrator(options ...*MigrateOptions) *Migrator {
+	var opts MigrateOptions
+	if len(options) > 0 && options[0] != nil {
+		opts = *options[0]
+	}
+	if opts.Dir == "" {
+		opts.Dir = DefaultMigrationsDir
+	}
+	if opts.FS == nil {
+		opts.FS = os.DirFS(opts.Dir)
+	}
+	if opts.Table == "" {
+		opts.Table = DefaultMigrationTable
+	}
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = 15 * time.Second
+	}
+	return &Migrator{conn: c, opts: opts}
+}
+
+// Load 读取迁移文件，按版本号升序返回，数值相同的版本号（如1和001）视为重复
+func (m *Migrator) Load() ([]*Migration, error) {
+	entries, err := fs.ReadDir(m.opts.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make(map[string]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		data, err := fs.ReadFile(m.opts.FS, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		version, name, direction := match[1], match[2], match[3]
+		key := strings.TrimLeft(version, "0")
+		mig := migrations[key]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: name}
+			migrations[key] = mig
+		} else if version != mig.Version {
+			return nil, fmt.Errorf("dba: duplicate migration version: %s, %s", mig.Version, version)
+		} else if name != "" && mig.Name != "" && name != mig.Name {
+			return nil, fmt.Errorf("dba: duplicate migration version: %s", version)
+		} else if mig.Name == "" {
+			mig.Name = name
+		}
+		if direction == "down" {
+			mig.Down = string(data)
+		} else {
+			if mig.Up != "" {
+				return nil, fmt.Errorf("dba: duplicate migration version: %s", version)
+			}
+			mig.Up = string(data)
+			sum := sha256.Sum256(data)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		}
+	}
+	var result []*Migration
+	for _, mig := range migrations {
+		result = append(result, mig)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return compareVersion(result[i].Version, result[j].Version) < 0
+	})
+	return result, nil
+}
+
+func (m *Migrator) Up() ([]*Migration, error) {
+	return m.UpContext(context.Background())
+}
+
+// UpContext 按版本顺序执行未执行的迁移，每个版本使用单独的事务，返回本次执行的迁移
+func (m *Migrator) UpContext(ctx context.Context) (applied []*Migration, err error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	err = m.withLock(ctx, func() error {
+		records, err := m.records(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mig := range migrations {
+			if record, ok := records[mig.Version]; ok {
+				if mig.Up != "" && record.Checksum != mig.Checksum {
+					return fmt.Errorf("dba: checksum mismatch for applied migration: %s", mig.Version)
+				}
+				continue
+			}
+			if mig.Up == "" {
+				return fmt.Errorf("dba: migration has no up file: %s", mig.Version)
+			}
+			err := m.exec(ctx, mig.Up, func(tx *sqlx.Tx) error {
+				_, err := tx.ExecContext(ctx, m.conn.Rebind(fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s) VALUES (?, ?, ?, ?)",
+					m.quote(m.opts.Table), m.quote("version"), m.quote("name"), m.quote("checksum"), m.quote("applied_at"))),
+					mig.Version, mig.Name, mig.Checksum, time.Now())
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("dba: apply migration %s failed: %w", mig.Version, err)
+			}
+			m.conn.logger.WithField("version", mig.Version).Infof("Migration applied")
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+func (m *Migrator) Down(steps int) ([]*Migration, error) {
+	return m.DownContext(context.Background(), steps)
+}
+
+// DownContext 按版本倒序回滚最近执行的steps个迁移，steps必须大于0
+func (m *Migrator) DownContext(ctx context.Context, steps int) (reverted []*Migration, err error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("dba: invalid down steps: %d", steps)
+	}
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[string]*Migration)
+	for _, mig := range migrations {
+		byVersion[mig.Version] = mig
+	}
+	err = m.withLock(ctx, func() error {
+		records, err := m.records(ctx)
+		if err != nil {
+			return err
+		}
+		var versions []string
+		for version := range records {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return compareVersion(versions[i], versions[j]) > 0
+		})
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+		for _, version := range versions {
+			mig := byVersion[version]
+			if mig == nil || mig.Down == "" {
+				return fmt.Errorf("dba: migration has no down file: %s", version)
+			}
+			err := m.exec(ctx, mig.Down, func(tx *sqlx.Tx) error {
+				_, err := tx.ExecContext(ctx, m.conn.Rebind(fmt.Sprintf("DELETE FROM %s WHERE %s = ?",
+					m.quote(m.opts.Table), m.quote("version"))), version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("dba: revert migration %s failed: %w", version, err)
+			}
+			m.conn.logger.WithField("version", version).Infof("Migration reverted")
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+func (m *Migrator) Status() ([]*MigrationStatus, error) {
+	return m.StatusContext(context.Background())
+}
+
+// StatusContext 返回迁移文件及已执行记录的状态，按版本号升序
+func (m *Migrator) StatusContext(ctx context.Context) ([]*MigrationStatus, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	records, err := m.records(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result []*MigrationStatus
+	for _, mig := range migrations {
+		status := &MigrationStatus{Version: mig.Version, Name: mig.Name, Checksum: mig.Checksum}
+		if record, ok := records[mig.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.ChecksumMismatch = mig.Up != "" && record.Checksum != mig.Checksum
+			delete(records, mig.Version)
+		}
+		result = append(result, status)
+	}
+	for _, record := range records {
+		appliedAt := record.AppliedAt
+		result = append(result, &MigrationStatus{
+			Version:   record.Version,
+			Name:      record.Name,
+			Checksum:  record.Checksum,
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return compareVersion(result[i].Version, result[j].Version) < 0
+	})
+	return result, nil
+}
+
+// withLock 持有迁移锁执行fn，防止多个进程同时迁移
+func (m *Migrator) withLock(ctx context.Context, fn func() error) (err error) {
+	conn, err := m.conn.xdb.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if err := m.conn.driver.AcquireLock(ctx, conn, m.opts.Table, m.opts.LockTimeout); err != nil {
+		return err
+	}
+	defer func() {
+		if e := m.conn.driver.ReleaseLock(context.Background(), conn, m.opts.Table); e != nil && err == nil {
+			err = e
+		}
+	}()
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// exec 在事务中逐条执行迁移脚本及记录变更，脚本按原样执行，不做占位符转换
+func (m *Migrator) exec(ctx context.Context, script string, record func(tx *sqlx.Tx) error) error {
+	tx, err := m.conn.xdb.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range splitStatements(m.conn.driver, script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			m.conn.logger.WithField("sql", stmt).Errorf("Migration statement failed: %v", err)
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err := record(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	sch, err := parseSchema(&schemaMigration{})
+	if err != nil {
+		return err
+	}
+	sch = sch.Clone()
+	sch.Name = m.opts.Table
+	sch.NativeName = m.opts.Table
+	ddl := m.conn.driver.GenDDL([]string{sch.Name}, map[string]*Schema{sch.Name: sch}, true)
+	for _, stmt := range splitStatements(m.conn.driver, ddl) {
+		if _, err := m.conn.xdb.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) records(ctx context.Context) (map[string]*schemaMigration, error) {
+	var rows []*schemaMigration
+	query := fmt.Sprintf("SELECT %s, %s, %s, %s FROM %s",
+		m.quote("version"), m.quote("name"), m.quote("checksum"), m.quote("applied_at"), m.quote(m.opts.Table))
+	if err := sqlx.SelectContext(ctx, m.conn.xdb, &rows, query); err != nil {
+		return nil, err
+	}
+	records := make(map[string]*schemaMigration)
+	for _, row := range rows {
+		records[row.Version] = row
+	}
+	return records, nil
+}
+
+func (m *Migrator) quote(name string) string {
+	return m.conn.driver.QuoteIdentifier(name)
+}
+
+// compareVersion 按数值比较版本号
+func compareVersion(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,184 @@
+package dba
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var testMigrations = fstest.MapFS{
+	"001_init.up.sql": {Data: []byte(`-- 初始化; 注释中的分号不拆分
+CREATE TABLE note (
+	id INTEGER PRIMARY KEY,
+	body TEXT NOT NULL
+);
+CREATE TABLE note_log (id INTEGER PRIMARY KEY, note_id INTEGER, body TEXT);
+/* 触发器包含多条语句 */
+CREATE TRIGGER note_insert AFTER INSERT ON note
+BEGIN
+	INSERT INTO note_log (note_id, body) VALUES (NEW.id, 'created; ' || CASE WHEN NEW.id > 0 THEN 'ok' ELSE 'zero' END);
+	UPDATE note SET body = body WHERE id = NEW.id;
+END;
+INSERT INTO note (id, body) VALUES (1, 'what? $1 -- not a comment; still a string');
+`)},
+	"001_init.down.sql": {Data: []byte("DROP TRIGGER note_insert;\nDROP TABLE note_log;\nDROP TABLE note;\n")},
+	"002_more.sql":      {Data: []byte("INSERT INTO note (id, body) VALUES (2, 'second');")},
+	"002_more.down.sql": {Data: []byte("DELETE FROM note WHERE id = 2; -- trailing comment")},
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t))
+	m := conn.Migrator(&MigrateOptions{FS: testMigrations})
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 || applied[0].Version != "001" || applied[1].Version != "002" {
+		t.Fatalf("unexpected applied migrations: %+v", applied)
+	}
+	var body string
+	if err := conn.xdb.Get(&body, "SELECT body FROM note WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if body != "what? $1 -- not a comment; still a string" {
+		t.Fatalf("script was rewritten: %q", body)
+	}
+	var logs []string
+	if err := conn.xdb.Select(&logs, "SELECT body FROM note_log ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 || logs[0] != "created; ok" {
+		t.Fatalf("unexpected trigger logs: %v", logs)
+	}
+
+	if applied, err := m.Up(); err != nil {
+		t.Fatal(err)
+	} else if len(applied) != 0 {
+		t.Fatalf("expected no pending migrations, got %+v", applied)
+	}
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 2 || !status[0].Applied || !status[1].Applied {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	for _, steps := range []int{0, -1} {
+		if _, err := m.Down(steps); err == nil {
+			t.Fatalf("expected error for %d steps", steps)
+		}
+	}
+	reverted, err := m.Down(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != "002" {
+		t.Fatalf("unexpected reverted migrations: %+v", reverted)
+	}
+	var notes int
+	if err := conn.xdb.Get(&notes, "SELECT COUNT(*) FROM note"); err != nil {
+		t.Fatal(err)
+	}
+	if notes != 1 {
+		t.Fatalf("expected 1 note after down, got %d", notes)
+	}
+	if status, err := m.Status(); err != nil {
+		t.Fatal(err)
+	} else if !status[0].Applied || status[1].Applied {
+		t.Fatalf("unexpected status after down: %+v", status)
+	}
+
+	if _, err := m.Down(1); err != nil {
+		t.Fatal(err)
+	}
+	var ledger int
+	if err := conn.xdb.Get(&ledger, "SELECT COUNT(*) FROM "+DefaultMigrationTable); err != nil {
+		t.Fatal(err)
+	}
+	if ledger != 0 {
+		t.Fatalf("expected empty ledger, got %d records", ledger)
+	}
+	if err := conn.xdb.Get(&notes, "SELECT COUNT(*) FROM note"); err == nil {
+		t.Fatal("expected note table to be dropped")
+	}
+}
+
+func TestMigratorFailedScript(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t))
+	m := conn.Migrator(&MigrateOptions{FS: fstest.MapFS{
+		"1_bad.sql": {Data: []byte("CREATE TABLE bad (id INTEGER);\nINSERT INTO missing VALUES (1);")},
+	}})
+	if _, err := m.Up(); err == nil {
+		t.Fatal("expected migration error")
+	}
+	if status, err := m.Status(); err != nil {
+		t.Fatal(err)
+	} else if len(status) != 1 || status[0].Applied {
+		t.Fatalf("failed migration recorded: %+v", status)
+	}
+}
+
+func TestMigratorDuplicateVersion(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t))
+	m := conn.Migrator(&MigrateOptions{FS: fstest.MapFS{
+		"1_a.sql":   {Data: []byte("SELECT 1;")},
+		"001_b.sql": {Data: []byte("SELECT 1;")},
+	}})
+	if _, err := m.Load(); err == nil || !strings.Contains(err.Error(), "duplicate migration version") {
+		t.Fatalf("expected duplicate version error, got %v", err)
+	}
+}
+
+func TestSQLiteLock(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t))
+	ctx := context.Background()
+	c1, err := conn.xdb.Connx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	c2, err := conn.xdb.Connx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+
+	driver := conn.driver
+	if err := driver.AcquireLock(ctx, c1, "m", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.AcquireLock(ctx, c2, "m", 200*time.Millisecond); err == nil {
+		t.Fatal("expected lock timeout")
+	}
+	// 未持有锁的连接不能释放其他进程的锁
+	if err := driver.ReleaseLock(ctx, c2, "m"); err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.AcquireLock(ctx, c2, "m", 200*time.Millisecond); err == nil {
+		t.Fatal("lock released by non-owner")
+	}
+	if err := driver.ReleaseLock(ctx, c1, "m"); err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.AcquireLock(ctx, c2, "m", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.ReleaseLock(ctx, c2, "m"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 过期的锁可被其他进程获取
+	if _, err := c1.ExecContext(ctx, "INSERT INTO dba_locks (name, owner, locked_at, expires_at) VALUES ('m', 'dead', 0, 0)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.AcquireLock(ctx, c2, "m", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.ReleaseLock(ctx, c2, "m"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,14 +2,20 @@ package dba
 
 import (
 	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"text/template"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type DataModel struct {
@@ -17,6 +23,8 @@ type DataModel struct {
 	schema         *Schema
 	xdb            *sqlx.DB
 	xtx            *sqlx.Tx
+	ownTx          bool // xtx由模型自行开启（而非ModelOptions.Tx传入）
+	strict         bool
 	createTemplate *template.Template
 	deleteTemplate *template.Template
 	updateTemplate *template.Template
@@ -68,6 +76,10 @@ type RelatesWriteOptions struct {
 }
 
 func (dm *DataModel) Create(value any, options ...*CreateOptions) error {
+	return dm.CreateContext(context.Background(), value, options...)
+}
+
+func (dm *DataModel) CreateContext(ctx context.Context, value any, options ...*CreateOptions) error {
 	var opts CreateOptions
 	if len(options) > 0 && options[0] != nil {
 		opts = *options[0]
@@ -78,95 +90,76 @@ func (dm *DataModel) Create(value any, options ...*CreateOptions) error {
 
 	ru := NewReflectValue(value)
 
-	columns := dm.schema.ScalarFieldNativeNames()
-	nativeFields := dm.schema.NativeFields()
-	var allVars [][]any
-
+	var docs []reflect.Value
 	if ru.IsArray() {
 		// 切片或数组插入
-		size := ru.Len()
-		allVars = make([][]any, size)
-		for i := 0; i < size; i++ {
-			elem := ru.Index(i)
-			rowVars := extractRowVars(elem, columns, nativeFields)
-			allVars[i] = rowVars
+		for i := 0; i < ru.Len(); i++ {
+			docs = append(docs, addressableDoc(ru.Index(i)))
 		}
 	} else {
 		// 单个值插入
-		rowVars := extractRowVars(value, columns, nativeFields)
-		allVars = append(allVars, rowVars)
+		docs = append(docs, addressableDoc(reflect.ValueOf(value)))
+	}
+	if err := dm.runDocHooks(ctx, HookBeforeCreate, docs); err != nil {
+		return dm.abort(err)
+	}
+	if err := dm.validateCreate(ctx, docs); err != nil {
+		return err
 	}
-
-	aif := dm.schema.AutoIncrField()
 
 	var (
-		tx  *sqlx.Tx
+		txm *DataModel
 		err error
 	)
 	if opts.SharedTx {
-		// 开启一个事务供所有批次使用
-		tx, err = dm.xdb.Beginx()
-		if err != nil {
+		// 开启一个事务供所有批次使用，已绑定事务时使用绑定的事务
+		if txm, err = dm.begin(ctx); err != nil {
 			return err
 		}
 	}
 
 	// 分批插入
-	for i := 0; i < len(allVars); i += opts.BatchSize {
+	for i := 0; i < len(docs); i += opts.BatchSize {
 		end := i + opts.BatchSize
-		if end > len(allVars) {
-			end = len(allVars)
+		if end > len(docs) {
+			end = len(docs)
 		}
 
-		varsBatch := allVars[i:end]
-
 		// 如果不共用事务，每个批次单独开启事务
 		if !opts.SharedTx {
-			tx, err = dm.xdb.Beginx()
-			if err != nil {
+			if txm, err = dm.begin(ctx); err != nil {
 				return err
 			}
 		}
 
-		lastInsertId, err := dm.insertBatchWithTx(tx, columns, varsBatch, &opts)
-		if err != nil {
-			if tx != nil {
-				_ = tx.Rollback()
-			}
-			return err
+		// 插入并回填数据库生成的主键（及默认值）
+		if err := dm.insertBatchWithTx(ctx, txm.xtx, docs[i:end], &opts); err != nil {
+			return txm.abort(err)
 		}
 
 		if !opts.SharedTx {
-			// 提交每个批次的事务
-			if err := dm.afterCreate(value, &opts); err != nil {
-				return err
+			// 写入关联数据后执行AfterCreate钩子，再提交每个批次的事务
+			if err := txm.afterCreate(ctx, batchValue(ru, value, i, end), &opts); err != nil {
+				return txm.abort(err)
 			}
-			if err := tx.Commit(); err != nil {
-				return err
+			if err := dm.runDocHooks(ctx, HookAfterCreate, docs[i:end]); err != nil {
+				return txm.abort(err)
 			}
-		}
-
-		insertID := lastInsertId
-		if lastInsertId > 0 && aif != nil {
-			// 设置自增字段
-			for j := end - 1; j >= i; j-- {
-				if ru.IsArray() {
-					elem := ru.Index(j)
-					_ = SetFieldOrKey(elem, aif.Name, insertID)
-				} else {
-					_ = SetFieldOrKey(ru.Raw(), aif.Name, insertID)
-				}
-				insertID--
+			if err := txm.commit(); err != nil {
+				return err
 			}
 		}
 	}
 
 	if opts.SharedTx {
 		// 所有批次共用一个事务，提交事务
-		if err := dm.afterCreate(value, &opts); err != nil {
-			return err
+		if err := txm.afterCreate(ctx, value, &opts); err != nil {
+			return txm.abort(err)
+		}
+		if err := dm.runDocHooks(ctx, HookAfterCreate, docs); err != nil {
+			return txm.abort(err)
 		}
-		if err := tx.Commit(); err != nil {
+		if err := txm.commit(); err != nil {
 			return err
 		}
 	}
@@ -174,29 +167,159 @@ func (dm *DataModel) Create(value any, options ...*CreateOptions) error {
 	return nil
 }
 
+// addressableDoc 按值传入的结构体替换为可寻址的副本，以便钩子、默认值及回填写入
+func addressableDoc(doc reflect.Value) reflect.Value {
+	v := doc
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct && !v.CanAddr() {
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		return copied
+	}
+	return doc
+}
+
+// batchValue 返回[i,end)范围内的批次数据，单个值时返回其本身
+func batchValue(ru *ReflectValue, value any, i, end int) any {
+	if ru.IsArray() && (ru.Kind() == reflect.Slice || ru.CanAddr()) {
+		return ru.Slice(i, end).Interface()
+	}
+	return value
+}
+
+// settableOf 返回可通过SetFieldOrKey回写的文档（结构体指针或map）
+func settableOf(doc reflect.Value) any {
+	if doc.Kind() == reflect.Interface {
+		doc = doc.Elem()
+	}
+	if doc.Kind() == reflect.Struct {
+		if !doc.CanAddr() {
+			// 按值传入的结构体无法回写
+			return nil
+		}
+		return doc.Addr().Interface()
+	}
+	return doc.Interface()
+}
+
+// isNilValue 判断值是否应视为NULL（nil、空指针或Valuer返回nil）
+func isNilValue(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return true
+		}
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		if dv, err := valuer.Value(); err == nil && dv == nil {
+			return true
+		}
+	}
+	return false
+}
+
 // extractRowVars 提取单行数据
-func extractRowVars(value any, columns []string, nativeFields map[string]*Field) []any {
-	var rowVars []any
-	for _, column := range columns {
-		field := nativeFields[column]
-		v := NewReflectValue(value).FieldByName(field.Name)
-		rowVars = append(rowVars, v)
+func extractRowVars(value any, fields []*Field) []any {
+	rowVars := make([]any, len(fields))
+	rv := NewReflectValue(value)
+	for i, field := range fields {
+		rowVars[i] = indirectValue(rv.FieldByName(field.Name))
 	}
 	return rowVars
 }
 
-func (dm *DataModel) ensureXtx() error {
-	if dm.xtx == nil {
-		if xtx, err := dm.xdb.Beginx(); err != nil {
-			return err
+// begin 返回在事务中执行写入的模型：已绑定事务时返回自身，否则返回自行开启事务的副本（ownTx），
+// 副本的事务由commit或abort结束，不修改共享的模型
+func (dm *DataModel) begin(ctx context.Context) (*DataModel, error) {
+	if dm.xtx != nil {
+		return dm, nil
+	}
+	xtx, err := dm.xdb.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	copied := *dm
+	copied.xtx = xtx
+	copied.ownTx = true
+	return &copied, nil
+}
+
+// commit 提交模型自行开启的事务，调用方传入的事务由调用方提交
+func (dm *DataModel) commit() error {
+	if dm.xtx == nil || !dm.ownTx {
+		return nil
+	}
+	err := dm.xtx.Commit()
+	dm.xtx = nil
+	dm.ownTx = false
+	return err
+}
+
+func (dm *DataModel) insertBatchWithTx(ctx context.Context, tx *sqlx.Tx, docs []reflect.Value, opts *CreateOptions) error {
+	driver := dm.conn.driver
+	scalarFields := append([]*Field(nil), dm.schema.ScalarFields()...)
+	sort.Slice(scalarFields, func(i, j int) bool {
+		return scalarFields[i].NativeName < scalarFields[j].NativeName
+	})
+
+	allVars := make([][]any, len(docs))
+	for i, doc := range docs {
+		allVars[i] = extractRowVars(doc.Interface(), scalarFields)
+	}
+
+	// 未赋值的审计字段自动填充，并尽量回写到传入数据
+	auditValues := dm.auditValues(ctx, AuditCreatedAt, AuditUpdatedAt, AuditCreatedBy, AuditUpdatedBy)
+	for j, field := range scalarFields {
+		v, ok := auditValues[field.Name]
+		if !ok {
+			continue
+		}
+		for i, rowVars := range allVars {
+			if !IsNilOrZero(rowVars[j]) {
+				continue
+			}
+			rowVars[j] = v
+			if target := settableOf(docs[i]); target != nil {
+				_ = SetFieldOrKey(target, field.Name, v)
+			}
+		}
+	}
+
+	// 所有行均为空值的列不写入，交由数据库生成（自增主键、默认值），自增列的零值同样视为空值
+	var (
+		fields          []*Field
+		omittedFields   []*Field
+		includedIndexes []int
+	)
+	for j, field := range scalarFields {
+		included := false
+		for _, rowVars := range allVars {
+			if isNilValue(rowVars[j]) || (field.IsAutoIncrement && IsNilOrZero(rowVars[j])) {
+				continue
+			}
+			included = true
+			break
+		}
+		if included {
+			fields = append(fields, field)
+			includedIndexes = append(includedIndexes, j)
 		} else {
-			dm.xtx = xtx
+			omittedFields = append(omittedFields, field)
+		}
+	}
+	vars := make([][]any, len(allVars))
+	for i, rowVars := range allVars {
+		for _, j := range includedIndexes {
+			vars[i] = append(vars[i], rowVars[j])
 		}
 	}
-	return nil
-}
 
-func (dm *DataModel) insertBatchWithTx(tx *sqlx.Tx, columns []string, vars [][]any, opts *CreateOptions) (int64, error) {
 	placeholders := make([]string, len(vars))
 	for i := 0; i < len(placeholders); i++ {
 		rowPlaceholders := make([]string, len(vars[i]))
@@ -206,28 +329,33 @@ func (dm *DataModel) insertBatchWithTx(tx *sqlx.Tx, columns []string, vars [][]a
 		placeholders[i] = "(" + strings.Join(rowPlaceholders, ",") + ")"
 	}
 
+	quotedColumns := make([]string, len(fields))
+	for i, field := range fields {
+		quotedColumns[i] = driver.QuoteIdentifier(field.NativeName)
+	}
 	data := map[string]any{
-		"Table":   dm.schema.NativeName,
-		"Columns": strings.Join(columns, ", "),
+		"Table":   driver.QuoteIdentifier(dm.schema.NativeName),
+		"Columns": strings.Join(quotedColumns, ", "),
 		"Rows":    strings.Join(placeholders, ", "),
 	}
 
+	var conflictUpdateValues []any
 	if opts.ConflictUpdates != nil {
 		if opts.ConflictUpdates.Kind == "" {
 			opts.ConflictUpdates.Kind = ConflictIgnore
 		}
 		data["ConflictKind"] = opts.ConflictUpdates.Kind
 
-		var (
-			conflictUpdateColumns []string
-			conflictUpdateValues  []any
-		)
+		var conflictUpdateColumns []string
 		switch opts.ConflictUpdates.Kind {
 		case ConflictIgnore:
 		case ConflictUpdate:
-			for column, customValue := range opts.ConflictUpdates.Columns {
-				refVal := reflect.Indirect(reflect.ValueOf(customValue))
-				if refVal.IsNil() {
+			for key, customValue := range opts.ConflictUpdates.Columns {
+				column, _, err := resolveColumn(driver, dm.schema, key, dm.strict)
+				if err != nil {
+					return err
+				}
+				if rv := reflect.ValueOf(customValue); !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
 					conflictUpdateColumns = append(conflictUpdateColumns, fmt.Sprintf("%s = VALUES(%s)", column, column))
 				} else {
 					conflictUpdateColumns = append(conflictUpdateColumns, fmt.Sprintf("%s = ?", column))
@@ -238,42 +366,121 @@ func (dm *DataModel) insertBatchWithTx(tx *sqlx.Tx, columns []string, vars [][]a
 		if len(conflictUpdateColumns) > 0 {
 			data["ConflictUpdates"] = strings.Join(conflictUpdateColumns, ", ")
 		}
-		if len(conflictUpdateValues) > 0 {
-			for i, items := range vars {
-				items = append(items, conflictUpdateValues...)
-				vars[i] = items
+	}
+
+	// 支持RETURNING的驱动直接返回主键及数据库填充的列
+	var returningFields []*Field
+	if driver.SupportsReturning() {
+		returningFields = append(returningFields, dm.schema.PrimaryFields()...)
+		for _, field := range omittedFields {
+			if !field.IsPrimary {
+				returningFields = append(returningFields, field)
 			}
 		}
+		var returning []string
+		for _, field := range returningFields {
+			returning = append(returning, driver.QuoteIdentifier(field.NativeName))
+		}
+		if len(returning) > 0 {
+			data["Returning"] = strings.Join(returning, ", ")
+		}
 	}
 
 	var buff bytes.Buffer
 	if err := dm.createTemplate.Execute(&buff, data); err != nil {
-		return 0, err
+		return err
 	}
 	sql := buff.String()
-	sql = formatSQL(sql)
+	sql = dm.conn.Rebind(formatSQL(sql))
 
 	var args []any
 	for _, row := range vars {
 		args = append(args, row...)
 	}
+	// ON DUPLICATE KEY UPDATE/ON CONFLICT 的参数位于所有行之后
+	args = append(args, conflictUpdateValues...)
+
+	if data["Returning"] != nil {
+		returned, err := queryReturning(ctx, tx, sql, args)
+		if err != nil {
+			dm.conn.logger.WithField("sql", sql).WithField("args", args).Errorf("Insert failed: %v", err)
+			return err
+		}
+		dm.conn.logger.WithField("sql", sql).WithField("args", args).WithField("returned", len(returned)).Infof("Insert successful")
+		// 冲突忽略时返回行数可能少于插入行数，此时无法按顺序对应，不回填
+		if len(returned) == len(docs) {
+			for i, doc := range docs {
+				target := settableOf(doc)
+				for _, field := range returningFields {
+					if v, ok := returned[i][field.NativeName]; ok && v != nil {
+						if b, isBytes := v.([]byte); isBytes {
+							v = string(b)
+						}
+						_ = SetFieldOrKey(target, field.Name, v)
+					}
+				}
+			}
+		}
+		return nil
+	}
 
-	r, err := tx.Exec(sql, args...)
+	r, err := tx.ExecContext(ctx, sql, args...)
 	if err != nil {
-		return 0, err
+		dm.conn.logger.WithField("sql", sql).WithField("args", args).Errorf("Insert failed: %v", err)
+		return err
 	}
 
 	lastInsertId, err := r.LastInsertId()
 	if err != nil {
 		dm.conn.logger.WithField("sql", sql).WithField("args", args).Errorf("Insert failed: %v", err)
-	} else {
-		dm.conn.logger.WithField("sql", sql).WithField("args", args).WithField("lastInsertId", lastInsertId).Infof("Insert successful")
+		return err
+	}
+	dm.conn.logger.WithField("sql", sql).WithField("args", args).WithField("lastInsertId", lastInsertId).Infof("Insert successful")
+
+	// MySQL批量插入时LastInsertId为第一行的自增值，其余行按顺序递增
+	aif := dm.schema.AutoIncrField()
+	if aif == nil || lastInsertId <= 0 || opts.ConflictUpdates != nil {
+		return nil
+	}
+	autoGenerated := false
+	for _, field := range omittedFields {
+		if field.Name == aif.Name {
+			autoGenerated = true
+		}
+	}
+	if !autoGenerated {
+		return nil
+	}
+	if n, err := r.RowsAffected(); err != nil || int(n) != len(docs) {
+		return nil
+	}
+	for i, doc := range docs {
+		_ = SetFieldOrKey(settableOf(doc), aif.Name, lastInsertId+int64(i))
+	}
+	return nil
+}
+
+// queryReturning 执行带RETURNING的语句，按顺序返回每一行
+func queryReturning(ctx context.Context, tx *sqlx.Tx, sql string, args []any) ([]map[string]any, error) {
+	rows, err := tx.QueryxContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []map[string]any
+	for rows.Next() {
+		row := make(map[string]any)
+		if err := rows.MapScan(row); err != nil {
+			return nil, err
+		}
+		results = append(results, row)
 	}
-	return lastInsertId, err
+	return results, rows.Err()
 }
 
-func (dm *DataModel) afterCreate(docs any, opts *CreateOptions) error {
-	return relatesWrite(docs, dm, opts.RelatesWrites)
+func (dm *DataModel) afterCreate(ctx context.Context, docs any, opts *CreateOptions) error {
+	return relatesWrite(ctx, docs, dm, opts.RelatesWrites)
 }
 
 func (dm *DataModel) Find(conditions ...any) *Result {
@@ -292,15 +499,43 @@ func (dm *DataModel) Find(conditions ...any) *Result {
 }
 
 type Result struct {
-	cache     *sync.Map
-	dm        *DataModel
-	filters   []*Filter
-	orderBys  map[string]bool
-	fields    []string
-	isOmit    bool
-	limit     int
-	offset    int
-	populates []*PopulateOptions
+	cache      *sync.Map
+	dm         *DataModel
+	filters    []*Filter
+	orderBys   map[string]bool
+	orderKeys  []string // 排序字段的声明顺序
+	fields     []string
+	isOmit     bool
+	limit      int
+	offset     int
+	populates  []*PopulateOptions
+	deleted    deletedScope
+	keyset     *keysetClause
+	groupBys   []string
+	havings    []*Filter
+	aggregates []*Aggregation
+	primary    bool // 读操作使用主库
+}
+
+// deletedScope 软删除数据的查询范围
+type deletedScope int
+
+const (
+	deletedScopeExclude deletedScope = iota // 排除已删除数据（默认）
+	deletedScopeInclude                     // 包含已删除数据
+	deletedScopeOnly                        // 仅查询已删除数据
+)
+
+// WithDeleted 查询时包含已软删除的数据
+func (r *Result) WithDeleted() *Result {
+	r.deleted = deletedScopeInclude
+	return r
+}
+
+// OnlyDeleted 仅查询已软删除的数据
+func (r *Result) OnlyDeleted() *Result {
+	r.deleted = deletedScopeOnly
+	return r
 }
 
 func (r *Result) Where(conditions ...any) *Result {
@@ -336,11 +571,14 @@ func (r *Result) OrderBy(names ...string) *Result {
 		if name == "" {
 			continue
 		}
-		if strings.HasPrefix(name, "-") {
-			r.orderBys[name[1:]] = true
-		} else {
-			r.orderBys[name] = false
+		desc := strings.HasPrefix(name, "-")
+		if desc {
+			name = name[1:]
 		}
+		if _, ok := r.orderBys[name]; !ok {
+			r.orderKeys = append(r.orderKeys, name)
+		}
+		r.orderBys[name] = desc
 	}
 	return r
 }
@@ -420,10 +658,25 @@ func (r *Result) Populate(names ...string) *Result {
 	return r
 }
 
-func parseWhere(sch *Schema, filters []*Filter) (string, []any) {
+// parseWhere 解析查询条件，支持按关系路径（如Org.Name）过滤
+func parseWhere(driver Driver, ns *Namespace, sch *Schema, filters []*Filter, strict bool) (string, []any, error) {
+	return parseFilters(driver, sch, filters, func(key string) (string, *Field, error) {
+		return resolveColumn(driver, sch, key, strict)
+	}, func(entry *Entry) (string, []any, bool, error) {
+		return relationFilter(driver, ns, sch, driver.QuoteIdentifier(sch.NativeName), entry, 1)
+	})
+}
+
+// columnResolver 将过滤、排序中的名称解析为已转义的列名或表达式
+type columnResolver func(key string) (string, *Field, error)
+
+// entryResolver 将过滤条件整体解析为SQL，不能解析时返回false
+type entryResolver func(entry *Entry) (string, []any, bool, error)
+
+func parseFilters(driver Driver, sch *Schema, filters []*Filter, resolve columnResolver, related entryResolver) (string, []any, error) {
 	if len(filters) > 0 {
-		var setItem func(filterOperator, []*Filter) (string, []any)
-		setItem = func(sfo filterOperator, sfs []*Filter) (string, []any) {
+		var setItem func(filterOperator, []*Filter) (string, []any, error)
+		setItem = func(sfo filterOperator, sfs []*Filter) (string, []any, error) {
 			var (
 				clauses []string
 				attrs   []any
@@ -439,76 +692,41 @@ func parseWhere(sch *Schema, filters []*Filter) (string, []any) {
 				switch item.entryType {
 				case entryTypeFilterList:
 					subFilterList := item.entryList.([]*Filter)
-					if s, a := setItem(item.operator, subFilterList); s != "" {
+					s, a, err := setItem(item.operator, subFilterList)
+					if err != nil {
+						return "", nil, err
+					}
+					if s != "" {
 						subSQLs = append(subSQLs, s)
 						subAttrs = append(subAttrs, a...)
 					}
 				case entryTypeEntryList:
 					entryList := item.entryList.([]*Entry)
 					for _, entry := range entryList {
-						key := entry.Key
-						field := sch.Fields[key]
-						if field.Valid() && field.NativeName != "" {
-							key = field.NativeName
-						}
-						switch entry.Op {
-						case entryOpEqual:
-							subSQLs = append(subSQLs, fmt.Sprintf("(%s = ?)", key))
-							subAttrs = append(subAttrs, entry.Value)
-						case entryOpNotEqual:
-							subSQLs = append(subSQLs, fmt.Sprintf("(%s <> ?)", key))
-							subAttrs = append(subAttrs, entry.Value)
-						case entryOpLike:
-							subSQLs = append(subSQLs, fmt.Sprintf("(%s LIKE ?)", key))
-							subAttrs = append(subAttrs, "%"+fmt.Sprintf("%v", entry.Value)+"%")
-						case entryOpPrefix:
-							subSQLs = append(subSQLs, fmt.Sprintf("(%s LIKE ?)", key))
-							subAttrs = append(subAttrs, fmt.Sprintf("%v", entry.Value)+"%")
-						case entryOpSuffix:
-							subSQLs = append(subSQLs, fmt.Sprintf("(%s LIKE ?)", key))
-							subAttrs = append(subAttrs, "%"+fmt.Sprintf("%v", entry.Value))
-						case entryOpGreaterThan:
-							subSQLs = append(subSQLs, fmt.Sprintf("(%s > ?)", key))
-							subAttrs = append(subAttrs, entry.Value)
-						case entryOpGreaterThanOrEqual:
-							subSQLs = append(subSQLs, fmt.Sprintf("(%s >= ?)", key))
-							subAttrs = append(subAttrs, entry.Value)
-						case entryOpLessThan:
-							subSQLs = append(subSQLs, fmt.Sprintf("(%s < ?)", key))
-							subAttrs = append(subAttrs, entry.Value)
-						case entryOpLessThanOrEqual:
-							subSQLs = append(subSQLs, fmt.Sprintf("(%s <= ?)", key))
-							subAttrs = append(subAttrs, entry.Value)
-						case entryOpIn:
-							subSQLs = append(subSQLs, fmt.Sprintf("(%s IN ?)", key))
-							subAttrs = append(subAttrs, entry.Value)
-						case entryOpNotIn:
-							subSQLs = append(subSQLs, fmt.Sprintf("(%s NOT IN ?)", key))
-							subAttrs = append(subAttrs, entry.Value)
-						case entryOpExists:
-							var isExists bool
-							if v, ok := entry.Value.(bool); ok {
-								isExists = v
-							} else {
-								isExists = true
+						if related != nil {
+							s, a, ok, err := related(entry)
+							if err != nil {
+								return "", nil, err
 							}
-							if isExists {
-								if field != nil && field.Type == String {
-									subSQLs = append(subSQLs, fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", key, key))
-								} else {
-									subSQLs = append(subSQLs, fmt.Sprintf("(%s IS NOT NULL)", key))
-								}
-							} else {
-								if field != nil && field.Type == String {
-									subSQLs = append(subSQLs, fmt.Sprintf("(%s IS NULL OR %s <> '')", key, key))
-								} else {
-									subSQLs = append(subSQLs, fmt.Sprintf("(%s IS NULL)", key))
-								}
+							if ok {
+								subSQLs = append(subSQLs, s)
+								subAttrs = append(subAttrs, a...)
+								continue
 							}
-						default:
-							subSQLs = append(subSQLs, fmt.Sprintf("(%s = ?)", key))
-							subAttrs = append(subAttrs, entry.Value)
 						}
+						key, field, err := resolve(entry.Key)
+						if err != nil {
+							return "", nil, err
+						}
+						if field != nil && field.IsVirtual {
+							return "", nil, fmt.Errorf("dba: virtual field cannot be filtered: %s.%s", sch.Name, entry.Key)
+						}
+						s, a, err := entryCondition(driver, key, field, entry)
+						if err != nil {
+							return "", nil, err
+						}
+						subSQLs = append(subSQLs, s)
+						subAttrs = append(subAttrs, a...)
 					}
 				}
 				if len(subSQLs) > 0 {
@@ -535,53 +753,336 @@ func parseWhere(sch *Schema, filters []*Filter) (string, []any) {
 			}
 			if sfo == filterOperatorOr {
 				s := fmt.Sprintf(sfoFormat, strings.Join(clauses, " OR "))
-				return s, attrs
+				return s, attrs, nil
 			} else {
 				s := fmt.Sprintf(sfoFormat, strings.Join(clauses, " AND "))
-				return s, attrs
+				return s, attrs, nil
 			}
 		}
 		return setItem(filterOperatorAnd, filters)
 	}
-	return "", nil
+	return "", nil, nil
+}
+
+// entryCondition 生成单个过滤条件，key为已转义的列名或表达式，值为*Expression时内联其SQL并按顺序合并参数
+func entryCondition(driver Driver, key string, field *Field, entry *Entry) (string, []any, error) {
+	if expr, ok := entry.Value.(*Expression); ok {
+		if expr == nil {
+			return "", nil, fmt.Errorf("dba: nil expression: %s", entry.Key)
+		}
+		if expr.err != nil {
+			return "", nil, expr.err
+		}
+		args := append([]any(nil), expr.Args...)
+		switch entry.Op {
+		case entryOpEqual:
+			return fmt.Sprintf("(%s = (%s))", key, expr.SQL), args, nil
+		case entryOpNotEqual:
+			return fmt.Sprintf("(%s <> (%s))", key, expr.SQL), args, nil
+		case entryOpGreaterThan, entryOpGreaterThanOrEqual, entryOpLessThan, entryOpLessThanOrEqual:
+			return fmt.Sprintf("(%s %s (%s))", key, entry.Op, expr.SQL), args, nil
+		case entryOpIn:
+			return fmt.Sprintf("(%s IN (%s))", key, expr.SQL), args, nil
+		case entryOpNotIn:
+			return fmt.Sprintf("(%s NOT IN (%s))", key, expr.SQL), args, nil
+		default:
+			return "", nil, fmt.Errorf("dba: expression not supported for %s: %s", entry.Op, entry.Key)
+		}
+	}
+	switch entry.Op {
+	case entryOpEqual:
+		return fmt.Sprintf("(%s = ?)", key), []any{entry.Value}, nil
+	case entryOpNotEqual:
+		return fmt.Sprintf("(%s <> ?)", key), []any{entry.Value}, nil
+	case entryOpLike:
+		return fmt.Sprintf("(%s LIKE ?)", key), []any{"%" + fmt.Sprintf("%v", entry.Value) + "%"}, nil
+	case entryOpPrefix:
+		return fmt.Sprintf("(%s LIKE ?)", key), []any{fmt.Sprintf("%v", entry.Value) + "%"}, nil
+	case entryOpSuffix:
+		return fmt.Sprintf("(%s LIKE ?)", key), []any{"%" + fmt.Sprintf("%v", entry.Value)}, nil
+	case entryOpGreaterThan:
+		return fmt.Sprintf("(%s > ?)", key), []any{entry.Value}, nil
+	case entryOpGreaterThanOrEqual:
+		return fmt.Sprintf("(%s >= ?)", key), []any{entry.Value}, nil
+	case entryOpLessThan:
+		return fmt.Sprintf("(%s < ?)", key), []any{entry.Value}, nil
+	case entryOpLessThanOrEqual:
+		return fmt.Sprintf("(%s <= ?)", key), []any{entry.Value}, nil
+	case entryOpIn:
+		s, a := parseInClause(driver, key, entry.Value, false)
+		return s, a, nil
+	case entryOpNotIn:
+		s, a := parseInClause(driver, key, entry.Value, true)
+		return s, a, nil
+	case entryOpExists:
+		var isExists bool
+		if v, ok := entry.Value.(bool); ok {
+			isExists = v
+		} else {
+			isExists = true
+		}
+		if isExists {
+			if field != nil && field.Type == String {
+				return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", key, key), nil, nil
+			}
+			return fmt.Sprintf("(%s IS NOT NULL)", key), nil, nil
+		}
+		if field != nil && field.Type == String {
+			return fmt.Sprintf("(%s IS NULL OR %s <> '')", key, key), nil, nil
+		}
+		return fmt.Sprintf("(%s IS NULL)", key), nil, nil
+	default:
+		return fmt.Sprintf("(%s = ?)", key), []any{entry.Value}, nil
+	}
+}
+
+// resolveColumn 将模型字段名（或原生列名）解析为已转义的列名，严格模式下拒绝非模型字段
+func resolveColumn(driver Driver, sch *Schema, key string, strict bool) (string, *Field, error) {
+	field := sch.Fields[key]
+	if !field.Valid() {
+		field = sch.NativeFields()[key]
+	}
+	if field.Valid() && field.NativeName != "" {
+		return driver.QuoteIdentifier(field.NativeName), field, nil
+	}
+	if strict {
+		return "", nil, fmt.Errorf("dba: unknown field: %s.%s", sch.Name, key)
+	}
+	return driver.QuoteIdentifier(key), nil, nil
+}
+
+// inClauseMaxPlaceholders 单个IN列表使用占位符的最大个数，超出时整个列表作为一个JSON参数传入，避免超出驱动的参数上限
+const inClauseMaxPlaceholders = 1000
+
+// parseInClause 将$IN/$NIN展开为占位符列表（PostgreSQL使用= ANY(?)/<> ALL(?)）
+//
+// 列表较长时：SQLite使用json_each(?)，MySQL使用JSON_TABLE(?)，整个列表只占用一个参数。
+// 空列表时：$IN恒为假，$NIN恒为真
+func parseInClause(driver Driver, key string, value any, not bool) (string, []any) {
+	values := toAnySlice(value)
+	if len(values) == 0 {
+		if not {
+			return "(1 = 1)", nil
+		}
+		return "(1 = 0)", nil
+	}
+	var driverName string
+	if driver != nil {
+		driverName = driver.Name()
+	}
+	if driverName == PostgreSQL {
+		if not {
+			return fmt.Sprintf("(%s <> ALL(?))", key), []any{pq.Array(values)}
+		}
+		return fmt.Sprintf("(%s = ANY(?))", key), []any{pq.Array(values)}
+	}
+	op := "IN"
+	if not {
+		op = "NOT IN"
+	}
+	if len(values) > inClauseMaxPlaceholders {
+		if list, ok := jsonList(values); ok {
+			switch driverName {
+			case SQLite:
+				return fmt.Sprintf("(%s %s (SELECT value FROM json_each(?)))", key, op), []any{list}
+			case MySQL:
+				return fmt.Sprintf("(%s %s (SELECT %s FROM JSON_TABLE(?, '$[*]' COLUMNS (v %s PATH '$')) AS dba_in))",
+					key, op, mysqlJSONListColumn(values), mysqlJSONListType(values)), []any{list}
+			}
+		}
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(values)), ",")
+	return fmt.Sprintf("(%s %s (%s))", key, op, placeholders), values
+}
+
+// jsonList 将列表编码为JSON数组参数，仅支持数值、字符串及布尔值
+func jsonList(values []any) (string, bool) {
+	items := make([]any, 0, len(values))
+	for _, v := range values {
+		switch val := v.(type) {
+		case []byte:
+			items = append(items, string(val))
+		case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			items = append(items, val)
+		default:
+			return "", false
+		}
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// mysqlJSONListType JSON_TABLE的列类型：整数使用BIGINT，数值使用DOUBLE，其他使用字符串
+func mysqlJSONListType(values []any) string {
+	kind := "BIGINT"
+	for _, v := range values {
+		switch v.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, bool:
+		case uint64, float32, float64:
+			if kind == "BIGINT" {
+				kind = "DOUBLE"
+			}
+		default:
+			return "VARCHAR(1024)"
+		}
+	}
+	return kind
+}
+
+// mysqlJSONListColumn 字符串列转换为连接字符集，使其与表列按默认排序规则比较
+func mysqlJSONListColumn(values []any) string {
+	if mysqlJSONListType(values) == "VARCHAR(1024)" {
+		return "CONVERT(v USING utf8mb4)"
+	}
+	return "v"
+}
+
+// toAnySlice 将切片/数组参数转换为[]any，非切片值视为单元素列表
+func toAnySlice(value any) []any {
+	if value == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return []any{indirectValue(value)}
+	}
+	values := make([]any, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		values = append(values, indirectValue(rv.Index(i).Interface()))
+	}
+	return values
 }
 
-func parseOrderBys(sch *Schema, orderBys map[string]bool) (string, []any) {
+// indirectValue 解开reflect.Value包装，得到可直接作为SQL参数的值
+func indirectValue(v any) any {
+	switch rv := v.(type) {
+	case reflect.Value:
+		if !rv.IsValid() {
+			return nil
+		}
+		return rv.Interface()
+	case *reflect.Value:
+		if rv == nil || !rv.IsValid() {
+			return nil
+		}
+		return rv.Interface()
+	}
+	return v
+}
+
+// parseOrderBys 按声明顺序生成排序子句
+func parseOrderBys(sch *Schema, keys []string, orderBys map[string]bool, resolve columnResolver) (string, []any, error) {
 	var clauses []string
-	for key, val := range orderBys {
-		if f := sch.Fields[key]; f.Valid() && f.NativeName != "" {
-			key = f.NativeName
+	for _, key := range keys {
+		val := orderBys[key]
+		column, field, err := resolve(key)
+		if err != nil {
+			return "", nil, err
+		}
+		if field != nil && field.IsVirtual {
+			return "", nil, fmt.Errorf("dba: virtual field cannot be sorted: %s.%s", sch.Name, key)
 		}
 		if val {
-			clauses = append(clauses, fmt.Sprintf("%s DESC", key))
+			clauses = append(clauses, fmt.Sprintf("%s DESC", column))
 		} else {
-			clauses = append(clauses, fmt.Sprintf("%s", key))
+			clauses = append(clauses, fmt.Sprintf("%s", column))
 		}
 	}
 	if len(clauses) > 0 {
-		return strings.Join(clauses, ","), nil
+		return strings.Join(clauses, ","), nil, nil
 	}
-	return "", nil
+	return "", nil, nil
 }
 
-func (r *Result) beforeQuery() (map[string]any, []any) {
+func (r *Result) beforeQuery() (map[string]any, []any, error) {
+	driver := r.dm.conn.driver
 	var attrs []any
 	// 解析过滤
-	whereClause, whereAttrs := parseWhere(r.dm.schema, r.filters)
+	whereClause, whereAttrs, err := parseWhere(driver, r.dm.conn.ns, r.dm.schema, r.filters, r.dm.strict)
+	if err != nil {
+		return nil, nil, err
+	}
 	if len(whereAttrs) > 0 {
 		attrs = append(attrs, whereAttrs...)
 	}
+	// 软删除过滤
+	if f := r.dm.schema.SoftDeleteField(); f != nil && r.deleted != deletedScopeInclude {
+		deletedClause, deletedAttrs := softDeleteCondition(driver, f, r.deleted == deletedScopeOnly)
+		if whereClause != "" {
+			whereClause = fmt.Sprintf("(%s) AND %s", whereClause, deletedClause)
+		} else {
+			whereClause = deletedClause
+		}
+		attrs = append(attrs, deletedAttrs...)
+	}
+	// 游标分页条件
+	if r.keyset != nil && r.keyset.where != "" {
+		if whereClause != "" {
+			whereClause = fmt.Sprintf("(%s) AND (%s)", whereClause, r.keyset.where)
+		} else {
+			whereClause = r.keyset.where
+		}
+		attrs = append(attrs, r.keyset.attrs...)
+	}
+	// 解析分组
+	var groupBys []string
+	for _, name := range r.groupBys {
+		column, field, err := resolveColumn(driver, r.dm.schema, name, r.dm.strict)
+		if err != nil {
+			return nil, nil, err
+		}
+		if field != nil && (field.IsVirtual || !field.IsScalarType()) {
+			return nil, nil, fmt.Errorf("dba: invalid group field: %s.%s", r.dm.schema.Name, name)
+		}
+		groupBys = append(groupBys, column)
+	}
+	// 分组过滤及排序可使用聚合别名
+	resolve := func(key string) (string, *Field, error) {
+		if agg := r.aggregation(key); agg != nil {
+			expr, err := agg.expression(driver, r.dm.schema, r.dm.strict)
+			return expr, nil, err
+		}
+		return resolveColumn(driver, r.dm.schema, key, r.dm.strict)
+	}
+	havingClause, havingAttrs, err := parseFilters(driver, r.dm.schema, r.havings, resolve, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(havingAttrs) > 0 {
+		attrs = append(attrs, havingAttrs...)
+	}
 	// 解析排序
-	orderByClause, orderByAttrs := parseOrderBys(r.dm.schema, r.orderBys)
+	orderByClause, orderByAttrs, err := parseOrderBys(r.dm.schema, r.orderKeys, r.orderBys, resolve)
+	if err != nil {
+		return nil, nil, err
+	}
 	if len(orderByAttrs) > 0 {
 		attrs = append(attrs, orderByAttrs...)
 	}
 	data := map[string]any{
-		"Table": r.dm.schema.NativeName,
+		"Table": driver.QuoteIdentifier(r.dm.schema.NativeName),
 		"Where": whereClause,
 	}
+	if len(groupBys) > 0 {
+		data["GroupBys"] = strings.Join(groupBys, ", ")
+	}
+	if havingClause != "" {
+		data["Having"] = havingClause
+	}
 	if orderByClause != "" {
-		data["GroupBys"] = orderByClause
+		data["OrderBys"] = orderByClause
+	}
+	if r.keyset != nil {
+		data["OrderBys"] = r.keyset.orderBy
 	}
 
 	// 设置limit
@@ -592,6 +1093,19 @@ func (r *Result) beforeQuery() (map[string]any, []any) {
 	if r.offset > 0 {
 		data["Offset"] = r.offset
 	}
+	// 聚合查询只查询分组列及聚合结果
+	if len(r.aggregates) > 0 {
+		columns := append([]string(nil), groupBys...)
+		for _, agg := range r.aggregates {
+			expr, err := agg.expression(driver, r.dm.schema, r.dm.strict)
+			if err != nil {
+				return nil, nil, err
+			}
+			columns = append(columns, fmt.Sprintf("%s AS %s", expr, driver.QuoteIdentifier(agg.alias())))
+		}
+		data["Columns"] = strings.Join(columns, ", ")
+		return data, attrs, nil
+	}
 	// 设置select或omit字段
 	var columns []string
 	if len(r.fields) > 0 {
@@ -599,22 +1113,41 @@ func (r *Result) beforeQuery() (map[string]any, []any) {
 			scalarFields := r.dm.schema.ScalarFields()
 			var omitFieldsMap = make(map[string]bool)
 			for _, n := range r.fields {
-				omitFieldsMap[n] = true
+				// 与Select一致：支持字段名及原生列名，严格模式下拒绝非模型字段
+				_, f, err := resolveColumn(driver, r.dm.schema, n, r.dm.strict)
+				if err != nil {
+					return nil, nil, err
+				}
+				if f != nil {
+					omitFieldsMap[f.Name] = true
+				}
 			}
 			for _, f := range scalarFields {
 				if omitFieldsMap[f.Name] {
 					continue
 				}
-				columns = append(columns, f.NativeName)
+				columns = append(columns, driver.QuoteIdentifier(f.NativeName))
 			}
 		} else {
+			var hasVirtual bool
 			for _, n := range r.fields {
-				f := r.dm.schema.Fields[n]
-				if f.Valid() && f.NativeName != "" {
-					columns = append(columns, f.NativeName)
-				} else {
-					columns = append(columns, n)
+				column, f, err := resolveColumn(driver, r.dm.schema, n, r.dm.strict)
+				if err != nil {
+					return nil, nil, err
+				}
+				if f != nil && f.IsVirtual {
+					hasVirtual = true
+					continue
+				}
+				if f != nil && !f.IsScalarType() {
+					// 关联字段不是数据库列
+					continue
 				}
+				columns = append(columns, column)
+			}
+			if hasVirtual {
+				// 虚拟字段依赖的列未知，查询全部列
+				columns = []string{"*"}
 			}
 		}
 	} else {
@@ -623,10 +1156,10 @@ func (r *Result) beforeQuery() (map[string]any, []any) {
 	if len(columns) > 0 {
 		data["Columns"] = strings.Join(columns, ", ")
 	}
-	return data, attrs
+	return data, attrs, nil
 }
 
-func (r *Result) afterQuery(dst any) error {
+func (r *Result) afterQuery(ctx context.Context, dst any) error {
 	sch := r.dm.schema
 	docs := dst
 	for _, item := range r.populates {
@@ -667,7 +1200,7 @@ func (r *Result) afterQuery(dst any) error {
 			}
 			fru := NewReflectValue(docs)
 			if fru.Raw().CanAddr() {
-				if res, err := relatesQuery(fru.Value.Addr().Interface(), r.dm.conn, sch, opts); err != nil {
+				if res, err := relatesQuery(ctx, fru.Value.Addr().Interface(), r.dm.conn, sch, opts); err != nil {
 					return err
 				} else {
 					if err := SetFieldOrKey(ru.Raw(), opts.Path, res); err != nil {
@@ -675,7 +1208,7 @@ func (r *Result) afterQuery(dst any) error {
 					}
 				}
 			} else {
-				if _, err := relatesQuery(docs, r.dm.conn, sch, opts); err != nil {
+				if _, err := relatesQuery(ctx, docs, r.dm.conn, sch, opts); err != nil {
 					return err
 				}
 
@@ -686,59 +1219,108 @@ func (r *Result) afterQuery(dst any) error {
 }
 
 func (r *Result) One(dst any) error {
+	return r.OneContext(context.Background(), dst)
+}
+
+func (r *Result) OneContext(ctx context.Context, dst any) error {
 	// FINAL
 	defer r.reset()
+	ctx = r.readContext(ctx)
 
-	data, attrs := r.beforeQuery()
+	scope := &HookScope{Event: HookAfterFind, Result: r.clone()}
+	data, attrs, err := r.beforeQuery()
+	if err != nil {
+		return err
+	}
 	var buff bytes.Buffer
 	if err := r.dm.queryTemplate.Execute(&buff, data); err != nil {
 		return err
 	}
 	sql := buff.String()
-	sql = formatSQL(sql)
+	sql = r.dm.conn.Rebind(formatSQL(sql))
 
-	if err := autoScan(dst, r.dm.xdb, sql, attrs); err != nil {
+	if err := autoScan(ctx, dst, r.queryer(ctx), sql, attrs); err != nil {
 		r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).Errorf("Find one failed: %v", err)
 		return err
 	}
 	r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).Infof("Find one successful")
-	return r.afterQuery(dst)
+	r.computeVirtuals(ctx, dst)
+	if err := r.afterQuery(ctx, dst); err != nil {
+		return err
+	}
+	return r.afterFind(ctx, scope, dst)
 }
 
 func (r *Result) All(dst any) error {
+	return r.AllContext(context.Background(), dst)
+}
+
+func (r *Result) AllContext(ctx context.Context, dst any) error {
 	// FINAL
 	defer r.reset()
+	ctx = r.readContext(ctx)
 
-	data, attrs := r.beforeQuery()
+	scope := &HookScope{Event: HookAfterFind, Result: r.clone()}
+	data, attrs, err := r.beforeQuery()
+	if err != nil {
+		return err
+	}
 	var buff bytes.Buffer
 	if err := r.dm.queryTemplate.Execute(&buff, data); err != nil {
 		return err
 	}
 	sql := buff.String()
-	sql = formatSQL(sql)
+	sql = r.dm.conn.Rebind(formatSQL(sql))
 
-	if err := autoScan(dst, r.dm.xdb, sql, attrs); err != nil {
+	if err := autoScan(ctx, dst, r.queryer(ctx), sql, attrs); err != nil {
 		r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).Errorf("Find all failed: %v", err)
 		return err
 	}
 	r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).Infof("Find all successful")
-	return r.afterQuery(dst)
+	r.computeVirtuals(ctx, dst)
+	if err := r.afterQuery(ctx, dst); err != nil {
+		return err
+	}
+	return r.afterFind(ctx, scope, dst)
 }
 
 func (r *Result) Count() (int, error) {
+	return r.CountContext(context.Background())
+}
+
+func (r *Result) CountContext(ctx context.Context) (int, error) {
 	// FINAL
 	defer r.reset()
+	ctx = r.readContext(ctx)
 
-	data, attrs := r.beforeQuery()
-	var buff bytes.Buffer
-	if err := r.dm.queryTemplate.Execute(&buff, data); err != nil {
+	data, attrs, err := r.beforeQuery()
+	if err != nil {
 		return 0, err
 	}
+	delete(data, "OrderBys")
+	delete(data, "Limit")
+	delete(data, "Offset")
+	var buff bytes.Buffer
+	if groupBys, ok := data["GroupBys"]; ok {
+		// 分组查询统计分组数
+		data["Columns"] = groupBys
+		if err := r.dm.queryTemplate.Execute(&buff, data); err != nil {
+			return 0, err
+		}
+		inner := buff.String()
+		buff.Reset()
+		buff.WriteString(fmt.Sprintf("SELECT COUNT(*) FROM (%s) %s", inner, r.dm.conn.driver.QuoteIdentifier("dba_count")))
+	} else {
+		data["Columns"] = "COUNT(*)"
+		if err := r.dm.queryTemplate.Execute(&buff, data); err != nil {
+			return 0, err
+		}
+	}
 	sql := buff.String()
-	sql = formatSQL(sql)
+	sql = r.dm.conn.Rebind(formatSQL(sql))
 
 	var count int
-	if err := r.dm.xdb.QueryRowx(sql, attrs...).Scan(&count); err != nil {
+	if err := r.queryer(ctx).QueryRowxContext(ctx, sql, attrs...).Scan(&count); err != nil {
 		r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).Errorf("Count failed: %v", err)
 		return 0, err
 	}
@@ -747,19 +1329,42 @@ func (r *Result) Count() (int, error) {
 	return count, nil
 }
 
+// AsSubquery 生成查询语句作为过滤值中的子查询，不执行查询及查询钩子，如：
+//
+//	Model("User").Find("ID $IN", Model("Order").Find("Amount >", 100).Select("UserID").AsSubquery())
+func (r *Result) AsSubquery() *Expression {
+	// FINAL
+	defer r.reset()
+
+	data, attrs, err := r.beforeQuery()
+	if err != nil {
+		return &Expression{err: err}
+	}
+	var buff bytes.Buffer
+	if err := r.dm.queryTemplate.Execute(&buff, data); err != nil {
+		return &Expression{err: err}
+	}
+	return &Expression{SQL: formatSQL(buff.String()), Args: attrs}
+}
+
 func (r *Result) Paginate(pageNum int, pageSize int, dst any) (totalRecords int, totalPages int, err error) {
+	return r.PaginateContext(context.Background(), pageNum, pageSize, dst)
+}
+
+func (r *Result) PaginateContext(ctx context.Context, pageNum int, pageSize int, dst any) (totalRecords int, totalPages int, err error) {
 	// FINAL
 	defer r.reset()
 
+	// All会重置查询条件，统计总数使用查询前的副本
+	counter := r.clone()
+
 	r.offset = (pageNum - 1) * pageSize
 	r.limit = pageSize
-	if err = r.All(dst); err != nil {
+	if err = r.AllContext(ctx, dst); err != nil {
 		return
 	}
 
-	r.offset = 0
-	r.limit = 0
-	totalRecords, err = r.Count()
+	totalRecords, err = counter.CountContext(ctx)
 	if err != nil {
 		return
 	}
@@ -767,8 +1372,8 @@ func (r *Result) Paginate(pageNum int, pageSize int, dst any) (totalRecords int,
 	return
 }
 
-func (r *Result) afterUpdate(doc any, opts *UpdateOptions) error {
-	return relatesWrite(doc, r.dm, opts.RelatesWrites)
+func (r *Result) afterUpdate(ctx context.Context, dm *DataModel, doc any, opts *UpdateOptions) error {
+	return relatesWrite(ctx, doc, dm, opts.RelatesWrites)
 }
 
 type UpdateOptions struct {
@@ -776,35 +1381,64 @@ type UpdateOptions struct {
 }
 
 func (r *Result) Update(doc any, options ...*UpdateOptions) (int, error) {
+	return r.UpdateContext(context.Background(), doc, options...)
+}
+
+func (r *Result) UpdateContext(ctx context.Context, doc any, options ...*UpdateOptions) (int, error) {
 	var opts UpdateOptions
 	if len(options) > 0 && options[0] != nil {
 		opts = *options[0]
 	}
 
+	if r.dm.schema.SoftDeleteField() != nil {
+		if err := r.requireFilters("update"); err != nil {
+			return 0, err
+		}
+	}
+
 	// FINAL
 	defer r.reset()
 
-	data, attrs := r.beforeQuery()
+	scope := &HookScope{Event: HookBeforeUpdate, Result: r.clone(), Doc: doc}
+	if err := r.dm.runHooks(ctx, scope); err != nil {
+		return 0, r.dm.abort(err)
+	}
+	data, attrs, err := r.beforeQuery()
+	if err != nil {
+		return 0, err
+	}
 	pairs := NewReflectValue(doc).Map()
-	fields := r.dm.schema.Fields
+	// 未显式更新的修改时间、修改人自动填充
+	if len(pairs) > 0 {
+		for name, v := range r.dm.auditValues(ctx, AuditUpdatedAt, AuditUpdatedBy) {
+			if _, ok := pairs[name]; ok {
+				continue
+			}
+			if _, ok := pairs[r.dm.schema.Fields[name].NativeName]; ok {
+				continue
+			}
+			pairs[name] = v
+		}
+	}
+	if err := r.dm.validateUpdate(pairs); err != nil {
+		return 0, err
+	}
 	var sets []string
 	var pairsAttrs []any
 	for k, v := range pairs {
-		f := fields[k]
-		if f.Valid() && f.NativeName != "" {
-			if s, isStr := v.(string); isStr && s == SetToNullFlag {
-				sets = append(sets, fmt.Sprintf("%s = NULL", f.NativeName))
-			} else {
-				sets = append(sets, fmt.Sprintf("%s = ?", f.NativeName))
-				pairsAttrs = append(pairsAttrs, v)
-			}
+		column, f, err := resolveColumn(r.dm.conn.driver, r.dm.schema, k, r.dm.strict)
+		if err != nil {
+			return 0, err
+		}
+		if f != nil && (!f.IsScalarType() || f.IsVirtual) {
+			// 关联字段由afterUpdate处理，虚拟字段不持久化
+			continue
+		}
+		if s, isStr := v.(string); isStr && s == SetToNullFlag {
+			sets = append(sets, fmt.Sprintf("%s = NULL", column))
 		} else {
-			if s, isStr := v.(string); isStr && s == SetToNullFlag {
-				sets = append(sets, fmt.Sprintf("%s = NULL", k))
-			} else {
-				sets = append(sets, fmt.Sprintf("%s = ?", k))
-				pairsAttrs = append(pairsAttrs, v)
-			}
+			sets = append(sets, fmt.Sprintf("%s = ?", column))
+			pairsAttrs = append(pairsAttrs, v)
 		}
 	}
 	if len(pairsAttrs) > 0 {
@@ -821,14 +1455,17 @@ func (r *Result) Update(doc any, options ...*UpdateOptions) (int, error) {
 		return 0, err
 	}
 	sql := buff.String()
-	sql = formatSQL(sql)
+	sql = r.dm.conn.Rebind(formatSQL(sql))
 
-	if err := r.dm.ensureXtx(); err != nil {
+	// 更新及关联数据写入在同一事务中执行，未绑定事务时自行开启并提交
+	dm, err := r.dm.begin(ctx)
+	if err != nil {
 		return 0, err
 	}
-	res, err := r.dm.xtx.Exec(sql, attrs...)
+	res, err := dm.xtx.ExecContext(ctx, sql, attrs...)
 	if err != nil {
-		return 0, err
+		r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).Errorf("Update failed: %v", err)
+		return 0, dm.abort(err)
 	}
 	n, err := res.RowsAffected()
 	if err != nil {
@@ -836,33 +1473,115 @@ func (r *Result) Update(doc any, options ...*UpdateOptions) (int, error) {
 	} else {
 		r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).WithField("rowsAffected", n).Infof("Update successful")
 	}
-	if err := r.afterUpdate(doc, &opts); err != nil {
+	if err := r.afterUpdate(ctx, dm, doc, &opts); err != nil {
+		return 0, dm.abort(err)
+	}
+	scope.Event = HookAfterUpdate
+	scope.RowsAffected = int(n)
+	if err := r.dm.runHooks(ctx, scope); err != nil {
+		return 0, dm.abort(err)
+	}
+	if err := dm.commit(); err != nil {
 		return 0, err
 	}
 	return int(n), err
 }
 
 type DeleteOptions struct {
+	Hard bool `json:"hard"` // 存在软删除字段时仍然物理删除
 }
 
+// Delete 删除数据（必须指定过滤条件），模型存在软删除字段时更新删除时间（软删除）
 func (r *Result) Delete(options ...*DeleteOptions) (int, error) {
-	// var opts DeleteOptions
-	// if len(options) > 0 && options[0] != nil {
-	// 	opts = *options[0]
-	// }
+	return r.DeleteContext(context.Background(), options...)
+}
+
+func (r *Result) DeleteContext(ctx context.Context, options ...*DeleteOptions) (int, error) {
+	var opts DeleteOptions
+	if len(options) > 0 && options[0] != nil {
+		opts = *options[0]
+	}
+	if err := r.requireFilters("delete"); err != nil {
+		return 0, err
+	}
+	return r.deleteWithHooks(ctx, func(dm *DataModel) (int, error) {
+		if f := r.dm.schema.SoftDeleteField(); f != nil && !opts.Hard {
+			return r.setDeleted(ctx, dm, f, true)
+		}
+		return r.hardDelete(ctx, dm)
+	})
+}
+
+// HardDelete 物理删除数据（忽略软删除字段），必须指定过滤条件
+func (r *Result) HardDelete() (int, error) {
+	return r.HardDeleteContext(context.Background())
+}
+
+func (r *Result) HardDeleteContext(ctx context.Context) (int, error) {
+	if err := r.requireFilters("delete"); err != nil {
+		return 0, err
+	}
+	return r.deleteWithHooks(ctx, func(dm *DataModel) (int, error) {
+		return r.hardDelete(ctx, dm)
+	})
+}
+
+// requireFilters 删除（及软删除模型的更新）必须指定过滤条件，避免误操作全表数据
+func (r *Result) requireFilters(action string) error {
+	if len(r.filters) > 0 {
+		return nil
+	}
+	r.reset()
+	return fmt.Errorf("dba: %s requires a filter: %s", action, r.dm.schema.Name)
+}
+
+// deleteWithHooks 执行删除前后钩子，删除在事务中执行（未绑定事务时自行开启并提交），删除后钩子返回错误时回滚删除
+func (r *Result) deleteWithHooks(ctx context.Context, fn func(dm *DataModel) (int, error)) (int, error) {
+	scope := &HookScope{Event: HookBeforeDelete, Result: r.clone()}
+	if err := r.dm.runHooks(ctx, scope); err != nil {
+		r.reset()
+		return 0, r.dm.abort(err)
+	}
+	dm, err := r.dm.begin(ctx)
+	if err != nil {
+		r.reset()
+		return 0, err
+	}
+	n, err := fn(dm)
+	if err != nil {
+		return n, dm.abort(err)
+	}
+	scope.Event = HookAfterDelete
+	scope.RowsAffected = n
+	if err := r.dm.runHooks(ctx, scope); err != nil {
+		return 0, dm.abort(err)
+	}
+	if err := dm.commit(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
 
+func (r *Result) hardDelete(ctx context.Context, dm *DataModel) (int, error) {
 	// FINAL
 	defer r.reset()
 
-	data, attrs := r.beforeQuery()
+	if r.dm.schema.SoftDeleteField() != nil && r.deleted == deletedScopeExclude {
+		// 物理删除同样作用于已软删除的数据
+		r.deleted = deletedScopeInclude
+	}
+	data, attrs, err := r.beforeQuery()
+	if err != nil {
+		return 0, err
+	}
 	var buff bytes.Buffer
 	if err := r.dm.deleteTemplate.Execute(&buff, data); err != nil {
 		return 0, err
 	}
 	sql := buff.String()
-	sql = formatSQL(sql)
+	sql = r.dm.conn.Rebind(formatSQL(sql))
 
-	res, err := r.dm.xdb.Exec(sql, attrs...)
+	res, err := dm.execer().ExecContext(ctx, sql, attrs...)
 	if err != nil {
 		return 0, err
 	}
@@ -875,38 +1594,189 @@ func (r *Result) Delete(options ...*DeleteOptions) (int, error) {
 	return int(n), err
 }
 
+// Restore 恢复已软删除的数据
+func (r *Result) Restore() (int, error) {
+	return r.RestoreContext(context.Background())
+}
+
+func (r *Result) RestoreContext(ctx context.Context) (int, error) {
+	f := r.dm.schema.SoftDeleteField()
+	if f == nil {
+		r.reset()
+		return 0, fmt.Errorf("dba: schema has no soft delete field: %s", r.dm.schema.Name)
+	}
+	if err := r.requireFilters("restore"); err != nil {
+		return 0, err
+	}
+	return r.setDeleted(ctx, r.dm, f, false)
+}
+
+// setDeleted 通过dm（可能绑定事务）更新软删除字段，deleted为true时标记删除，否则恢复
+func (r *Result) setDeleted(ctx context.Context, dm *DataModel, f *Field, deleted bool) (int, error) {
+	// FINAL
+	defer r.reset()
+
+	if deleted {
+		r.deleted = deletedScopeExclude
+	} else {
+		r.deleted = deletedScopeOnly
+	}
+	data, attrs, err := r.beforeQuery()
+	if err != nil {
+		return 0, err
+	}
+	sets := []string{fmt.Sprintf("%s = ?", r.dm.conn.driver.QuoteIdentifier(f.NativeName))}
+	setAttrs := []any{softDeleteValue(f, deleted)}
+	if deleted {
+		// 记录删除人
+		for name, v := range r.dm.auditValues(ctx, AuditDeletedBy) {
+			sets = append(sets, fmt.Sprintf("%s = ?", r.dm.conn.driver.QuoteIdentifier(r.dm.schema.Fields[name].NativeName)))
+			setAttrs = append(setAttrs, v)
+		}
+	} else {
+		// 恢复时清空删除人
+		for _, field := range r.dm.schema.Fields {
+			if field.Audit == AuditDeletedBy {
+				sets = append(sets, fmt.Sprintf("%s = NULL", r.dm.conn.driver.QuoteIdentifier(field.NativeName)))
+			}
+		}
+	}
+	data["Sets"] = strings.Join(sets, ", ")
+	attrs = append(setAttrs, attrs...)
+
+	var buff bytes.Buffer
+	if err := r.dm.updateTemplate.Execute(&buff, data); err != nil {
+		return 0, err
+	}
+	sql := buff.String()
+	sql = r.dm.conn.Rebind(formatSQL(sql))
+
+	res, err := dm.execer().ExecContext(ctx, sql, attrs...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).Errorf("Soft delete failed: %v", err)
+	} else {
+		r.dm.conn.logger.WithField("sql", sql).WithField("attrs", attrs).WithField("rowsAffected", n).Infof("Soft delete successful")
+	}
+	return int(n), err
+}
+
+// softDeleteCondition 返回软删除字段的过滤条件，deleted为true时匹配已删除数据
+func softDeleteCondition(driver Driver, f *Field, deleted bool) (string, []any) {
+	return softDeleteColumnCondition(driver.QuoteIdentifier(f.NativeName), f, deleted)
+}
+
+// softDeleteColumnCondition 使用指定的列（如带表别名的列）生成软删除过滤条件
+func softDeleteColumnCondition(column string, f *Field, deleted bool) (string, []any) {
+	switch f.Type {
+	case Integer:
+		if deleted {
+			return fmt.Sprintf("(%s <> ?)", column), []any{0}
+		}
+		return fmt.Sprintf("(%s IS NULL OR %s = ?)", column, column), []any{0}
+	case Boolean:
+		if deleted {
+			return fmt.Sprintf("(%s = ?)", column), []any{true}
+		}
+		return fmt.Sprintf("(%s IS NULL OR %s = ?)", column, column), []any{false}
+	default:
+		if deleted {
+			return fmt.Sprintf("(%s IS NOT NULL)", column), nil
+		}
+		return fmt.Sprintf("(%s IS NULL)", column), nil
+	}
+}
+
+// softDeleteValue 返回软删除字段的写入值，deleted为false时返回恢复值
+func softDeleteValue(f *Field, deleted bool) any {
+	switch f.Type {
+	case Integer:
+		if deleted {
+			return time.Now().Unix()
+		}
+		return 0
+	case Boolean:
+		return deleted
+	default:
+		if deleted {
+			return time.Now()
+		}
+		return nil
+	}
+}
+
+// execer 优先使用模型绑定的事务执行语句
+func (dm *DataModel) execer() sqlx.ExecerContext {
+	if dm.xtx != nil {
+		return dm.xtx
+	}
+	return dm.xdb
+}
+
 func (r *Result) reset() {
 	r.filters = nil
 	r.orderBys = make(map[string]bool)
+	r.orderKeys = nil
 	r.limit = 0
 	r.offset = 0
 	r.cache = new(sync.Map)
 	r.populates = make([]*PopulateOptions, 0)
+	r.deleted = deletedScopeExclude
+	r.keyset = nil
+	r.groupBys = nil
+	r.havings = nil
+	r.aggregates = nil
+	r.primary = false
+}
+
+// clone 复制当前查询条件
+func (r *Result) clone() *Result {
+	copied := *r
+	copied.cache = new(sync.Map)
+	copied.filters = append([]*Filter(nil), r.filters...)
+	copied.orderBys = make(map[string]bool)
+	for k, v := range r.orderBys {
+		copied.orderBys[k] = v
+	}
+	copied.orderKeys = append([]string(nil), r.orderKeys...)
+	copied.groupBys = append([]string(nil), r.groupBys...)
+	copied.havings = append([]*Filter(nil), r.havings...)
+	copied.fields = append([]string(nil), r.fields...)
+	copied.populates = append([]*PopulateOptions(nil), r.populates...)
+	return &copied
 }
 
-func autoScan(dst any, xdb *sqlx.DB, sql string, attrs []any) error {
+func autoScan(ctx context.Context, dst any, q sqlx.QueryerContext, sql string, attrs []any) error {
 	ru := NewReflectValue(dst)
 
 	switch ru.ValueIs() {
 	case ValueIsStruct:
-		return xdb.Get(dst, sql, attrs...)
+		return sqlx.GetContext(ctx, q, dst, sql, attrs...)
 	case ValueIsMap:
-		return xdb.QueryRowx(sql, attrs...).MapScan(dst.(map[string]any))
+		return q.QueryRowxContext(ctx, sql, attrs...).MapScan(dst.(map[string]any))
 	case ValueIsStructArray:
-		return xdb.Select(dst, sql, attrs...)
+		return sqlx.SelectContext(ctx, q, dst, sql, attrs...)
 	case ValueIsMapArray:
-		rows, err := xdb.Queryx(sql, attrs...)
+		rows, err := q.QueryxContext(ctx, sql, attrs...)
 		if err != nil {
 			return err
 		}
 
-		sliceValue := reflect.ValueOf(CopyEmptyArray(ru.raw.Type()))
+		defer rows.Close()
+
+		sliceValue := reflect.ValueOf(CopyEmptyArray(ru.Type()))
 		for rows.Next() {
 			elem := CopyEmptyValue(ru.Type().Elem())
-			if err = rows.MapScan(elem.(map[string]any)); err == nil {
-				elemValue := reflect.ValueOf(elem)
-				sliceValue = reflect.Append(sliceValue, elemValue)
+			if err = rows.MapScan(elem.(map[string]any)); err != nil {
+				return err
 			}
+			sliceValue = reflect.Append(sliceValue, reflect.ValueOf(elem))
+		}
+		if err = rows.Err(); err != nil {
+			return err
 		}
 		ru.Set(sliceValue)
 	}
@@ -914,7 +1784,7 @@ func autoScan(dst any, xdb *sqlx.DB, sql string, attrs []any) error {
 	return nil
 }
 
-func relatesQuery(dst any, conn *Connection, sch *Schema, opts *PopulateOptions) (any, error) {
+func relatesQuery(ctx context.Context, dst any, conn *Connection, sch *Schema, opts *PopulateOptions) (any, error) {
 	field := sch.Fields[opts.Path]
 	rel := field.Relation
 	if opts.CustomRel != nil {
@@ -927,184 +1797,237 @@ func relatesQuery(dst any, conn *Connection, sch *Schema, opts *PopulateOptions)
 	dst = Item2List(dst)
 	ru := reflect.Indirect(reflect.ValueOf(dst))
 	ns := conn.ns
+	srcSch := sch
 
 	// 1.收集关联的ID
 	var srcValues []any
+	srcSeen := make(map[any]bool)
 	for i := 0; i < ru.Len(); i++ {
-		elem := ru.Index(i)
-		val := NewReflectValue(elem).FieldByName(rel.SrcField)
-		if val == nil {
+		key, val, ok := relationValue(ru.Index(i), srcSch, rel.SrcField)
+		if !ok || srcSeen[key] {
 			continue
 		}
+		srcSeen[key] = true
 		srcValues = append(srcValues, val)
 	}
-	switch rel.Kind {
-	case HasOne, ReferencesOne:
-		// 2.统一查询关联数据
-		DstModel := ns.Model(rel.DstSchema, &ModelOptions{ConnectionName: conn.name})
-
-		dstSliceRef, err := GetZeroSliceValueOfField(CopyEmptyValue(ru.Type().Elem()), opts.Path)
-		if err != nil {
-			return dst, err
+	if len(srcValues) == 0 {
+		return dst, nil
+	}
+	// 关联数据按关联值分组后回写
+	groups := make(map[any]reflect.Value)
+	// newSlice 创建可寻址的关联数据切片，数组字段（HAS_MANY/REF_MANY）使用字段类型
+	newSlice := func() (reflect.Value, error) {
+		elem := ru.Type().Elem()
+		for elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
 		}
-		if err := DstModel.Find(fmt.Sprintf("%s $IN", rel.DstField), srcValues).All(dstSliceRef.Addr().Interface()); err != nil {
-			return dst, err
+		if elem.Kind() == reflect.Map {
+			return reflect.New(reflect.SliceOf(elem)).Elem(), nil
 		}
-		// 3.建立映射
-		dstIdMapRef := make(map[any]*reflect.Value)
-		for i := 0; i < dstSliceRef.Len(); i++ {
-			re := NewReflectValue(dstSliceRef.Index(i))
-			dstId := re.FieldByName(rel.DstField)
-			if dstId == nil {
-				continue
-			}
-			dstIdMapRef[dstId] = dstId
+		if elem.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("populate field failed: %s.%s", sch.Name, opts.Path)
 		}
-		// 4.回写字段
-		for i := 0; i < ru.Len(); i++ {
-			elem := ru.Index(i)
-			val := NewReflectValue(elem).FieldByName(rel.SrcField)
-			if val == nil {
-				continue
-			}
-			_ = SetFieldOrKey(elem, opts.Path, dstIdMapRef[val].Interface())
+		sf, ok := elem.FieldByName(opts.Path)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("populate field failed: %s.%s", sch.Name, opts.Path)
 		}
-	case HasMany:
-		// 1.收集关联的ID
-		//（同上）
-		// 2.统一查询关联数据
-		DstModel := ns.Model(rel.DstSchema, &ModelOptions{ConnectionName: conn.name})
-		dstSliceRef, err := GetZeroSliceValueOfField(CopyEmptyValue(ru.Type().Elem()), opts.Path)
+		typ := sf.Type
+		if typ.Kind() != reflect.Slice {
+			typ = reflect.SliceOf(typ)
+		}
+		return reflect.New(typ).Elem(), nil
+	}
+	findAll := func(schemaName string, field string, values []any) (reflect.Value, error) {
+		slice, err := newSlice()
 		if err != nil {
-			return dst, err
+			return slice, err
 		}
-		if err := DstModel.Find(fmt.Sprintf("%s $IN", rel.DstField), srcValues).All(dstSliceRef.Addr().Interface()); err != nil {
+		model := ns.Model(schemaName, &ModelOptions{ConnectionName: conn.name})
+		err = model.Find(fmt.Sprintf("%s $IN", field), values).AllContext(ctx, slice.Addr().Interface())
+		return slice, err
+	}
+	switch rel.Kind {
+	case HasOne, ReferencesOne, HasMany:
+		// 2.统一查询关联数据
+		dstSch := ns.SchemaBy(rel.DstSchema)
+		dstSlice, err := findAll(rel.DstSchema, rel.DstField, srcValues)
+		if err != nil {
 			return dst, err
 		}
 		// 3.建立映射
-		dstIdMapRef := make(map[any]*reflect.Value)
-		for i := 0; i < dstSliceRef.Len(); i++ {
-			re := NewReflectValue(dstSliceRef.Index(i))
-			dstId := NewReflectValue(re).FieldByName(rel.DstField)
-			if dstId == nil {
+		for i := 0; i < dstSlice.Len(); i++ {
+			key, _, ok := relationValue(dstSlice.Index(i), dstSch, rel.DstField)
+			if !ok {
 				continue
 			}
-			if dstIdMapRef[dstId] == nil {
-				valuesRef, _ := GetZeroSliceValueOfField(CopyEmptyValue(ru.Type().Elem()), opts.Path)
-				dstIdMapRef[dstId] = &valuesRef
-			}
-			newValuesRef := reflect.Append(*dstIdMapRef[dstId], re.Value)
-			dstIdMapRef[dstId] = &newValuesRef
-		}
-		// 4.回写字段
-		for i := 0; i < ru.Len(); i++ {
-			elem := ru.Index(i)
-			val := NewReflectValue(elem).FieldByName(rel.SrcField)
-			if val == nil {
-				continue
+			if rel.Kind == HasMany {
+				group, ok := groups[key]
+				if !ok {
+					group = reflect.MakeSlice(dstSlice.Type(), 0, 0)
+				}
+				groups[key] = reflect.Append(group, dstSlice.Index(i))
+			} else if _, ok := groups[key]; !ok {
+				groups[key] = dstSlice.Index(i)
 			}
-			_ = SetFieldOrKey(elem, opts.Path, dstIdMapRef[val].Interface())
 		}
-
 	case ReferencesMany:
-		// 1.收集关联的ID
-		//（同上）
+		// 2.查询中间表数据
+		var brgPairs [][2]any
 		if rel.BrgIsNative {
-			// 2.统一查询关联数据
 			var allBrgData = make([]map[string]any, 0)
-			if err := conn.Query(&allBrgData, fmt.Sprintf(`SELECT * FROM %s WHERE %s IN (?)`, rel.BrgSchema, rel.BrgSrcField), srcValues); err != nil {
+			brgWhere, brgAttrs := parseInClause(conn.driver, conn.driver.QuoteIdentifier(rel.BrgSrcField), srcValues, false)
+			if err := conn.QueryContext(ctx, &allBrgData, fmt.Sprintf(`SELECT * FROM %s WHERE %s`, conn.driver.QuoteIdentifier(rel.BrgSchema), brgWhere), brgAttrs...); err != nil {
 				return dst, err
 			}
-			var allDstIds []any
 			for _, v := range allBrgData {
-				allDstIds = append(allDstIds, v[rel.BrgDstField])
-			}
-			DstModel := ns.Model(rel.DstSchema, &ModelOptions{ConnectionName: conn.name})
-			allDstSliceRef, err := GetZeroSliceValueOfField(CopyEmptyValue(ru.Type().Elem()), opts.Path)
-			if err != nil {
-				return dst, err
+				brgPairs = append(brgPairs, [2]any{v[rel.BrgSrcField], v[rel.BrgDstField]})
 			}
-			if err := DstModel.Find(fmt.Sprintf("%s $IN", rel.DstField), allDstIds).All(allDstSliceRef.Addr().Interface()); err != nil {
+		} else {
+			var allBrgData []map[string]any
+			BrgModel := ns.Model(rel.BrgSchema, &ModelOptions{ConnectionName: conn.name})
+			if err := BrgModel.Find(fmt.Sprintf("%s $IN", rel.BrgSrcField), srcValues).AllContext(ctx, &allBrgData); err != nil {
 				return dst, err
 			}
-			allDstIdMapRef := make(map[any]*reflect.Value)
-			for i := 0; i < allDstSliceRef.Len(); i++ {
-				re := NewReflectValue(allDstSliceRef.Index(i))
-				dstId := re.FieldByName(rel.DstField)
-				if dstId == nil {
-					continue
-				}
-				allDstIdMapRef[dstId] = &re.Value
-			}
-			// 3.建立映射
-			dstIdMapRef := make(map[any]*reflect.Value)
+			brgSch := ns.SchemaBy(rel.BrgSchema)
 			for _, v := range allBrgData {
-				srcIdValue := v[rel.BrgSrcField]
-				dstIdValue := v[rel.BrgDstField]
-				dstDataRef := allDstIdMapRef[dstIdValue]
-				if dstDataRef == nil {
-					continue
-				}
-				if dstIdMapRef[srcIdValue] == nil {
-					valuesRef, _ := GetZeroSliceValueOfField(CopyEmptyValue(ru.Type().Elem()), opts.Path)
-					dstIdMapRef[srcIdValue] = &valuesRef
+				doc := reflect.ValueOf(v)
+				_, srcId, ok1 := relationValue(doc, brgSch, rel.BrgSrcField)
+				_, dstId, ok2 := relationValue(doc, brgSch, rel.BrgDstField)
+				if ok1 && ok2 {
+					brgPairs = append(brgPairs, [2]any{srcId, dstId})
 				}
-				tmp := reflect.Append(*dstIdMapRef[srcIdValue], *dstDataRef)
-				dstIdMapRef[srcIdValue] = &tmp
-
-			}
-			// 4.回写字段
-			for i := 0; i < ru.Len(); i++ {
-				elem := ru.Index(i)
-				srcId := NewReflectValue(elem).FieldByName(rel.SrcField)
-				if srcId == nil {
-					continue
-				}
-				_ = SetFieldOrKey(elem, opts.Path, dstIdMapRef[srcId].Interface())
 			}
-		} else {
-			// 2.统一查询关联数据
-			BrgModel := ns.Model(rel.BrgSchema, &ModelOptions{ConnectionName: conn.name})
-			allBrgSliceRef, err := GetZeroSliceValueOfField(CopyEmptyValue(ru.Type().Elem()), opts.Path)
-			if err != nil {
-				return dst, err
+		}
+		var dstIds []any
+		dstSeen := make(map[any]bool)
+		for _, pair := range brgPairs {
+			if key := relationKey(pair[1]); key != nil && !dstSeen[key] {
+				dstSeen[key] = true
+				dstIds = append(dstIds, pair[1])
 			}
-			if err := BrgModel.Find(fmt.Sprintf("%s $IN", rel.BrgSrcField), srcValues).All(allBrgSliceRef.Addr().Interface()); err != nil {
-				return dst, err
+		}
+		if len(dstIds) == 0 {
+			break
+		}
+		// 3.统一查询关联数据并建立映射
+		dstSch := ns.SchemaBy(rel.DstSchema)
+		dstSlice, err := findAll(rel.DstSchema, rel.DstField, dstIds)
+		if err != nil {
+			return dst, err
+		}
+		dstByKey := make(map[any]reflect.Value)
+		for i := 0; i < dstSlice.Len(); i++ {
+			if key, _, ok := relationValue(dstSlice.Index(i), dstSch, rel.DstField); ok {
+				dstByKey[key] = dstSlice.Index(i)
 			}
-			// 3.建立映射
-			srcIdBrgMapRef := make(map[any]*reflect.Value)
-			for i := 0; i < allBrgSliceRef.Len(); i++ {
-				re := NewReflectValue(allBrgSliceRef.Index(i))
-				brgSrcId := re.FieldByName(rel.BrgSrcField)
-				if brgSrcId == nil {
-					continue
-				}
-
-				if srcIdBrgMapRef[brgSrcId] == nil {
-					valuesRef, _ := GetZeroSliceValueOfField(CopyEmptyValue(ru.Type().Elem()), opts.Path)
-					srcIdBrgMapRef[brgSrcId] = &valuesRef
-				}
-				tmp := reflect.Append(*srcIdBrgMapRef[brgSrcId], re.Value)
-				srcIdBrgMapRef[brgSrcId] = &tmp
+		}
+		for _, pair := range brgPairs {
+			srcKey, dstKey := relationKey(pair[0]), relationKey(pair[1])
+			doc, ok := dstByKey[dstKey]
+			if srcKey == nil || !ok {
+				continue
 			}
-			// 4.回写字段
-			for i := 0; i < ru.Len(); i++ {
-				elem := ru.Index(i)
-				srcId := NewReflectValue(elem).FieldByName(rel.SrcField)
-				if srcId == nil {
-					continue
-				}
-				_ = SetFieldOrKey(elem, opts.Path, srcIdBrgMapRef[srcId].Interface())
+			group, ok := groups[srcKey]
+			if !ok {
+				group = reflect.MakeSlice(dstSlice.Type(), 0, 0)
 			}
+			groups[srcKey] = reflect.Append(group, doc)
 		}
 	default:
 		return dst, fmt.Errorf("unknown relation: %s.%s[%s]", sch.Name, opts.Path, rel.Kind)
 	}
+	// 4.回写字段
+	for i := 0; i < ru.Len(); i++ {
+		elem := ru.Index(i)
+		key, _, ok := relationValue(elem, srcSch, rel.SrcField)
+		if !ok {
+			continue
+		}
+		if value, ok := groups[key]; ok {
+			setRelationField(elem, opts.Path, value)
+		}
+	}
 
 	return dst, nil
 }
 
+// relationValue 读取数据的关联字段值，map按字段名或列名读取，返回值的比较键及参数值
+func relationValue(doc reflect.Value, sch *Schema, name string) (any, any, bool) {
+	rv := NewReflectValue(doc)
+	for rv.Value.Kind() == reflect.Interface || rv.Value.Kind() == reflect.Ptr {
+		if rv.Value.IsNil() {
+			return nil, nil, false
+		}
+		rv = NewReflectValue(rv.Value.Elem())
+	}
+	val := rv.FieldByName(name)
+	if val == nil && sch != nil {
+		if f := sch.Fields[name]; f != nil {
+			val = rv.FieldByName(f.NativeName)
+		}
+	}
+	if val == nil {
+		return nil, nil, false
+	}
+	v := *val
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil, false
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil, nil, false
+	}
+	key := relationKey(v.Interface())
+	if key == nil {
+		return nil, nil, false
+	}
+	return key, v.Interface(), true
+}
+
+// relationKey 关联值的比较键，忽略整数类型及[]byte与string的差异
+func relationKey(v any) any {
+	switch val := indirectValue(v).(type) {
+	case nil:
+		return nil
+	case []byte:
+		return string(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// setRelationField 回写关联字段，结构体按字段名、map按键写入
+func setRelationField(elem reflect.Value, name string, value reflect.Value) {
+	for elem.Kind() == reflect.Interface || elem.Kind() == reflect.Ptr {
+		if elem.IsNil() {
+			return
+		}
+		elem = elem.Elem()
+	}
+	switch elem.Kind() {
+	case reflect.Struct:
+		f := elem.FieldByName(name)
+		if !f.CanSet() {
+			return
+		}
+		if value.Kind() == reflect.Interface {
+			value = value.Elem()
+		}
+		switch {
+		case value.Type().AssignableTo(f.Type()):
+			f.Set(value)
+		case value.Kind() == reflect.Ptr && value.Elem().Type().AssignableTo(f.Type()):
+			f.Set(value.Elem())
+		case f.Kind() == reflect.Ptr && value.Type().AssignableTo(f.Type().Elem()) && value.CanAddr():
+			f.Set(value.Addr())
+		}
+	case reflect.Map:
+		elem.SetMapIndex(reflect.ValueOf(name), value)
+	}
+}
+
 func calcFieldStrategy(sch *Schema, opts *RelatesWriteOptions) map[string]int {
 	fieldStrategy := make(map[string]int)
 	// 指定了的字段走策略
@@ -1152,7 +2075,7 @@ type updatePair struct {
 	values any
 }
 
-func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error {
+func relatesWrite(ctx context.Context, in any, SrcModel *DataModel, opts *RelatesWriteOptions) error {
 	srcSch := SrcModel.schema
 	tx := SrcModel.xtx
 	conn := SrcModel.conn
@@ -1188,7 +2111,7 @@ func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error
 			switch rel.Kind {
 			case HasOne:
 				storedDoc := NewReflectValue(NewVar(fieldValue))
-				if err := DstModel.Find(fmt.Sprintf("%s", rel.DstField), srcId).One(storedDoc.Addr().Interface()); err != nil {
+				if err := DstModel.Find(fmt.Sprintf("%s", rel.DstField), srcId).OneContext(ctx, storedDoc.Addr().Interface()); err != nil {
 					return err
 				}
 				storedDocValues := storedDoc.Map()
@@ -1214,14 +2137,14 @@ func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error
 					if fieldStrategy[name] >= 2 {
 						// update
 						if len(filter) > 0 && len(values) > 0 {
-							if _, err := DstModel.Find(filter).Update(values); err != nil {
+							if _, err := DstModel.Find(filter).UpdateContext(ctx, values); err != nil {
 								return err
 							}
 						}
 					}
 				} else {
 					// create
-					if err := DstModel.Create(inputDocValues); err != nil {
+					if err := DstModel.CreateContext(ctx, inputDocValues); err != nil {
 						return err
 					}
 				}
@@ -1235,14 +2158,14 @@ func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error
 					}
 					if len(filter) > 0 {
 						if fieldStrategy[name] >= 3 {
-							if _, err := DstModel.Find(filter).Delete(); err != nil {
+							if _, err := DstModel.Find(filter).DeleteContext(ctx); err != nil {
 								return err
 							}
 						} else {
 							values := map[string]any{
 								rel.DstField: SetToNullFlag,
 							}
-							if _, err := DstModel.Find(filter).Update(values); err != nil {
+							if _, err := DstModel.Find(filter).UpdateContext(ctx, values); err != nil {
 								return err
 							}
 						}
@@ -1250,7 +2173,7 @@ func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error
 				}
 			case HasMany:
 				storedDocs := NewReflectValue(NewVar(fieldValue))
-				if err := DstModel.Find(fmt.Sprintf("%s", rel.DstField), srcId).All(storedDocs.Addr().Interface()); err != nil {
+				if err := DstModel.Find(fmt.Sprintf("%s", rel.DstField), srcId).AllContext(ctx, storedDocs.Addr().Interface()); err != nil {
 					return err
 				}
 
@@ -1306,7 +2229,7 @@ func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error
 				if fieldStrategy[name] >= 1 {
 					// APPEND
 					if len(createDocs) > 0 {
-						if err := DstModel.Create(createDocs); err != nil {
+						if err := DstModel.CreateContext(ctx, createDocs); err != nil {
 							return err
 						}
 					}
@@ -1316,7 +2239,7 @@ func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error
 					// UPSERT
 					if len(updateDocs) > 0 {
 						for _, item := range updateDocs {
-							if _, err := DstModel.Find(item.filter).Update(item.values); err != nil {
+							if _, err := DstModel.Find(item.filter).UpdateContext(ctx, item.values); err != nil {
 								return err
 							}
 						}
@@ -1326,7 +2249,7 @@ func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error
 				if fieldStrategy[name] >= 3 {
 					// REPLACE
 					if len(deleteFilters) > 0 {
-						if _, err := DstModel.Find(Or(deleteFilters...)).Delete(); err != nil {
+						if _, err := DstModel.Find(Or(deleteFilters...)).DeleteContext(ctx); err != nil {
 							return err
 						}
 					}
@@ -1342,7 +2265,7 @@ func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error
 						}
 					}
 					if len(filter) > 0 {
-						if _, err := SrcModel.Find(filter).Update(map[string]any{
+						if _, err := SrcModel.Find(filter).UpdateContext(ctx, map[string]any{
 							rel.SrcField: dstID,
 						}); err != nil {
 							return err
@@ -1376,7 +2299,7 @@ func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error
 					brgSrcField = brgSch.Fields[rel.BrgSrcField]
 					brgDstField = brgSch.Fields[rel.BrgDstField]
 
-					brgSchemaNative = brgSch.Name
+					brgSchemaNative = brgSch.NativeName
 					brgSrcFieldNative = brgSrcField.NativeName
 					brgDstFieldNative = brgDstField.NativeName
 				}
@@ -1386,7 +2309,7 @@ func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error
 				} else {
 					storedDocs = NewReflectValue(NewVar(fieldValue))
 				}
-				if err := conn.Query(storedDocs.Addr().Interface(), fmt.Sprintf(`SELECT %s FROM %s WHERE %s = ?`, brgDstFieldNative, brgSchemaNative, brgSrcFieldNative), srcId); err != nil {
+				if err := conn.QueryContext(ctx, storedDocs.Addr().Interface(), fmt.Sprintf(`SELECT %s FROM %s WHERE %s = ?`, conn.driver.QuoteIdentifier(brgDstFieldNative), conn.driver.QuoteIdentifier(brgSchemaNative), conn.driver.QuoteIdentifier(brgSrcFieldNative)), srcId); err != nil {
 					return err
 				}
 
@@ -1435,7 +2358,7 @@ func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error
 					// APPEND + UPSERT
 					if len(updateDocs) > 0 {
 						for _, item := range updateDocs {
-							if _, err := DstModel.Find(item.filter).Update(item.values); err != nil {
+							if _, err := DstModel.Find(item.filter).UpdateContext(ctx, item.values); err != nil {
 								return err
 							}
 						}
@@ -1446,7 +2369,7 @@ func relatesWrite(in any, SrcModel *DataModel, opts *RelatesWriteOptions) error
 					// REPLACE
 					if len(existsIDs) > 0 {
 						// TODO
-						//if _, err := DstModel.Find(Or(deleteFilters...)).Delete(); err != nil {
+						//if _, err := DstModel.Find(Or(deleteFilters...)).DeleteContext(ctx); err != nil {
 						//	return err
 						//}
 					}
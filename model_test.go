@@ -0,0 +1,321 @@
+package dba
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type CtxItem struct {
+	ID   uint `dba:"pk;incr"`
+	Name string
+}
+
+func TestContextCanceled(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &CtxItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	model := conn.ns.Model("CtxItem")
+	if _, err := conn.ExecContext(context.Background(), "INSERT INTO ctx_item (name) VALUES (?)", "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	items := make([]*CtxItem, 0)
+	for name, fn := range map[string]func() error{
+		"Exec": func() error {
+			_, err := conn.ExecContext(ctx, "DELETE FROM ctx_item")
+			return err
+		},
+		"Query": func() error {
+			return conn.QueryContext(ctx, map[string]any{}, "SELECT * FROM ctx_item")
+		},
+		"Create": func() error {
+			return model.CreateContext(ctx, map[string]any{"Name": "b"})
+		},
+		"One": func() error {
+			var item CtxItem
+			return model.Find().OneContext(ctx, &item)
+		},
+		"All": func() error {
+			return model.Find().AllContext(ctx, &items)
+		},
+		"Count": func() error {
+			_, err := model.Find().CountContext(ctx)
+			return err
+		},
+		"Update": func() error {
+			_, err := model.Find("Name", "a").UpdateContext(ctx, map[string]any{"Name": "c"})
+			return err
+		},
+		"Delete": func() error {
+			_, err := model.Find("Name", "a").DeleteContext(ctx)
+			return err
+		},
+	} {
+		if err := fn(); !errors.Is(err, context.Canceled) {
+			t.Fatalf("%s: expected context.Canceled, got %v", name, err)
+		}
+	}
+	// 取消的操作未产生任何修改
+	if err := model.Find().All(&items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "a" {
+		t.Fatalf("unexpected rows: %+v", items)
+	}
+}
+
+type InItem struct {
+	ID   uint `dba:"pk"`
+	Code string
+}
+
+func TestInClause(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &InItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	const total = 1500
+	if _, err := conn.Exec(`WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < ?)
+		INSERT INTO in_item (id, code) SELECT n, 'c' || n FROM seq`, total); err != nil {
+		t.Fatal(err)
+	}
+	count := func(conditions ...any) int {
+		t.Helper()
+		rows := make([]*InItem, 0)
+		if err := conn.ns.Model("InItem").Find(conditions...).All(&rows); err != nil {
+			t.Fatal(err)
+		}
+		return len(rows)
+	}
+	ids := make([]uint, 1200)
+	codes := make([]string, 1200)
+	for i := range ids {
+		ids[i] = uint(i + 1)
+		codes[i] = fmt.Sprintf("c%d", i+1)
+	}
+	for _, tc := range []struct {
+		name       string
+		conditions []any
+		want       int
+	}{
+		{"in", []any{"ID $IN", []int{1, 2, 3}}, 3},
+		{"not in", []any{"ID $NIN", []int{1, 2, 3}}, total - 3},
+		{"array", []any{"Code $IN", [2]string{"c1", "c9"}}, 2},
+		{"scalar", []any{"ID $IN", 5}, 1},
+		{"empty in", []any{"ID $IN", []int{}}, 0},
+		{"empty not in", []any{"ID $NIN", []int{}}, total},
+		// 超过占位符上限时作为JSON参数传入
+		{"json ints", []any{"ID $IN", ids}, 1200},
+		{"json strings", []any{"Code $IN", codes}, 1200},
+		{"json not in", []any{"ID $NIN", ids}, total - 1200},
+	} {
+		if got := count(tc.conditions...); got != tc.want {
+			t.Fatalf("%s: got %d, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		driver string
+		values any
+		not    bool
+		want   string
+		args   int
+	}{
+		{PostgreSQL, []int{1, 2}, false, "(id = ANY(?))", 1},
+		{PostgreSQL, []int{1, 2}, true, "(id <> ALL(?))", 1},
+		{MySQL, []int{1, 2}, false, "(id IN (?,?))", 2},
+		{MySQL, ids, true, "(id NOT IN (SELECT v FROM JSON_TABLE(?, '$[*]' COLUMNS (v BIGINT PATH '$')) AS dba_in))", 1},
+		{MySQL, codes, false, "(id IN (SELECT CONVERT(v USING utf8mb4) FROM JSON_TABLE(?, '$[*]' COLUMNS (v VARCHAR(1024) PATH '$')) AS dba_in))", 1},
+		{SQLite, ids, false, "(id IN (SELECT value FROM json_each(?)))", 1},
+	} {
+		got, args := parseInClause(drivers[tc.driver], "id", tc.values, tc.not)
+		if got != tc.want || len(args) != tc.args {
+			t.Fatalf("%s: got %s with %d args", tc.driver, got, len(args))
+		}
+	}
+}
+
+type Keyword struct {
+	ID    uint `dba:"pk;incr"`
+	Order int
+	Group string
+	User  string
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	for name, want := range map[string]string{
+		SQLite:     "`user`.`na``me`",
+		MySQL:      "`user`.`na``me`",
+		PostgreSQL: "\"user\".\"na`me\"",
+	} {
+		if got := drivers[name].QuoteIdentifier("user.na`me"); got != want {
+			t.Fatalf("%s: got %s", name, got)
+		}
+	}
+
+	// 列名为保留字时各类语句均可执行
+	conn := newTestConnection(t, testDSN(t), &Keyword{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	model := func() *DataModel { return conn.ns.Model("Keyword") }
+	if _, err := conn.Exec("INSERT INTO keyword (`order`, `group`, `user`) VALUES (?, ?, ?), (?, ?, ?)", 2, "a", "x", 1, "b", "y"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ns.Model("Keyword", &ModelOptions{Tx: tx}).Find("Group", "a").Update(&map[string]any{"User": "z", "Order": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	list := make([]*Keyword, 0)
+	if err := model().Find("Order >", 0).OrderBy("Order").Select("ID", "Order", "User").All(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].User != "y" || list[1].User != "z" || list[1].Order != 3 || list[1].Group != "" {
+		t.Fatalf("unexpected rows: %+v", list)
+	}
+
+	// 严格模式拒绝非模型字段
+	strict := true
+	for name, r := range map[string]*Result{
+		"filter": conn.ns.Model("Keyword", &ModelOptions{Strict: &strict}).Find("Missing", 1),
+		"order":  conn.ns.Model("Keyword", &ModelOptions{Strict: &strict}).Find().OrderBy("Missing"),
+		"select": conn.ns.Model("Keyword", &ModelOptions{Strict: &strict}).Find().Select("Missing"),
+	} {
+		if err := r.All(&list); err == nil || !strings.Contains(err.Error(), "unknown field: Keyword.Missing") {
+			t.Fatalf("%s: expected unknown field error, got %v", name, err)
+		}
+	}
+}
+
+type KeyItem struct {
+	ID   uint `dba:"pk;incr"`
+	Name string
+}
+
+// lastInsertIDDriver 禁用RETURNING，使用LastInsertId回填主键
+type lastInsertIDDriver struct {
+	Driver
+}
+
+func (lastInsertIDDriver) SupportsReturning() bool {
+	return false
+}
+
+func TestCreateBackfillKeys(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &KeyItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for _, returning := range []bool{true, false} {
+		if !returning {
+			conn.driver = lastInsertIDDriver{conn.driver}
+		}
+		model := conn.ns.Model("KeyItem")
+		item := &KeyItem{Name: "one"}
+		if err := model.Create(item); err != nil {
+			t.Fatal(err)
+		}
+		doc := map[string]any{"Name": "map"}
+		if err := model.Create(doc); err != nil {
+			t.Fatal(err)
+		}
+		if id, err := strconv.Atoi(fmt.Sprint(doc["ID"])); err != nil || item.ID == 0 || uint(id) != item.ID+1 {
+			t.Fatalf("returning=%v: unexpected keys: %d, %v", returning, item.ID, doc["ID"])
+		}
+		if !returning {
+			// SQLite批量插入时LastInsertId为最后一行，按首行递增的回填仅适用于MySQL
+			continue
+		}
+		batch := []*KeyItem{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+		if err := model.Create(batch); err != nil {
+			t.Fatal(err)
+		}
+		if batch[0].ID != item.ID+2 || batch[2].ID != item.ID+4 {
+			t.Fatalf("unexpected batch keys: %d, %d", batch[0].ID, batch[2].ID)
+		}
+		// 回填的主键与数据库中一致
+		var stored KeyItem
+		if err := model.Find("ID", batch[1].ID).One(&stored); err != nil || stored.Name != "b" {
+			t.Fatalf("unexpected row: %+v, %v", stored, err)
+		}
+	}
+}
+
+type SoftItem struct {
+	ID        uint `dba:"pk;incr"`
+	Name      string
+	DeletedAt *time.Time `dba:"soft_delete"`
+}
+
+type SoftFlagItem struct {
+	ID      uint `dba:"pk;incr"`
+	Name    string
+	Deleted bool `dba:"soft_delete"`
+}
+
+func TestSoftDelete(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &SoftItem{}, &SoftFlagItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"SoftItem", "SoftFlagItem"} {
+		model := func() *DataModel { return conn.ns.Model(name) }
+		count := func(r *Result) int {
+			t.Helper()
+			n, err := r.Count()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return n
+		}
+		if err := model().Create([]map[string]any{{"Name": "a"}, {"Name": "b"}, {"Name": "c"}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := model().Find().Delete(); err == nil {
+			t.Fatalf("%s: expected error for delete without filters", name)
+		}
+		if n, err := model().Find("Name $IN", []string{"a", "b"}).Delete(); err != nil || n != 2 {
+			t.Fatalf("%s: soft delete: %d, %v", name, n, err)
+		}
+		if got := []int{count(model().Find()), count(model().Find().WithDeleted()), count(model().Find().OnlyDeleted())}; !reflect.DeepEqual(got, []int{1, 3, 2}) {
+			t.Fatalf("%s: unexpected counts: %v", name, got)
+		}
+		// 已删除的数据不会被再次删除或更新
+		if n, err := model().Find("Name", "a").Delete(); err != nil || n != 0 {
+			t.Fatalf("%s: delete deleted row: %d, %v", name, n, err)
+		}
+		if n, err := model().Find("Name", "a").Restore(); err != nil || n != 1 {
+			t.Fatalf("%s: restore: %d, %v", name, n, err)
+		}
+		if n := count(model().Find()); n != 2 {
+			t.Fatalf("%s: expected 2 rows after restore, got %d", name, n)
+		}
+		if n, err := model().Find("Name", "b").HardDelete(); err != nil || n != 1 {
+			t.Fatalf("%s: hard delete: %d, %v", name, n, err)
+		}
+		if n, err := model().Find("Name", "c").Delete(&DeleteOptions{Hard: true}); err != nil || n != 1 {
+			t.Fatalf("%s: hard delete option: %d, %v", name, n, err)
+		}
+		if n := count(model().Find().WithDeleted()); n != 1 {
+			t.Fatalf("%s: expected 1 row after hard delete, got %d", name, n)
+		}
+	}
+	if _, err := newTestConnection(t, testDSN(t), &KeyItem{}).ns.Model("KeyItem").Find("ID", 1).Restore(); err == nil {
+		t.Fatal("expected error for restore without soft delete field")
+	}
+}
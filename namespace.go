@@ -1,13 +1,16 @@
 package dba
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"text/template"
 	"time"
 
 	"github.com/Masterminds/sprig/v3"
+	"github.com/iancoleman/strcase"
 	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -32,6 +35,10 @@ type Namespace struct {
 	Name        string
 	connections *sync.Map
 	schemas     *sync.Map
+
+	actorProvider ActorProvider
+	hooksMu       sync.RWMutex
+	hooks         map[string]map[HookEvent][]HookFunc
 }
 
 type ConnectConfig struct {
@@ -42,7 +49,42 @@ type ConnectConfig struct {
 	DeleteClauses string         `json:"delete_clauses,omitempty"`
 	UpdateClauses string         `json:"update_clauses,omitempty"`
 	QueryClauses  string         `json:"query_clauses,omitempty"`
+	Strict        bool           `json:"strict,omitempty"` // 严格模式：过滤、排序、查询字段必须为模型字段
+	CursorSecret  string         `json:"-"`                // 游标签名密钥，未设置时使用进程内随机密钥
 	Logger        *logrus.Logger `json:"-"`
+
+	Replicas             []string        `json:"replicas,omitempty"`               // 只读副本DSN，查询操作路由到副本，写入及事务使用主库
+	ReplicaBalance       string          `json:"replica_balance,omitempty"`        // 副本负载均衡策略：round_robin（默认）、random、least_latency
+	ReplicaCheckInterval time.Duration   `json:"replica_check_interval,omitempty"` // 副本健康检查间隔，默认DefaultReplicaCheckInterval
+	Balancer             ReplicaBalancer `json:"-"`                                // 自定义负载均衡策略，优先于ReplicaBalance
+
+	// 连接池配置，同时作用于主库及副本，未设置（零值）时使用database/sql的默认值
+	MaxOpenConns    int           `json:"max_open_conns,omitempty"`     // 最大打开连接数
+	MaxIdleConns    int           `json:"max_idle_conns,omitempty"`     // 最大空闲连接数，小于0时不保留空闲连接
+	ConnMaxLifetime time.Duration `json:"conn_max_lifetime,omitempty"`  // 连接最大存活时间
+	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time,omitempty"` // 连接最大空闲时间
+}
+
+// configurePool 按配置设置连接池参数
+func configurePool(xdb *sqlx.DB, config *ConnectConfig) {
+	if config.MaxOpenConns > 0 {
+		xdb.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns != 0 {
+		xdb.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetime > 0 {
+		xdb.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+	if config.ConnMaxIdleTime > 0 {
+		xdb.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	}
+}
+
+// newColumnMapper 扫描结果时按snake_case匹配结构体字段，与模型原生字段名的默认规则（strcase.ToSnake）一致，
+// sqlx默认的小写规则无法匹配CreatedAt等多单词字段；通过native指定列名的字段需同时声明db标签
+func newColumnMapper() *reflectx.Mapper {
+	return reflectx.NewMapperFunc("db", strcase.ToSnake)
 }
 
 func (ns *Namespace) Connect(config *ConnectConfig) (*Connection, error) {
@@ -58,6 +100,8 @@ func (ns *Namespace) Connect(config *ConnectConfig) (*Connection, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "dba: connect failed")
 	}
+	xdb.Mapper = newColumnMapper()
+	configurePool(xdb, config)
 	if config.Name == "" {
 		count := 0
 		ns.connections.Range(func(key, value any) bool {
@@ -71,12 +115,18 @@ func (ns *Namespace) Connect(config *ConnectConfig) (*Connection, error) {
 		logger = globalLogger
 	}
 	conn := &Connection{
-		ns:     ns,
-		driver: driver,
-		dsn:    config.Dsn,
-		name:   config.Name,
-		xdb:    xdb,
-		logger: logger,
+		ns:           ns,
+		driver:       driver,
+		dsn:          config.Dsn,
+		name:         config.Name,
+		xdb:          xdb,
+		strict:       config.Strict,
+		logger:       logger,
+		cursorSecret: []byte(config.CursorSecret),
+	}
+	if err := conn.connectReplicas(config); err != nil {
+		_ = xdb.Close()
+		return nil, err
 	}
 	var (
 		createClauses = config.CreateClauses
@@ -100,7 +150,12 @@ func (ns *Namespace) Connect(config *ConnectConfig) (*Connection, error) {
 	conn.DeleteTemplate = template.Must(template.New("").Funcs(sprig.FuncMap()).Parse(deleteClauses))
 	conn.UpdateTemplate = template.Must(template.New("").Funcs(sprig.FuncMap()).Parse(updateClauses))
 	conn.QueryTemplate = template.Must(template.New("").Funcs(sprig.FuncMap()).Parse(queryClauses))
-	ns.connections.Store(config.Name, conn)
+	// 同名连接被替换时关闭原连接
+	if old, ok := ns.connections.Swap(config.Name, conn); ok {
+		if err := old.(*Connection).Close(); err != nil {
+			logger.Errorf("Close connection %s failed: %v", config.Name, err)
+		}
+	}
 	return conn, nil
 }
 
@@ -125,9 +180,15 @@ func (ns *Namespace) ConnectionNames() []string {
 	return names
 }
 
+// Disconnect 移除并关闭指定的连接
 func (ns *Namespace) Disconnect(name ...string) {
 	for _, item := range name {
-		ns.connections.Delete(item)
+		if v, ok := ns.connections.LoadAndDelete(item); ok {
+			conn := v.(*Connection)
+			if err := conn.Close(); err != nil {
+				conn.logger.Errorf("Close connection %s failed: %v", item, err)
+			}
+		}
 	}
 }
 
@@ -200,18 +261,22 @@ func (ns *Namespace) setRelations() {
 				if rel != nil {
 					needUpdate = true
 					field.Relation = rel
-					if rel.BrgIsNative && rel.BrgSchema != "" {
+					if rel.BrgIsNative && rel.BrgSchema != "" && schs[rel.BrgSchema] == nil {
 						srcField := s.Fields[rel.SrcField]
 						dstField := schs[rel.DstSchema].Fields[rel.DstField]
 
+						// 原生中间表以两侧字段作为联合主键
 						mockNativeSchema := Schema{
-							Name:       rel.BrgSchema,
-							NativeName: rel.BrgSchema,
+							isNativeBridge: true,
+							Name:           rel.BrgSchema,
+							NativeName:     rel.BrgSchema,
 							Fields: map[string]*Field{
 								rel.BrgSrcField: {
 									Name:           rel.BrgSrcField,
 									NativeName:     rel.BrgSrcField,
 									RequiredConfig: "true",
+									IsPrimary:      true,
+									IsUnsigned:     srcField.IsUnsigned,
 									Type:           srcField.Type,
 									NativeType:     srcField.NativeType,
 								},
@@ -219,6 +284,8 @@ func (ns *Namespace) setRelations() {
 									Name:           rel.BrgDstField,
 									NativeName:     rel.BrgDstField,
 									RequiredConfig: "true",
+									IsPrimary:      true,
+									IsUnsigned:     dstField.IsUnsigned,
 									Type:           dstField.Type,
 									NativeType:     dstField.NativeType,
 								},
@@ -242,9 +309,22 @@ func (ns *Namespace) setRelations() {
 	}
 }
 
+// SetActorProvider 设置审计字段使用的操作人来源，默认读取WithActor写入上下文的操作人
+func (ns *Namespace) SetActorProvider(provider ActorProvider) {
+	ns.actorProvider = provider
+}
+
+func (ns *Namespace) actor(ctx context.Context) *Actor {
+	if ns.actorProvider != nil {
+		return ns.actorProvider(ctx)
+	}
+	return ActorFromContext(ctx)
+}
+
 type ModelOptions struct {
 	ConnectionName string
 	Tx             *sqlx.Tx
+	Strict         *bool // 严格模式，为nil时沿用连接配置
 }
 
 func (ns *Namespace) Init(connectionName ...string) error {
@@ -254,8 +334,10 @@ func (ns *Namespace) Init(connectionName ...string) error {
 	}
 	for _, name := range connectionName {
 		conn := ns.Session(name)
-		ddl := conn.GenDDL(schs, true)
-		if _, err := conn.Exec(ddl); err != nil {
+		if conn == nil {
+			return errors.Errorf("dba: connection not exists: %s", name)
+		}
+		if err := conn.Init(schs); err != nil {
 			return err
 		}
 	}
@@ -297,11 +379,17 @@ func (ns *Namespace) Model(schemaName string, options ...*ModelOptions) *DataMod
 		queryTemplate = template.Must(template.New("").Funcs(sprig.FuncMap()).Parse(s.QueryClauses))
 	}
 
+	strict := conn.strict
+	if opts.Strict != nil {
+		strict = *opts.Strict
+	}
+
 	return &DataModel{
 		conn:           conn,
 		schema:         s,
 		xdb:            conn.xdb,
 		xtx:            opts.Tx,
+		strict:         strict,
 		createTemplate: createTemplate,
 		deleteTemplate: deleteTemplate,
 		updateTemplate: updateTemplate,
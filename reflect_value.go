@@ -87,9 +87,7 @@ func (rv *ReflectValue) ValueIs() ValueIs {
 	case reflect.Map:
 		return ValueIsMap
 	case reflect.Slice, reflect.Array:
-		if v.IsNil() {
-			return ValueIsUnknown
-		}
+		// nil切片同样可根据元素类型判断（如 var list []*User）
 		elemType := v.Type().Elem()
 		// 处理元素为指针的情况
 		for elemType.Kind() == reflect.Ptr {
@@ -209,20 +207,28 @@ func (rv *ReflectValue) Map() map[string]any {
 	return entries
 }
 
-func parseStructToMap(v reflect.Value, result map[string]any, visited map[uintptr]bool) {
+// visitKey 已展开的结构体，偏移为0的嵌入结构体与外层地址相同，需同时比较类型
+type visitKey struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+func parseStructToMap(v reflect.Value, result map[string]any, visited map[visitKey]bool) {
 	if !v.IsValid() {
 		return
 	}
 
 	// 防止循环引用
 	if visited == nil {
-		visited = make(map[uintptr]bool)
+		visited = make(map[visitKey]bool)
 	}
-	ptr := v.Pointer()
-	if visited[ptr] {
-		return
+	if v.CanAddr() {
+		key := visitKey{typ: v.Type(), ptr: v.Addr().Pointer()}
+		if visited[key] {
+			return
+		}
+		visited[key] = true
 	}
-	visited[ptr] = true
 
 	t := v.Type()
 	for i := 0; i < v.NumField(); i++ {
@@ -252,8 +258,8 @@ func parseStructToMap(v reflect.Value, result map[string]any, visited map[uintpt
 			continue
 		}
 
-		// 处理嵌套结构体
-		if field.Kind() == reflect.Struct {
+		// 仅展开嵌入结构体，其余结构体（如time.Time、关联档案）作为字段值保留
+		if field.Kind() == reflect.Struct && fieldType.Anonymous {
 			parseStructToMap(field, result, visited)
 		} else {
 			result[fieldType.Name] = field.Interface()
@@ -694,7 +700,7 @@ func SetFieldOrKey(elem any, k string, v any) (err error) {
 		}
 	case reflect.Map:
 		// 处理 map 类型，通过键名设置对应的值
-		value.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(value))
+		value.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
 	default:
 	}
 
@@ -0,0 +1,100 @@
+package dba
+
+import (
+	"fmt"
+	"strings"
+)
+
+// relationFilter 将关系路径过滤（如Org.Name $PREFIX、Tags.Name $IN）转换为EXISTS子查询：
+// 存在满足条件的关联数据时匹配，路径可以跨越多层关系，关联模型的软删除数据被排除。
+// outer为外层表名或别名（已转义），depth用于生成子查询中的表别名；键不是关系路径时返回false
+func relationFilter(driver Driver, ns *Namespace, sch *Schema, outer string, entry *Entry, depth int) (string, []any, bool, error) {
+	name, rest, ok := strings.Cut(entry.Key, ".")
+	if !ok || ns == nil {
+		return "", nil, false, nil
+	}
+	field := sch.Fields[name]
+	if !field.Valid() || !field.Relation.Valid() {
+		return "", nil, false, nil
+	}
+	rel := field.Relation
+	dstSch := ns.SchemaBy(rel.DstSchema)
+	if dstSch == nil {
+		return "", nil, false, fmt.Errorf("dba: relation schema not exists: %s.%s", sch.Name, name)
+	}
+	srcField, dstField := sch.Fields[rel.SrcField], dstSch.Fields[rel.DstField]
+	if srcField == nil || dstField == nil {
+		return "", nil, false, fmt.Errorf("dba: invalid relation: %s.%s", sch.Name, name)
+	}
+	alias := driver.QuoteIdentifier(fmt.Sprintf("r%d", depth))
+
+	// 关联模型上的条件
+	var (
+		conds []string
+		attrs []any
+	)
+	sub := &Entry{Key: rest, Op: entry.Op, Value: entry.Value}
+	s, a, ok, err := relationFilter(driver, ns, dstSch, alias, sub, depth+1)
+	if err != nil {
+		return "", nil, false, err
+	}
+	if !ok {
+		f := dstSch.Fields[rest]
+		if !f.Valid() {
+			f = dstSch.NativeFields()[rest]
+		}
+		if !f.Valid() || f.NativeName == "" {
+			return "", nil, false, fmt.Errorf("dba: invalid filter field: %s.%s", sch.Name, entry.Key)
+		}
+		if f.IsVirtual || !f.IsScalarType() {
+			return "", nil, false, fmt.Errorf("dba: field cannot be filtered: %s.%s", sch.Name, entry.Key)
+		}
+		if s, a, err = entryCondition(driver, alias+"."+driver.QuoteIdentifier(f.NativeName), f, sub); err != nil {
+			return "", nil, false, err
+		}
+	}
+	conds = append(conds, s)
+	attrs = append(attrs, a...)
+	if f := dstSch.SoftDeleteField(); f != nil {
+		s, a := softDeleteColumnCondition(alias+"."+driver.QuoteIdentifier(f.NativeName), f, false)
+		conds = append(conds, s)
+		attrs = append(attrs, a...)
+	}
+
+	srcColumn := outer + "." + driver.QuoteIdentifier(srcField.NativeName)
+	dstColumn := alias + "." + driver.QuoteIdentifier(dstField.NativeName)
+	var from string
+	switch rel.Kind {
+	case HasOne, HasMany, ReferencesOne:
+		from = fmt.Sprintf("%s AS %s", driver.QuoteIdentifier(dstSch.NativeName), alias)
+		conds = append([]string{fmt.Sprintf("%s = %s", dstColumn, srcColumn)}, conds...)
+	case ReferencesMany:
+		// 通过中间表关联，原生中间表的字段名即列名
+		brgAlias := driver.QuoteIdentifier(fmt.Sprintf("r%db", depth))
+		brgTable, brgSrc, brgDst := rel.BrgSchema, rel.BrgSrcField, rel.BrgDstField
+		var brgConds []string
+		if !rel.BrgIsNative {
+			brgSch := ns.SchemaBy(rel.BrgSchema)
+			if brgSch == nil || brgSch.Fields[brgSrc] == nil || brgSch.Fields[brgDst] == nil {
+				return "", nil, false, fmt.Errorf("dba: invalid relation: %s.%s", sch.Name, name)
+			}
+			brgTable = brgSch.NativeName
+			brgSrc, brgDst = brgSch.Fields[brgSrc].NativeName, brgSch.Fields[brgDst].NativeName
+			if f := brgSch.SoftDeleteField(); f != nil {
+				s, a := softDeleteColumnCondition(brgAlias+"."+driver.QuoteIdentifier(f.NativeName), f, false)
+				brgConds = append(brgConds, s)
+				// 中间表条件在关联模型条件之前
+				attrs = append(a, attrs...)
+			}
+		}
+		from = fmt.Sprintf("%s AS %s INNER JOIN %s AS %s ON %s = %s",
+			driver.QuoteIdentifier(brgTable), brgAlias,
+			driver.QuoteIdentifier(dstSch.NativeName), alias,
+			dstColumn, brgAlias+"."+driver.QuoteIdentifier(brgDst))
+		brgConds = append([]string{fmt.Sprintf("%s = %s", brgAlias+"."+driver.QuoteIdentifier(brgSrc), srcColumn)}, brgConds...)
+		conds = append(brgConds, conds...)
+	default:
+		return "", nil, false, fmt.Errorf("dba: unknown relation: %s.%s[%s]", sch.Name, name, rel.Kind)
+	}
+	return fmt.Sprintf("(EXISTS (SELECT 1 FROM %s WHERE %s))", from, strings.Join(conds, " AND ")), attrs, true, nil
+}
@@ -0,0 +1,106 @@
+package dba
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type RelOrg struct {
+	ID    uint `dba:"pk"`
+	Name  string
+	Users []*RelUser `dba:"rel=HAS_MANY,ID->OrgID"`
+}
+
+type RelUser struct {
+	ID      uint `dba:"pk"`
+	Name    string
+	OrgID   uint
+	Org     *RelOrg     `dba:"rel=REF_ONE,OrgID->ID"`
+	Profile *RelProfile `dba:"rel=HAS_ONE,ID->UserID"`
+	Tags    []*RelTag   `dba:"rel=REF_MANY,rel_user_tag(ID->user_id,ID->tag_id)"`
+}
+
+type RelProfile struct {
+	ID     uint `dba:"pk"`
+	UserID uint
+	City   string
+}
+
+type RelTag struct {
+	ID        uint `dba:"pk"`
+	Name      string
+	DeletedAt *time.Time `dba:"soft_delete"`
+}
+
+func TestRelationFilter(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &RelOrg{}, &RelUser{}, &RelProfile{}, &RelTag{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"INSERT INTO rel_org (id, name) VALUES (1, 'ACME Inc'), (2, 'Globex')",
+		"INSERT INTO rel_user (id, name, org_id) VALUES (1, 'u1', 1), (2, 'u2', 1), (3, 'u3', 2)",
+		"INSERT INTO rel_profile (id, user_id, city) VALUES (1, 1, 'Paris'), (2, 3, 'Rome')",
+		"INSERT INTO rel_tag (id, name, deleted_at) VALUES (1, 'go', NULL), (2, 'sql', NULL), (3, 'old', '2020-01-01 00:00:00')",
+		"INSERT INTO rel_user_tag (user_id, tag_id) VALUES (1, 1), (2, 2), (3, 1), (3, 3)",
+	} {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	userIDs := func(conditions ...any) []uint {
+		t.Helper()
+		var users []*RelUser
+		if err := conn.ns.Model("RelUser").Find(conditions...).All(&users); err != nil {
+			t.Fatal(err)
+		}
+		var ids []uint
+		for _, u := range users {
+			ids = append(ids, u.ID)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		return ids
+	}
+	for _, tc := range []struct {
+		conditions []any
+		want       []uint
+	}{
+		{[]any{"Org.Name $PREFIX", "ACME"}, []uint{1, 2}},
+		{[]any{"Profile.City", "Rome"}, []uint{3}},
+		{[]any{"Tags.Name $IN", []string{"sql", "go"}}, []uint{1, 2, 3}},
+		{[]any{"Tags.Name", "sql"}, []uint{2}},
+		// 已软删除的关联数据不参与过滤
+		{[]any{"Tags.Name", "old"}, nil},
+		{[]any{"Org.Name $PREFIX", "ACME", "Tags.Name", "go"}, []uint{1}},
+		{[]any{"Org.Users.Profile.City", "Rome"}, []uint{3}},
+	} {
+		if got := userIDs(tc.conditions...); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%v: got %v, want %v", tc.conditions, got, tc.want)
+		}
+	}
+
+	var orgs []*RelOrg
+	if err := conn.ns.Model("RelOrg").Find("Users.Tags.Name", "sql").All(&orgs); err != nil {
+		t.Fatal(err)
+	}
+	if len(orgs) != 1 || orgs[0].ID != 1 {
+		t.Fatalf("unexpected orgs: %+v", orgs)
+	}
+
+	// 关系路径同样用于删除条件
+	if n, err := conn.ns.Model("RelUser").Find("Org.Name", "Globex").Delete(); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("expected 1 deleted user, got %d", n)
+	}
+	if got := userIDs(); !reflect.DeepEqual(got, []uint{1, 2}) {
+		t.Fatalf("unexpected users after delete: %v", got)
+	}
+
+	if err := conn.ns.Model("RelUser").Find("Org.Missing", "x").All(&[]*RelUser{}); err == nil {
+		t.Fatal("expected error for unknown relation field")
+	}
+}
@@ -0,0 +1,225 @@
+package dba
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const (
+	BalanceRoundRobin   = "round_robin"
+	BalanceRandom       = "random"
+	BalanceLeastLatency = "least_latency"
+)
+
+// DefaultReplicaCheckInterval 副本健康检查的默认间隔
+var DefaultReplicaCheckInterval = 10 * time.Second
+
+// ReplicaBalancer 从可用的副本中选择一个执行读操作，replicas不为空
+type ReplicaBalancer interface {
+	Pick(replicas []*Replica) *Replica
+}
+
+// Replica 只读副本，健康检查失败时移出轮询，恢复后重新加入
+type Replica struct {
+	index   int
+	dsn     string
+	xdb     *sqlx.DB
+	healthy atomic.Bool
+	latency atomic.Int64 // 最近一次健康检查的耗时（纳秒）
+}
+
+func (r *Replica) DSN() string {
+	return r.dsn
+}
+
+func (r *Replica) Healthy() bool {
+	return r.healthy.Load()
+}
+
+func (r *Replica) Latency() time.Duration {
+	return time.Duration(r.latency.Load())
+}
+
+// Stats 返回副本的连接池统计
+func (r *Replica) Stats() sql.DBStats {
+	return r.xdb.Stats()
+}
+
+type roundRobinBalancer struct {
+	next atomic.Uint64
+}
+
+func (b *roundRobinBalancer) Pick(replicas []*Replica) *Replica {
+	return replicas[(b.next.Add(1)-1)%uint64(len(replicas))]
+}
+
+type randomBalancer struct{}
+
+func (randomBalancer) Pick(replicas []*Replica) *Replica {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+type leastLatencyBalancer struct{}
+
+func (leastLatencyBalancer) Pick(replicas []*Replica) *Replica {
+	picked := replicas[0]
+	for _, r := range replicas[1:] {
+		if r.Latency() < picked.Latency() {
+			picked = r
+		}
+	}
+	return picked
+}
+
+// NewReplicaBalancer 按名称创建内置的负载均衡策略，名称为空时使用轮询
+func NewReplicaBalancer(name string) (ReplicaBalancer, error) {
+	switch name {
+	case "", BalanceRoundRobin:
+		return new(roundRobinBalancer), nil
+	case BalanceRandom:
+		return randomBalancer{}, nil
+	case BalanceLeastLatency:
+		return leastLatencyBalancer{}, nil
+	default:
+		return nil, fmt.Errorf("dba: invalid replica balance: %s", name)
+	}
+}
+
+// connectReplicas 连接配置中的全部副本并启动健康检查，副本在连接时必须可用
+func (c *Connection) connectReplicas(config *ConnectConfig) error {
+	if len(config.Replicas) == 0 {
+		return nil
+	}
+	c.balancer = config.Balancer
+	if c.balancer == nil {
+		balancer, err := NewReplicaBalancer(config.ReplicaBalance)
+		if err != nil {
+			return err
+		}
+		c.balancer = balancer
+	}
+	for i, dsn := range config.Replicas {
+		start := time.Now()
+		xdb, err := c.driver.Connect(&ConnectConfig{Driver: config.Driver, Dsn: dsn})
+		if err != nil {
+			c.closeReplicas()
+			return errors.Wrapf(err, "dba: connect replica #%d failed", i)
+		}
+		xdb.Mapper = newColumnMapper()
+		configurePool(xdb, config)
+		r := &Replica{index: i, dsn: dsn, xdb: xdb}
+		r.healthy.Store(true)
+		r.latency.Store(int64(time.Since(start)))
+		c.replicas = append(c.replicas, r)
+	}
+	interval := config.ReplicaCheckInterval
+	if interval <= 0 {
+		interval = DefaultReplicaCheckInterval
+	}
+	c.replicaStop = make(chan struct{})
+	go c.checkReplicas(c.replicaStop, interval)
+	return nil
+}
+
+func (c *Connection) checkReplicas(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.pingReplicas(interval)
+		}
+	}
+}
+
+// pingReplicas 检查各副本，失败的副本移出轮询，恢复的副本重新加入
+func (c *Connection) pingReplicas(timeout time.Duration) {
+	for _, r := range c.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		err := r.xdb.PingContext(ctx)
+		cancel()
+		r.latency.Store(int64(time.Since(start)))
+		if err != nil {
+			if r.healthy.Swap(false) {
+				c.logger.Warnf("Replica #%d removed from rotation: %v", r.index, err)
+			}
+		} else if !r.healthy.Swap(true) {
+			c.logger.Infof("Replica #%d restored to rotation", r.index)
+		}
+	}
+}
+
+// replica 按负载均衡策略选择可用的副本，没有可用副本时返回nil
+func (c *Connection) replica() *sqlx.DB {
+	if len(c.replicas) == 0 {
+		return nil
+	}
+	healthy := make([]*Replica, 0, len(c.replicas))
+	for _, r := range c.replicas {
+		if r.Healthy() {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	return c.balancer.Pick(healthy).xdb
+}
+
+// Replicas 返回连接的全部副本
+func (c *Connection) Replicas() []*Replica {
+	return append([]*Replica(nil), c.replicas...)
+}
+
+// closeReplicas 停止健康检查并关闭副本连接
+func (c *Connection) closeReplicas() {
+	c.replicaOnce.Do(func() {
+		if c.replicaStop != nil {
+			close(c.replicaStop)
+		}
+		for _, r := range c.replicas {
+			if err := r.xdb.Close(); err != nil {
+				c.logger.Errorf("Close replica #%d failed: %v", r.index, err)
+			}
+		}
+	})
+}
+
+type usePrimaryKey struct{}
+
+// queryer 返回读操作使用的数据库：绑定事务时使用事务，UsePrimary时使用主库，否则优先使用可用的副本
+func (r *Result) queryer(ctx context.Context) sqlx.QueryerContext {
+	if r.dm.xtx != nil {
+		return r.dm.xtx
+	}
+	if !r.primary && ctx.Value(usePrimaryKey{}) == nil {
+		if db := r.dm.conn.replica(); db != nil {
+			return db
+		}
+	}
+	return r.dm.xdb
+}
+
+// readContext UsePrimary时标记上下文，使关联查询同样读取主库
+func (r *Result) readContext(ctx context.Context) context.Context {
+	if r.primary {
+		return context.WithValue(ctx, usePrimaryKey{}, true)
+	}
+	return ctx
+}
+
+// UsePrimary 读操作使用主库，用于读取刚写入的数据
+func (r *Result) UsePrimary() *Result {
+	r.primary = true
+	return r
+}
@@ -0,0 +1,114 @@
+package dba
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type ReplicaItem struct {
+	ID   uint `dba:"pk;incr"`
+	Name string
+}
+
+func TestReplicaRouting(t *testing.T) {
+	dir := t.TempDir()
+	primaryDSN, replicaDSN := filepath.Join(dir, "primary.db"), filepath.Join(dir, "replica.db")
+	// 副本使用独立的数据库文件，通过数据区分读取的来源
+	replica := newTestConnection(t, replicaDSN, &ReplicaItem{})
+	if err := replica.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replica.Exec("INSERT INTO replica_item (name) VALUES ('replica')"); err != nil {
+		t.Fatal(err)
+	}
+
+	ns := &Namespace{Name: t.Name(), connections: new(sync.Map), schemas: new(sync.Map)}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	conn, err := ns.Connect(&ConnectConfig{Driver: SQLite, Dsn: primaryDSN, Replicas: []string{replicaDSN}, Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ns.DisconnectAll)
+	if err := ns.RegisterSchema(&ReplicaItem{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// 写入使用主库
+	if err := ns.Model("ReplicaItem").Create(&ReplicaItem{Name: "primary"}); err != nil {
+		t.Fatal(err)
+	}
+	name := func(r *Result) string {
+		t.Helper()
+		var item ReplicaItem
+		if err := r.One(&item); err != nil {
+			t.Fatal(err)
+		}
+		return item.Name
+	}
+	if got := name(ns.Model("ReplicaItem").Find()); got != "replica" {
+		t.Fatalf("expected read from replica, got %s", got)
+	}
+	if got := name(ns.Model("ReplicaItem").Find().UsePrimary()); got != "primary" {
+		t.Fatalf("expected read from primary, got %s", got)
+	}
+	if n, err := ns.Model("ReplicaItem").Find("Name", "replica").Count(); err != nil || n != 1 {
+		t.Fatalf("expected count from replica, got %d, %v", n, err)
+	}
+	// 事务内的读取使用主库
+	if err := conn.Transaction(context.Background(), func(tx *Tx) error {
+		if got := name(tx.Model("ReplicaItem").Find()); got != "primary" {
+			t.Errorf("expected read from primary inside transaction, got %s", got)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 健康检查失败的副本移出轮询，读取回退到主库
+	r := conn.Replicas()[0]
+	_ = r.xdb.Close()
+	conn.pingReplicas(time.Second)
+	if r.Healthy() {
+		t.Fatal("expected replica to be removed from rotation")
+	}
+	if got := name(ns.Model("ReplicaItem").Find()); got != "primary" {
+		t.Fatalf("expected fallback to primary, got %s", got)
+	}
+}
+
+func TestReplicaBalancer(t *testing.T) {
+	replicas := []*Replica{{index: 0}, {index: 1}, {index: 2}}
+	replicas[0].latency.Store(int64(30 * time.Millisecond))
+	replicas[1].latency.Store(int64(10 * time.Millisecond))
+	replicas[2].latency.Store(int64(20 * time.Millisecond))
+
+	rr, err := NewReplicaBalancer(BalanceRoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		if got := rr.Pick(replicas).index; got != i%3 {
+			t.Fatalf("round robin pick %d: got replica #%d", i, got)
+		}
+	}
+	ll, _ := NewReplicaBalancer(BalanceLeastLatency)
+	if got := ll.Pick(replicas).index; got != 1 {
+		t.Fatalf("least latency: got replica #%d", got)
+	}
+	random, _ := NewReplicaBalancer(BalanceRandom)
+	if got := random.Pick(replicas[2:]).index; got != 2 {
+		t.Fatalf("random: got replica #%d", got)
+	}
+	if _, err := NewReplicaBalancer("weighted"); err == nil {
+		t.Fatal("expected error for unknown balance")
+	}
+}
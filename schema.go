@@ -3,7 +3,10 @@ package dba
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -50,18 +53,31 @@ const (
 	ReferencesMany RelationKind = "REFERENCES_MANY" // ReferencedEntities
 )
 
+// relationKindAliases 关系配置中的简写
+var relationKindAliases = map[RelationKind]RelationKind{
+	"REF_ONE":  ReferencesOne,
+	"REF_MANY": ReferencesMany,
+}
+
 type SchemaInterface interface {
 	Schema() Schema
 }
 
+// VirtualFieldsProvider 模型通过该接口声明虚拟字段，虚拟字段不持久化，查询后计算
+type VirtualFieldsProvider interface {
+	VirtualFields() map[string]Field
+}
+
 type Schema struct {
-	cache      *sync.Map
-	structType reflect.Type
+	cache          *sync.Map
+	structType     reflect.Type
+	isNativeBridge bool // 由REF_MANY关系自动注册的原生中间表
 
 	Name        string            `json:"name,omitempty"`
 	NativeName  string            `json:"native_name,omitempty"`
 	Description string            `json:"description,omitempty"`
 	Fields      map[string]*Field `json:"fields,omitempty"`
+	Indexes     []*Index          `json:"indexes,omitempty"`
 
 	CreateClauses string `json:"create_clauses,omitempty"`
 	UpdateClauses string `json:"update_clauses,omitempty"`
@@ -76,6 +92,10 @@ func (s *Schema) Clone() *Schema {
 	for _, f := range s.Fields {
 		copied.Fields[f.Name] = f.Clone()
 	}
+	copied.Indexes = nil
+	for _, idx := range s.Indexes {
+		copied.Indexes = append(copied.Indexes, idx.Clone())
+	}
 	return copied
 }
 
@@ -97,7 +117,7 @@ func (s *Schema) ScalarFields() []*Field {
 	}
 	var fields []*Field
 	for _, field := range s.Fields {
-		if field.IsScalarType() {
+		if field.IsScalarType() && !field.IsVirtual {
 			fields = append(fields, field)
 		}
 	}
@@ -180,11 +200,35 @@ func (s *Schema) AutoIncrField() *Field {
 	return nil
 }
 
+// SoftDeleteField 返回软删除字段，未配置时返回nil
+func (s *Schema) SoftDeleteField() *Field {
+	for _, f := range s.Fields {
+		if f.IsSoftDelete {
+			return f
+		}
+	}
+	return nil
+}
+
+// VirtualFields 返回虚拟字段，按名称排序
+func (s *Schema) VirtualFields() []*Field {
+	var fields []*Field
+	for _, f := range s.Fields {
+		if f.IsVirtual {
+			fields = append(fields, f)
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
 func (s *Schema) NativeFieldNames(names []string, scalarTypeOnly bool) []string {
 	var result []string
 	for _, name := range names {
 		if field := s.Fields[name]; field.Valid() {
-			if scalarTypeOnly && !field.IsScalarType() {
+			if scalarTypeOnly && (!field.IsScalarType() || field.IsVirtual) {
 				continue
 			}
 			result = append(result, field.NativeName)
@@ -207,19 +251,23 @@ type Field struct {
 	IsPrimary       bool       `json:"is_primary"`
 	IsUnsigned      bool       `json:"is_unsigned"`
 	IsAutoIncrement bool       `json:"is_auto_increment"`
+	IsSoftDelete    bool       `json:"is_soft_delete,omitempty"`
+	IsVirtual       bool       `json:"is_virtual,omitempty"` // 虚拟字段，不持久化，查询后由VirtualHandler计算
+	Audit           AuditKind  `json:"audit,omitempty"`
+	AuditActorAttr  string     `json:"audit_actor_attr,omitempty"` // 操作人属性：id、account、name、org
 	DictCode        string     `json:"dict_code,omitempty"`
 
-	// TODO 默认值配置实现
-	DefaultConfig string
-	// TODO 必填配置实现
-	RequiredConfig string `json:"required_config,omitempty"`
-	RequiredGroup  string `json:"required_group,omitempty"`
-	RequiredOp     string `json:"required_op,omitempty"`
-	// TODO 唯一值配置实现
-	UniqueConfig string
-	// TODO 枚举值配置实现
-	EnumConfig     string `json:"enum_config,omitempty"`
-	VirtualHandler func(ctx context.Context, docPtr any) any
+	DefaultConfig  string `json:"default_config,omitempty"`  // 默认值，时间类型支持now；结构体非指针字段为零值时同样使用默认值
+	RequiredConfig string `json:"required_config,omitempty"` // 必填（req标签），为true时校验非空且建表时为NOT NULL
+	RequiredGroup  string `json:"required_group,omitempty"`  // 必填组，组内字段按RequiredOp校验
+	RequiredOp     string `json:"required_op,omitempty"`     // OR：组内任一字段必填；AND：组内字段均必填
+	UniqueConfig   string `json:"unique_config,omitempty"`   // 唯一索引名称，多个以逗号分隔，同名字段组成联合唯一索引；true表示单列唯一索引
+	IndexConfig    string `json:"index_config,omitempty"`    // 普通索引名称，规则同UniqueConfig
+	IndexOrder     string `json:"index_order,omitempty"`     // 字段在索引中的排序：ASC、DESC
+	IndexWhere     string `json:"index_where,omitempty"`     // 部分索引条件，MySQL不支持
+	EnumConfig     string `json:"enum_config,omitempty"`     // 枚举值，如1:男,2:女
+
+	VirtualHandler func(ctx context.Context, docPtr any) any `json:"-" msgpack:"-"`
 }
 
 func (f *Field) Valid() bool {
@@ -234,7 +282,7 @@ func (f *Field) IsScalarType() bool {
 }
 
 func (f *Field) IsRequired() bool {
-	return f.RequiredConfig != ""
+	return isRequiredConfig(f.RequiredConfig)
 }
 
 func (f *Field) Clone() *Field {
@@ -244,6 +292,81 @@ func (f *Field) Clone() *Field {
 	return copied
 }
 
+// IndexColumn 索引列，模型索引中为字段名称，数据库索引中为列名
+type IndexColumn struct {
+	Name string `json:"name"`
+	Desc bool   `json:"desc,omitempty"`
+}
+
+// Index 索引定义
+type Index struct {
+	Name    string         `json:"name"`
+	Unique  bool           `json:"unique,omitempty"`
+	Columns []*IndexColumn `json:"columns"`
+	Where   string         `json:"where,omitempty"` // 部分索引条件，MySQL不支持
+}
+
+func (idx *Index) Clone() *Index {
+	copied := new(Index)
+	*copied = *idx
+	copied.Columns = nil
+	for _, col := range idx.Columns {
+		c := *col
+		copied.Columns = append(copied.Columns, &c)
+	}
+	return copied
+}
+
+// buildIndexes 根据字段的索引配置生成索引，联合索引的列按order中的字段顺序排列，已存在的同名索引不覆盖
+func (s *Schema) buildIndexes(order []string) {
+	existing := make(map[string]bool)
+	for _, idx := range s.Indexes {
+		existing[idx.Name] = true
+	}
+	built := make(map[string]*Index)
+	var names []string
+	for _, name := range order {
+		f := s.Fields[name]
+		if f == nil || f.IsVirtual {
+			continue
+		}
+		for _, item := range []struct {
+			config string
+			unique bool
+			prefix string
+		}{
+			{f.IndexConfig, false, "idx"},
+			{f.UniqueConfig, true, "uk"},
+		} {
+			for _, v := range SplitAndTrimSpace(item.config, ",", true) {
+				idxName := v
+				if b, err := strconv.ParseBool(v); err == nil {
+					if !b {
+						continue
+					}
+					idxName = fmt.Sprintf("%s_%s_%s", item.prefix, s.NativeName, f.NativeName)
+				}
+				if existing[idxName] {
+					continue
+				}
+				idx := built[idxName]
+				if idx == nil {
+					idx = &Index{Name: idxName, Unique: item.unique}
+					built[idxName] = idx
+					names = append(names, idxName)
+				}
+				idx.Columns = append(idx.Columns, &IndexColumn{Name: f.Name, Desc: strings.EqualFold(f.IndexOrder, "DESC")})
+				if f.IndexWhere != "" {
+					idx.Where = f.IndexWhere
+				}
+			}
+		}
+	}
+	for _, name := range names {
+		s.Indexes = append(s.Indexes, built[name])
+	}
+}
+
 type Relation struct {
 	Kind      RelationKind `json:"kind,omitempty"`
 	Field     string       `json:"field,omitempty"`
@@ -256,6 +379,10 @@ type Relation struct {
 	BrgSrcField string `json:"brg_src_field,omitempty"`
 	BrgDstField string `json:"brg_dst_field,omitempty"`
 	BrgIsNative bool   `json:"brg_is_native,omitempty"`
+
+	ForeignKey bool   `json:"foreign_key,omitempty"` // 生成外键约束
+	OnDelete   string `json:"on_delete,omitempty"`   // 外键删除动作：cascade、restrict、set_null、set_default、no_action
+	OnUpdate   string `json:"on_update,omitempty"`   // 外键更新动作，取值同OnDelete
 }
 
 func (rs *Relation) Valid() bool {
@@ -288,6 +415,7 @@ func parseSchema(value any) (*Schema, error) {
 		return nil, errors.New("dba: value is nil")
 	}
 	if v, ok := value.(*Schema); ok {
+		v.buildIndexes(sortedKeys(v.Fields))
 		return v, nil
 	}
 	if v, ok := value.(map[string]any); ok {
@@ -295,6 +423,7 @@ func parseSchema(value any) (*Schema, error) {
 		if err := ConvertData(v, &s); err != nil {
 			return nil, err
 		}
+		s.buildIndexes(sortedKeys(s.Fields))
 		return &s, nil
 	}
 
@@ -324,7 +453,12 @@ func parseSchema(value any) (*Schema, error) {
 		if err := mergo.Merge(&sch, d); err != nil {
 			return nil, errors.Wrap(err, "dba: failed to merge schema")
 		}
+		// 自定义表名优先于结构体名称推导的表名
+		if d.NativeName != "" {
+			sch.NativeName = d.NativeName
+		}
 	}
+	var fieldOrder []string
 	for _, field := range s.Fields() {
 		fieldName := field.Name()
 		fieldValue := field.Value()
@@ -356,6 +490,7 @@ func parseSchema(value any) (*Schema, error) {
 				for _, embeddedField := range embeddedSchema.Fields {
 					sch.Fields[embeddedField.Name] = embeddedField
 				}
+				fieldOrder = append(fieldOrder, sortedKeys(embeddedSchema.Fields)...)
 			}
 			continue
 		}
@@ -363,6 +498,10 @@ func parseSchema(value any) (*Schema, error) {
 			Name:       fieldName,
 			NativeName: strcase.ToSnake(fieldName),
 		}
+		var (
+			foreignKey         bool
+			onDelete, onUpdate string
+		)
 		for k, v := range ParseTag(field.Tag("dba")) {
 			switch k {
 			case "name":
@@ -375,6 +514,8 @@ func parseSchema(value any) (*Schema, error) {
 				p.EnumConfig = v
 			case "dict":
 				p.DictCode = v
+			case "req":
+				p.RequiredConfig = v
 			case "required", "required_group", "req_group":
 				p.RequiredGroup = v
 				if p.RequiredOp == "" {
@@ -386,10 +527,37 @@ func parseSchema(value any) (*Schema, error) {
 				p.DefaultConfig = v
 			case "desc":
 				p.Description = v
+			case "index":
+				p.IndexConfig = v
+			case "unique":
+				p.UniqueConfig = v
+			case "index_order":
+				p.IndexOrder = strings.ToUpper(v)
+			case "index_where":
+				p.IndexWhere = v
 			case "pk":
 				p.IsPrimary = true
 			case "incr":
 				p.IsAutoIncrement = true
+			case "soft_delete", "deleted_at":
+				p.IsSoftDelete = true
+			case "created_at", "updated_at":
+				p.Audit = AuditKind(k)
+			case "created_by", "updated_by", "deleted_by":
+				p.Audit = AuditKind(k)
+				p.AuditActorAttr = ActorAttrID
+				if v != "" && v != "true" {
+					p.AuditActorAttr = v
+				}
+				if !isActorAttr(p.AuditActorAttr) {
+					return nil, errors.Errorf("dba: invalid actor attribute: %s.%s %s=%s", sch.Name, fieldName, k, v)
+				}
+			case "fk":
+				foreignKey = v != "false"
+			case "on_delete":
+				onDelete = v
+			case "on_update":
+				onUpdate = v
 			case "rel":
 				p.Relation = new(Relation)
 				p.RelationConfig = v
@@ -402,6 +570,11 @@ func parseSchema(value any) (*Schema, error) {
 				p.Relation.Field = p.Name
 			}
 		}
+		if p.Relation != nil {
+			p.Relation.ForeignKey = foreignKey
+			p.Relation.OnDelete = onDelete
+			p.Relation.OnUpdate = onUpdate
+		}
 		parseFieldType(fieldNewValue, fieldKind, p)
 		if elemType != nil {
 			p.Type = Array
@@ -415,7 +588,26 @@ func parseSchema(value any) (*Schema, error) {
 			continue
 		}
 		sch.Fields[p.Name] = p
+		fieldOrder = append(fieldOrder, p.Name)
+	}
+	if vp, ok := reflect.New(reflectType).Interface().(VirtualFieldsProvider); ok {
+		for name, vf := range vp.VirtualFields() {
+			vf := vf
+			if existing := sch.Fields[name]; existing != nil {
+				// 结构体中的同名字段仅用于接收计算结果
+				existing.IsVirtual = true
+				existing.VirtualHandler = vf.VirtualHandler
+				continue
+			}
+			vf.Name = name
+			if vf.NativeName == "" {
+				vf.NativeName = strcase.ToSnake(name)
+			}
+			vf.IsVirtual = true
+			sch.Fields[name] = &vf
+		}
 	}
+	sch.buildIndexes(fieldOrder)
 	structParsedMap.Store(parsedKey, sch)
 	return &sch, nil
 }
@@ -444,6 +636,7 @@ func parseFieldType(fieldNewValue any, fieldKind reflect.Kind, p *Field) {
 		*sql.NullBool, *null.Bool:
 		p.Type = Boolean
 	case string, *string:
+		p.Type = String
 	case sql.NullString, null.String,
 		*sql.NullString, *null.String:
 		p.Type = String
@@ -483,7 +676,10 @@ func parseRelation(config string, currentSchema *Schema, currentField *Field, sc
 	if i := strings.Index(config, ","); i <= 0 {
 		return nil
 	} else {
-		kind = RelationKind(strings.ToUpper(config[:i]))
+		kind = RelationKind(strings.ToUpper(strings.TrimSpace(config[:i])))
+		if v, ok := relationKindAliases[kind]; ok {
+			kind = v
+		}
 		others = config[i+1:]
 	}
 	others = strings.TrimSpace(others)
@@ -527,11 +723,9 @@ func parseRelation(config string, currentSchema *Schema, currentField *Field, sc
 				}
 			}
 		}
-		if _, has := schs[rel.BrgSchema]; has {
-			rel.BrgIsNative = false
-		} else {
-			rel.BrgIsNative = true
-		}
+		// 中间表未注册或为自动注册的原生中间表时视为原生中间表，结果与解析次数及注册顺序无关
+		brg := schs[rel.BrgSchema]
+		rel.BrgIsNative = brg == nil || brg.isNativeBridge
 	default:
 		return nil
 	}
@@ -553,6 +747,10 @@ func ParseTag(tagName string) map[string]string {
 		if i := strings.Index(item, "="); i > 0 {
 			key = item[:i]
 			value = item[i+1:]
+		} else if i := strings.Index(item, ":"); i > 0 {
+			// 兼容key:value写法，如req_group:USER_CONTACT
+			key = item[:i]
+			value = item[i+1:]
 		} else {
 			key = item
 			value = "true"
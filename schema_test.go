@@ -0,0 +1,83 @@
+package dba
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type IdxItem struct {
+	ID    uint   `dba:"pk;incr"`
+	Email string `dba:"unique"`
+	Code  string `dba:"unique=uk_idx_item_code_kind"`
+	Kind  string `dba:"unique=uk_idx_item_code_kind"`
+	Name  string `dba:"index"`
+	Score int    `dba:"index=idx_idx_item_score;index_order=desc;index_where=score > 0"`
+}
+
+func TestIndexDDL(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &IdxItem{})
+	sch := conn.ns.SchemaBy("IdxItem")
+	var got []string
+	for _, idx := range sch.Indexes {
+		var columns []string
+		for _, col := range idx.Columns {
+			columns = append(columns, col.Name)
+			if col.Desc {
+				columns[len(columns)-1] += " DESC"
+			}
+		}
+		got = append(got, idx.Name+"("+strings.Join(columns, ",")+")")
+	}
+	// 同名索引合并为复合索引，按字段声明顺序排列
+	if want := []string{"uk_idx_item_email(Email)", "uk_idx_item_code_kind(Code,Kind)", "idx_idx_item_name(Name)", "idx_idx_item_score(Score DESC)"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	schs := map[string]*Schema{"IdxItem": sch}
+	for name, lines := range map[string][]string{
+		SQLite: {
+			"CREATE UNIQUE INDEX IF NOT EXISTS `uk_idx_item_code_kind` ON `idx_item` (`code`, `kind`);",
+			"CREATE INDEX IF NOT EXISTS `idx_idx_item_score` ON `idx_item` (`score` DESC) WHERE score > 0;",
+		},
+		MySQL: {
+			"UNIQUE KEY `uk_idx_item_email` (`email`(191)),",
+			"KEY `idx_idx_item_score` (`score` DESC)",
+		},
+		PostgreSQL: {
+			`CREATE UNIQUE INDEX IF NOT EXISTS "uk_idx_item_email" ON "idx_item" ("email");`,
+			`CREATE INDEX IF NOT EXISTS "idx_idx_item_name" ON "idx_item" ("name");`,
+		},
+	} {
+		ddl := drivers[name].GenDDL([]string{"IdxItem"}, schs, true)
+		for _, line := range lines {
+			if !strings.Contains(ddl, line) {
+				t.Fatalf("%s: missing %q in\n%s", name, line, ddl)
+			}
+		}
+	}
+
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	tables, err := conn.driver.Inspect(context.Background(), conn.xdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(tables["idx_item"].Indexes); n != 4 {
+		t.Fatalf("expected 4 indexes, got %d", n)
+	}
+	model := func() *DataModel { return conn.ns.Model("IdxItem") }
+	if err := model().Create(&IdxItem{Email: "a", Code: "c", Kind: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := model().Create(&IdxItem{Email: "b", Code: "c", Kind: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []*IdxItem{{Email: "a", Code: "d"}, {Email: "c", Code: "c", Kind: "k"}} {
+		if err := model().Create(item); err == nil {
+			t.Fatalf("expected unique constraint error for %+v", item)
+		}
+	}
+}
@@ -533,6 +533,11 @@ func TrimSpaceSlice(s []string) {
 func Item2List(dst any) any {
 	v := reflect.Indirect(reflect.ValueOf(dst))
 	if k := v.Kind(); k != reflect.Array && k != reflect.Slice {
+		if !v.CanAddr() {
+			// map等不可寻址的值直接包装为单元素切片
+			s := reflect.MakeSlice(reflect.SliceOf(v.Type()), 0, 1)
+			return reflect.Append(s, v).Interface()
+		}
 		s := reflect.MakeSlice(reflect.SliceOf(v.Addr().Type()), 0, 0)
 		s = reflect.Append(s, v.Addr())
 		if s.CanAddr() {
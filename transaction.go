@@ -0,0 +1,116 @@
+package dba
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type TransactionOptions struct {
+	Isolation sql.IsolationLevel // 隔离级别，默认使用数据库的默认级别
+	ReadOnly  bool               // 只读事务
+}
+
+// Tx 事务会话，由Connection.Transaction创建，在回调返回后失效
+type Tx struct {
+	conn       *Connection
+	tx         *sqlx.Tx
+	savepoints int // 已创建的保存点数量，用于生成保存点名称
+}
+
+// Transaction 在事务中执行fn：fn返回nil时提交，返回错误或panic时回滚（panic会继续抛出）。
+// 嵌套事务通过Tx.Transaction使用保存点实现
+func (c *Connection) Transaction(ctx context.Context, fn func(tx *Tx) error, options ...*TransactionOptions) error {
+	var txOpts *sql.TxOptions
+	if len(options) > 0 && options[0] != nil {
+		txOpts = &sql.TxOptions{Isolation: options[0].Isolation, ReadOnly: options[0].ReadOnly}
+	}
+	xtx, err := c.xdb.BeginTxx(ctx, txOpts)
+	if err != nil {
+		return err
+	}
+	tx := &Tx{conn: c, tx: xtx}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = xtx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		if e := xtx.Rollback(); e != nil {
+			c.logger.Errorf("Transaction rollback failed: %v", e)
+		}
+		return err
+	}
+	return xtx.Commit()
+}
+
+// Transaction 使用保存点在当前事务中执行嵌套事务：fn返回错误或panic时回滚到保存点，外层事务不受影响
+func (tx *Tx) Transaction(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	tx.savepoints++
+	name := fmt.Sprintf("dba_sp_%d", tx.savepoints)
+	if _, err := tx.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = tx.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		if _, e := tx.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); e != nil {
+			tx.conn.logger.Errorf("Transaction rollback to savepoint failed: %v", e)
+		}
+		return err
+	}
+	_, err = tx.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// Model 返回绑定当前事务的模型
+func (tx *Tx) Model(schemaName string, options ...*ModelOptions) *DataModel {
+	var opts ModelOptions
+	if len(options) > 0 && options[0] != nil {
+		opts = *options[0]
+	}
+	opts.ConnectionName = tx.conn.name
+	opts.Tx = tx.tx
+	return tx.conn.ns.Model(schemaName, &opts)
+}
+
+// Connection 返回事务所属的连接
+func (tx *Tx) Connection() *Connection {
+	return tx.conn
+}
+
+// Tx 返回底层的sqlx事务，由Transaction负责提交或回滚，不能自行提交
+func (tx *Tx) Tx() *sqlx.Tx {
+	return tx.tx
+}
+
+func (tx *Tx) Query(dst any, query string, args ...any) error {
+	return tx.QueryContext(context.Background(), dst, query, args...)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, dst any, query string, args ...any) error {
+	query = tx.conn.Rebind(formatSQL(query))
+	return autoScan(ctx, dst, tx.tx, query, args)
+}
+
+func (tx *Tx) Exec(query string, args ...any) (int, error) {
+	return tx.ExecContext(context.Background(), query, args...)
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (int, error) {
+	query = tx.conn.Rebind(formatSQL(query))
+	r, err := tx.tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	n, err = r.RowsAffected()
+	return int(n), err
+}
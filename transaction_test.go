@@ -0,0 +1,135 @@
+package dba
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type TxItem struct {
+	ID   uint `dba:"pk;incr"`
+	Name string
+}
+
+func TestTransaction(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &TxItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	count := func() int {
+		t.Helper()
+		n, err := conn.ns.Model("TxItem").Find().Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// 返回nil时提交，事务内可以读取未提交的写入
+	err := conn.Transaction(ctx, func(tx *Tx) error {
+		if err := tx.Model("TxItem").CreateContext(ctx, &TxItem{Name: "a"}); err != nil {
+			return err
+		}
+		n, err := tx.Model("TxItem").Find().CountContext(ctx)
+		if err != nil {
+			return err
+		}
+		if n != 1 {
+			t.Errorf("expected 1 item inside transaction, got %d", n)
+		}
+		_, err = tx.Model("TxItem").Find("Name", "a").UpdateContext(ctx, map[string]any{"Name": "b"})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := conn.ns.Model("TxItem").Find("Name", "b").Count(); err != nil || n != 1 {
+		t.Fatalf("expected committed item, got %d, %v", n, err)
+	}
+
+	// 返回错误时回滚
+	errAbort := errors.New("abort")
+	err = conn.Transaction(ctx, func(tx *Tx) error {
+		if err := tx.Model("TxItem").CreateContext(ctx, &TxItem{Name: "c"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("expected abort error, got %v", err)
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("expected rollback, got %d items", n)
+	}
+
+	// panic时回滚并继续抛出
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic to propagate")
+			}
+		}()
+		_ = conn.Transaction(ctx, func(tx *Tx) error {
+			if _, err := tx.Exec("INSERT INTO tx_item (name) VALUES (?)", "d"); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+	}()
+	if n := count(); n != 1 {
+		t.Fatalf("expected rollback after panic, got %d items", n)
+	}
+
+	// 嵌套事务使用保存点，内层回滚不影响外层
+	err = conn.Transaction(ctx, func(tx *Tx) error {
+		if err := tx.Model("TxItem").CreateContext(ctx, &TxItem{Name: "outer"}); err != nil {
+			return err
+		}
+		if err := tx.Transaction(ctx, func(tx *Tx) error {
+			if err := tx.Model("TxItem").CreateContext(ctx, &TxItem{Name: "inner"}); err != nil {
+				return err
+			}
+			return errAbort
+		}); !errors.Is(err, errAbort) {
+			t.Errorf("expected inner abort error, got %v", err)
+		}
+		return tx.Transaction(ctx, func(tx *Tx) error {
+			return tx.Model("TxItem").CreateContext(ctx, &TxItem{Name: "inner2"})
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []*TxItem
+	if err := conn.Query(&items, "SELECT * FROM tx_item ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	if len(names) != 3 || names[1] != "outer" || names[2] != "inner2" {
+		t.Fatalf("unexpected items after nested transactions: %v", names)
+	}
+}
+
+func TestUpdateCommits(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &TxItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.ns.Model("TxItem").Create(&TxItem{Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := conn.ns.Model("TxItem").Find("Name", "a").Update(map[string]any{"Name": "b"}); err != nil || n != 1 {
+		t.Fatalf("update failed: %d, %v", n, err)
+	}
+	// 更新自行开启的事务已提交，连接已归还
+	if inUse := conn.xdb.Stats().InUse; inUse != 0 {
+		t.Fatalf("expected no connections in use, got %d", inUse)
+	}
+	if n, err := conn.ns.Model("TxItem").Find("Name", "b").Count(); err != nil || n != 1 {
+		t.Fatalf("update not committed: %d, %v", n, err)
+	}
+}
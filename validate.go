@@ -0,0 +1,318 @@
+package dba
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 校验规则
+const (
+	RuleRequired      = "required"
+	RuleRequiredGroup = "required_group"
+	RuleEnum          = "enum"
+	RuleDefault       = "default"
+)
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Index   int    `json:"index"` // 批量写入时的数据下标
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError 写入前校验失败，列出所有未通过的字段
+type ValidationError struct {
+	Schema string        `json:"schema"`
+	Errors []*FieldError `json:"errors"`
+	batch  bool
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+		if e.batch {
+			msgs[i] = fmt.Sprintf("[%d].%s", fe.Index, msgs[i])
+		}
+	}
+	return fmt.Sprintf("dba: validation failed: %s: %s", e.Schema, strings.Join(msgs, "; "))
+}
+
+func (e *ValidationError) add(index int, field, rule, format string, args ...any) {
+	e.Errors = append(e.Errors, &FieldError{
+		Index:   index,
+		Field:   field,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (e *ValidationError) orNil() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// DefaultValue 按字段类型解析默认值配置
+func (f *Field) DefaultValue() (any, error) {
+	v := f.DefaultConfig
+	switch f.Type {
+	case Integer:
+		return strconv.ParseInt(v, 10, 64)
+	case Float:
+		return strconv.ParseFloat(v, 64)
+	case Boolean:
+		return strconv.ParseBool(v)
+	case Time:
+		if strings.EqualFold(v, "now") {
+			return time.Now(), nil
+		}
+		return time.Parse(time.RFC3339, v)
+	default:
+		return v, nil
+	}
+}
+
+// EnumKeys 返回枚举配置中的可选值
+func (f *Field) EnumKeys() []string {
+	var keys []string
+	for _, item := range SplitAndTrimSpace(f.EnumConfig, ",", true) {
+		if i := strings.Index(item, ":"); i >= 0 {
+			item = strings.TrimSpace(item[:i])
+		}
+		keys = append(keys, item)
+	}
+	return keys
+}
+
+func (f *Field) enumContains(v any) bool {
+	s := fmt.Sprintf("%v", v)
+	for _, key := range f.EnumKeys() {
+		if key == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyFieldValue 判断必填字段是否未赋值，布尔类型仅nil视为未赋值
+func isEmptyFieldValue(f *Field, v any) bool {
+	if s, ok := v.(string); ok && s == SetToNullFlag {
+		return true
+	}
+	if f.Type == Boolean {
+		return isNilValue(v)
+	}
+	return IsNilOrZero(v)
+}
+
+// validationFields 返回参与校验的字段（含虚拟字段），按名称排序保证错误顺序稳定
+func (dm *DataModel) validationFields() []*Field {
+	var fields []*Field
+	for _, f := range dm.schema.Fields {
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
+// validateCreate 新增前为未赋值的字段（见fieldInput）填充默认值，并校验枚举、必填及必填组
+func (dm *DataModel) validateCreate(ctx context.Context, docs []reflect.Value) error {
+	verr := &ValidationError{Schema: dm.schema.Name, batch: len(docs) > 1}
+	for i, doc := range docs {
+		for doc.Kind() == reflect.Interface || (doc.Kind() == reflect.Ptr && !doc.IsNil()) {
+			doc = doc.Elem()
+		}
+		target := settableOf(doc)
+
+		groups := make(map[string][]bool)
+		groupOps := make(map[string]string)
+		var handlerDoc any
+		for _, f := range dm.validationFields() {
+			var (
+				v       any
+				present bool
+			)
+			if f.IsVirtual {
+				if f.VirtualHandler == nil || (f.RequiredGroup == "" && !isRequiredConfig(f.RequiredConfig)) {
+					continue
+				}
+				if handlerDoc == nil {
+					handlerDoc = dm.handlerDoc(doc)
+				}
+				v = f.VirtualHandler(ctx, handlerDoc)
+			} else {
+				v, present = fieldInput(doc, f)
+			}
+
+			if f.DefaultConfig != "" && !f.IsVirtual && !present {
+				dv, err := f.DefaultValue()
+				if err != nil {
+					verr.add(i, f.Name, RuleDefault, "invalid default value %q: %v", f.DefaultConfig, err)
+					continue
+				}
+				if err := SetFieldOrKey(target, f.Name, dv); err != nil {
+					verr.add(i, f.Name, RuleDefault, "set default value failed: %v", err)
+					continue
+				}
+				v = dv
+			}
+			if f.EnumConfig != "" && !isEmptyFieldValue(f, v) && !f.enumContains(v) {
+				verr.add(i, f.Name, RuleEnum, "value %v is not one of [%s]", v, strings.Join(f.EnumKeys(), ", "))
+			}
+			if isRequiredConfig(f.RequiredConfig) && isEmptyFieldValue(f, v) {
+				verr.add(i, f.Name, RuleRequired, "is required")
+			}
+			if f.RequiredGroup != "" {
+				groups[f.RequiredGroup] = append(groups[f.RequiredGroup], !isEmptyFieldValue(f, v))
+				if groupOps[f.RequiredGroup] == "" {
+					groupOps[f.RequiredGroup] = f.RequiredOp
+				}
+			}
+		}
+		for _, group := range sortedKeys(groups) {
+			if !requiredGroupSatisfied(groupOps[group], groups[group]) {
+				verr.add(i, group, RuleRequiredGroup, "%s", requiredGroupMessage(groupOps[group], dm.requiredGroupFields(group)))
+			}
+		}
+	}
+	return verr.orNil()
+}
+
+// fieldInput 读取新增数据中的字段值，present表示数据中已显式赋值：
+// map按字段名或列名判断键是否存在，结构体的指针字段非nil、其他字段非零值时视为已赋值。
+// 结构体非指针字段无法区分未赋值与零值，显式的false、0、""同样会被默认值覆盖，
+// 需要保存零值时应使用指针字段或map
+func fieldInput(doc reflect.Value, f *Field) (any, bool) {
+	switch doc.Kind() {
+	case reflect.Map:
+		if doc.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		for _, name := range []string{f.Name, f.NativeName} {
+			if val := doc.MapIndex(reflect.ValueOf(name).Convert(doc.Type().Key())); val.IsValid() {
+				return val.Interface(), true
+			}
+		}
+		return nil, false
+	case reflect.Struct:
+		val := doc.FieldByName(f.Name)
+		if !val.IsValid() {
+			return nil, false
+		}
+		if val.Kind() == reflect.Ptr {
+			return val.Interface(), !val.IsNil()
+		}
+		return val.Interface(), !val.IsZero()
+	}
+	return nil, false
+}
+
+// validateUpdate 更新前校验待更新字段：枚举、必填字段不可清空、必填组不可整体清空
+func (dm *DataModel) validateUpdate(pairs map[string]any) error {
+	verr := &ValidationError{Schema: dm.schema.Name}
+	nativeFields := dm.schema.NativeFields()
+	type groupItem struct {
+		name  string
+		empty bool
+	}
+	cleared := make(map[string][]groupItem)
+	for _, k := range sortedKeys(pairs) {
+		v := pairs[k]
+		f := dm.schema.Fields[k]
+		if !f.Valid() {
+			f = nativeFields[k]
+		}
+		if !f.Valid() || !f.IsScalarType() || f.IsVirtual {
+			continue
+		}
+		empty := isEmptyFieldValue(f, v)
+		if f.EnumConfig != "" && !empty && !f.enumContains(v) {
+			verr.add(0, f.Name, RuleEnum, "value %v is not one of [%s]", v, strings.Join(f.EnumKeys(), ", "))
+		}
+		if isRequiredConfig(f.RequiredConfig) && empty {
+			verr.add(0, f.Name, RuleRequired, "is required")
+		}
+		if f.RequiredGroup != "" {
+			cleared[f.RequiredGroup] = append(cleared[f.RequiredGroup], groupItem{f.Name, empty})
+		}
+	}
+	for _, group := range sortedKeys(cleared) {
+		var (
+			members    = dm.requiredGroupFields(group)
+			op         = dm.schema.Fields[cleared[group][0].name].RequiredOp
+			anyCleared bool
+			allCleared = len(cleared[group]) == len(members)
+		)
+		for _, item := range cleared[group] {
+			if item.empty {
+				anyCleared = true
+			} else {
+				allCleared = false
+			}
+		}
+		// AND组任一字段清空即不满足；OR组所有字段均被清空时不满足
+		if (strings.EqualFold(op, "AND") && anyCleared) || allCleared {
+			verr.add(0, group, RuleRequiredGroup, "%s", requiredGroupMessage(op, members))
+		}
+	}
+	return verr.orNil()
+}
+
+// requiredGroupFields 返回必填组内的字段名
+func (dm *DataModel) requiredGroupFields(group string) []string {
+	var names []string
+	for _, f := range dm.validationFields() {
+		if f.RequiredGroup == group {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+func isRequiredConfig(v string) bool {
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+func requiredGroupSatisfied(op string, filled []bool) bool {
+	var n int
+	for _, ok := range filled {
+		if ok {
+			n++
+		}
+	}
+	if strings.EqualFold(op, "AND") {
+		return n == len(filled)
+	}
+	return n > 0
+}
+
+func requiredGroupMessage(op string, names []string) string {
+	if strings.EqualFold(op, "AND") {
+		return fmt.Sprintf("all of [%s] are required", strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("at least one of [%s] is required", strings.Join(names, ", "))
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,113 @@
+package dba
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type DefaultItem struct {
+	ID      uint `dba:"pk;incr"`
+	Name    string
+	Enabled bool  `dba:"default=true"`
+	Level   int   `dba:"default=3"`
+	Visible *bool `dba:"default=true"`
+}
+
+func TestCreateDefaults(t *testing.T) {
+	conn := newTestConnection(t, filepath.Join(t.TempDir(), "test.db"), &DefaultItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	model := func() *DataModel { return conn.ns.Model("DefaultItem") }
+	load := func(name string) *DefaultItem {
+		t.Helper()
+		var item DefaultItem
+		if err := model().Find("Name", name).One(&item); err != nil {
+			t.Fatal(err)
+		}
+		return &item
+	}
+
+	// 结构体非指针字段的零值视为未赋值，使用默认值
+	hidden := false
+	if err := model().Create(&DefaultItem{Name: "struct", Enabled: false, Level: 0, Visible: &hidden}); err != nil {
+		t.Fatal(err)
+	}
+	if item := load("struct"); !item.Enabled || item.Level != 3 || item.Visible == nil || *item.Visible {
+		t.Fatalf("unexpected struct defaults: %+v", item)
+	}
+	if err := model().Create(&DefaultItem{Name: "nil"}); err != nil {
+		t.Fatal(err)
+	}
+	if item := load("nil"); item.Visible == nil || !*item.Visible {
+		t.Fatalf("expected default for nil pointer: %+v", item)
+	}
+
+	// map中显式赋值的零值保留，缺少的键使用默认值
+	if err := model().Create(map[string]any{"Name": "map", "Enabled": false, "Level": 0}); err != nil {
+		t.Fatal(err)
+	}
+	if item := load("map"); item.Enabled || item.Level != 0 || item.Visible == nil || !*item.Visible {
+		t.Fatalf("unexpected map defaults: %+v", item)
+	}
+}
+
+type ValidItem struct {
+	ID    uint   `dba:"pk;incr"`
+	Name  string `dba:"req"`
+	Kind  string `dba:"enum=a:A,b:B;default=a"`
+	Email string `dba:"req_group:CONTACT"`
+	Phone string `dba:"req_group:CONTACT"`
+}
+
+func TestValidation(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &ValidItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	model := func() *DataModel { return conn.ns.Model("ValidItem") }
+	rules := func(err error) []string {
+		t.Helper()
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("expected validation error, got %v", err)
+		}
+		var result []string
+		for _, fe := range verr.Errors {
+			result = append(result, fmt.Sprintf("%d.%s.%s", fe.Index, fe.Field, fe.Rule))
+		}
+		return result
+	}
+
+	item := &ValidItem{Name: "a", Phone: "1"}
+	if err := model().Create(item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Kind != "a" {
+		t.Fatalf("expected default enum value, got %q", item.Kind)
+	}
+	err := model().Create([]*ValidItem{{Name: "b", Email: "x"}, {Kind: "c"}})
+	if got, want := rules(err), []string{"1.Kind.enum", "1.Name.required", "1.CONTACT.required_group"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if !strings.Contains(err.Error(), "[1].CONTACT: at least one of [Email, Phone] is required") {
+		t.Fatalf("unexpected message: %v", err)
+	}
+	// 校验失败时不写入任何数据
+	if n, _ := model().Find().Count(); n != 1 {
+		t.Fatalf("expected no rows written, got %d", n)
+	}
+
+	_, err = model().Find("ID", item.ID).Update(map[string]any{"Name": "", "Kind": "z", "Email": "", "Phone": ""})
+	if got, want := rules(err), []string{"0.Kind.enum", "0.Name.required", "0.CONTACT.required_group"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// 必填组中仍有其他字段有值时允许清空
+	if _, err := model().Find("ID", item.ID).Update(map[string]any{"Email": "x", "Phone": ""}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,117 @@
+package dba
+
+import (
+	"context"
+	"reflect"
+)
+
+// handlerDoc 返回传给VirtualHandler的数据：结构体返回其指针，map按模型结构体转换（未注册结构体时原样返回）
+func (dm *DataModel) handlerDoc(doc reflect.Value) any {
+	for doc.Kind() == reflect.Interface || (doc.Kind() == reflect.Ptr && !doc.IsNil()) {
+		doc = doc.Elem()
+	}
+	switch doc.Kind() {
+	case reflect.Struct:
+		if doc.CanAddr() {
+			return doc.Addr().Interface()
+		}
+		copied := reflect.New(doc.Type())
+		copied.Elem().Set(doc)
+		return copied.Interface()
+	case reflect.Map:
+		if dm.schema.structType == nil {
+			return doc.Interface()
+		}
+		ptr := reflect.New(dm.schema.structType).Interface()
+		rv := NewReflectValue(doc.Interface())
+		for _, f := range dm.schema.ScalarFields() {
+			v := rv.FieldByName(f.Name)
+			if v == nil {
+				v = rv.FieldByName(f.NativeName)
+			}
+			if value := indirectValue(v); !isNilValue(value) {
+				_ = SetFieldOrKey(ptr, f.Name, value)
+			}
+		}
+		return ptr
+	}
+	return nil
+}
+
+// selectedVirtualFields 按Select/Omit返回需要计算的虚拟字段
+//
+// Select包含虚拟字段时会查询全部列，此时返回需保留的map键（原生字段名）
+func (r *Result) selectedVirtualFields() ([]*Field, map[string]bool) {
+	all := r.dm.schema.VirtualFields()
+	if len(all) == 0 || len(r.fields) == 0 {
+		return all, nil
+	}
+	named := make(map[string]*Field)
+	for _, n := range r.fields {
+		f := r.dm.schema.Fields[n]
+		if !f.Valid() {
+			f = r.dm.schema.NativeFields()[n]
+		}
+		if f.Valid() {
+			named[f.Name] = f
+		}
+	}
+	var fields []*Field
+	for _, f := range all {
+		if _, ok := named[f.Name]; ok != r.isOmit {
+			fields = append(fields, f)
+		}
+	}
+	if r.isOmit || len(fields) == 0 {
+		return fields, nil
+	}
+	keep := make(map[string]bool)
+	for _, f := range named {
+		keep[f.NativeName] = true
+	}
+	return fields, keep
+}
+
+// computeVirtuals 计算查询结果中的虚拟字段：map写入原生字段名，结构体写入同名字段
+func (r *Result) computeVirtuals(ctx context.Context, dst any) {
+	fields, keep := r.selectedVirtualFields()
+	if len(fields) == 0 {
+		return
+	}
+	ru := NewReflectValue(dst)
+	if ru.IsArray() {
+		for i := 0; i < ru.Len(); i++ {
+			r.dm.computeVirtualsOf(ctx, ru.Index(i), fields, keep)
+		}
+		return
+	}
+	r.dm.computeVirtualsOf(ctx, reflect.ValueOf(dst), fields, keep)
+}
+
+func (dm *DataModel) computeVirtualsOf(ctx context.Context, doc reflect.Value, fields []*Field, keep map[string]bool) {
+	for doc.Kind() == reflect.Interface || (doc.Kind() == reflect.Ptr && !doc.IsNil()) {
+		doc = doc.Elem()
+	}
+	if doc.Kind() != reflect.Map && !(doc.Kind() == reflect.Struct && doc.CanAddr()) {
+		return
+	}
+	arg := dm.handlerDoc(doc)
+	for _, f := range fields {
+		if f.VirtualHandler == nil {
+			continue
+		}
+		v := f.VirtualHandler(ctx, arg)
+		if doc.Kind() == reflect.Map {
+			_ = SetFieldOrKey(doc.Interface(), f.NativeName, v)
+		} else if doc.FieldByName(f.Name).IsValid() {
+			_ = SetFieldOrKey(doc.Addr().Interface(), f.Name, v)
+		}
+	}
+	if doc.Kind() == reflect.Map && keep != nil {
+		for _, key := range doc.MapKeys() {
+			if !keep[key.String()] {
+				doc.SetMapIndex(key, reflect.Value{})
+			}
+		}
+	}
+}
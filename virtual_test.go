@@ -0,0 +1,91 @@
+package dba
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type VirtItem struct {
+	ID       uint `dba:"pk;incr"`
+	First    string
+	Last     string
+	FullName string
+}
+
+func (VirtItem) VirtualFields() map[string]Field {
+	return map[string]Field{
+		"FullName": {
+			Type: String,
+			VirtualHandler: func(ctx context.Context, docPtr any) any {
+				v := docPtr.(*VirtItem)
+				return v.First + " " + v.Last
+			},
+		},
+		"Initials": {
+			Type: String,
+			VirtualHandler: func(ctx context.Context, docPtr any) any {
+				v := docPtr.(*VirtItem)
+				return v.First[:1] + v.Last[:1]
+			},
+		},
+	}
+}
+
+func TestVirtualFields(t *testing.T) {
+	conn := newTestConnection(t, testDSN(t), &VirtItem{})
+	if err := conn.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// 虚拟字段不建列
+	if ddl := conn.GenDDL(conn.ns.SchemaBys("VirtItem"), true); !strings.Contains(ddl, "first") || strings.Contains(ddl, "full_name") || strings.Contains(ddl, "initials") {
+		t.Fatalf("unexpected ddl: %s", ddl)
+	}
+	model := func() *DataModel { return conn.ns.Model("VirtItem") }
+	if err := model().Create(&VirtItem{First: "Ada", Last: "Lovelace"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var item VirtItem
+	if err := model().Find().One(&item); err != nil {
+		t.Fatal(err)
+	}
+	if item.FullName != "Ada Lovelace" {
+		t.Fatalf("unexpected struct result: %+v", item)
+	}
+	item = VirtItem{}
+	if err := model().Find().Omit("FullName").One(&item); err != nil {
+		t.Fatal(err)
+	}
+	if item.FullName != "" || item.First != "Ada" {
+		t.Fatalf("expected omitted virtual field: %+v", item)
+	}
+
+	keys := func(r *Result) map[string]any {
+		t.Helper()
+		row := make(map[string]any)
+		if err := r.One(row); err != nil {
+			t.Fatal(err)
+		}
+		return row
+	}
+	row := keys(model().Find())
+	if row["full_name"] != "Ada Lovelace" || row["initials"] != "AL" {
+		t.Fatalf("unexpected map result: %v", row)
+	}
+	// Select虚拟字段时按全部列计算，只返回选择的字段
+	row = keys(model().Find().Select("ID", "Initials"))
+	var names []string
+	for k := range row {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"id", "initials"}) || row["initials"] != "AL" {
+		t.Fatalf("unexpected selected result: %v", row)
+	}
+	if row = keys(model().Find().Select("ID", "First")); row["full_name"] != nil || row["initials"] != nil {
+		t.Fatalf("expected no virtual fields: %v", row)
+	}
+}